@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"errors"
 	"reflect"
-	"strconv"
 	"strings"
 )
 
@@ -23,6 +22,19 @@ type UserError struct {
 
 func (e UserError) Error() string { return e.Msg }
 
+// prefixError returns err with prefix prepended to its message.
+// UserErrors and InternalErrors keep their type, so callers can still distinguish them.
+func prefixError(prefix string, err error) error {
+	switch err := err.(type) {
+	case UserError:
+		return UserError{prefix + err.Msg}
+	case InternalError:
+		return InternalError{prefix + err.Msg}
+	default:
+		return errors.New(prefix + err.Error())
+	}
+}
+
 // RejectUnknownFields is whether to fail to parse JSON with unknown fields. This includes fields which exist in the struct at a later version than is being parsed.
 const RejectUnknownFields = true // TODO implement
 
@@ -32,14 +44,33 @@ const TagName = `api`
 // TagPropertyStr is the name of the tag property to use for parsing numbers and booleans as strings.
 const TagPropertyStr = `str`
 
+// TagPropertyRequired is the name of the tag property to require an unversioned field to exist when unmarshalling.
+const TagPropertyRequired = `required`
+
+// TagPropertyOptional is the name of the tag property to allow a versioned non-pointer field to be missing when unmarshalling, in which case it's set to its zero value.
+const TagPropertyOptional = `optional`
+
+// TagPropertyDeprecated is the name of the tag property to mark a field deprecated in generated documentation, such as JSONSchema and OpenAPI. It doesn't change marshalling or unmarshalling.
+const TagPropertyDeprecated = `deprecated`
+
 // UnmarshalJSON parses JSON for the given object.
 // bts is the JSON bytes.
 // realObj is the object to unmarshal into.
 // version is the object version being used. Fields in the object with a newer version than version must be pointers, and will not be deserialized into, even if the field exists in the JSON in bts. This is to preserve Semantic Versioning.
 func UnmarshalJSON(bts []byte, realObj interface{}, version float64) error {
-	// TODO add option to reject any realObj with a field missing a tc:version tag
-	// TODO add option to reject any bts with fields not in realObj - https://golang.org/pkg/encoding/json/#Decoder.DisallowUnknownFields
+	return UnmarshalJSONWithOptions(bts, realObj, version, Options{})
+}
+
+// UnmarshalJSONWithOptions is UnmarshalJSON, with the tag names and behavior of opts.
+func UnmarshalJSONWithOptions(bts []byte, realObj interface{}, version float64, opts Options) error {
+	if u, ok := versionUnmarshaler(realObj); ok && opts.unmarshalHooks() {
+		return u.UnmarshalJSONVersion(bts, version)
+	}
+	return UnmarshalCodecWithOptions(JSONCodec, bts, realObj, version, opts)
+}
 
+// unmarshalObj builds the object to decode into for realObj with the codec c, calls decode with a pointer to it, and sets realObj from the decoded object.
+func unmarshalObj(c Codec, realObj interface{}, version float64, opts Options, decode func(fakeObj interface{}) error) error {
 	obj := reflect.ValueOf(realObj)
 	if obj.Kind() != reflect.Ptr {
 		return InternalError{"object must be a pointer"}
@@ -54,15 +85,19 @@ func UnmarshalJSON(bts []byte, realObj interface{}, version float64) error {
 	// 	return InternalError{"object must be a pointer to a struct"}
 	// }
 
-	newVal := BuildUnmarshalObj(obj, version, true)
+	if err := opts.checkType(c, obj.Type()); err != nil {
+		return err
+	}
+
+	newVal := buildUnmarshalObj(c, obj, version, true, opts)
 
 	newValI := newVal.Addr().Interface()
 
-	if err := json.Unmarshal(bts, newValI); err != nil {
+	if err := decode(newValI); err != nil {
 		return err
 	}
 
-	if err := FromUnmarshalObj(newVal, realObj); err != nil {
+	if err := fromUnmarshalObj(c, newVal, realObj); err != nil {
 		return err
 	}
 
@@ -74,30 +109,35 @@ type TagProperties struct {
 	Version float64
 	// Str is whether "str" existed, which indicates that a string should be parsed as a boolean or number.
 	Str bool
+	// Required is whether "required" existed, which indicates that the field must exist, even if it isn't versioned.
+	Required bool
+	// Optional is whether "optional" existed, which indicates that the field may be missing, even if it's versioned and not a pointer.
+	Optional bool
+	// Deprecated is whether "deprecated" existed, which indicates that the field is deprecated in generated documentation.
+	Deprecated bool
+	// Rename is the name of the field in every encoding, from a schema registered with RegisterSchema. Tags never set it.
+	Rename string
 }
 
 // GetTagProperties returns the properties from the given tag. An empty string may be passed, which will indicate no version (therefore, all versions), and that the field should not accept a string for a number or boolean.
 func GetTagProperties(tag string) TagProperties {
-	props := TagProperties{}
-	for _, prop := range strings.Split(tag, ",") {
-		switch prop {
-		case TagPropertyStr:
-			props.Str = true
-		default:
-			if f, err := strconv.ParseFloat(prop, 64); err == nil {
-				props.Version = f
-			} else {
-				// TODO log? Return error?
-			}
-		}
-	}
-	return props
+	return Options{}.GetTagProperties(tag)
+}
+
+// ParseTagProperties is GetTagProperties, returning an InternalError if the tag is malformed, such as the typo `api:"1,4"`, which GetTagProperties parses as version 4.
+func ParseTagProperties(tag string) (TagProperties, error) {
+	return Options{}.ParseTagProperties(tag)
 }
 
 // BuildUnmarshalObj creates an object to be serialized or deserialized into, from the given val, omitting versions newer than version, and dynamically creating types which will deserialize from strings for fields with TagName TagPropertyStr.
 // The strTypes should always be false to build an object for unmarshalling into, and should always be true for building an object to marshal into bytes. This parameter exists, because the 'str' types use the largest possible type, and can lead to precision loss for smaller types like float32.
 func BuildUnmarshalObj(val reflect.Value, version float64, strTypes bool) reflect.Value {
-	newTyp := BuildUnmarshalType(val.Type(), version, strTypes)
+	return buildUnmarshalObj(JSONCodec, val, version, strTypes, Options{})
+}
+
+// buildUnmarshalObj is BuildUnmarshalObj, building the type for the codec c.
+func buildUnmarshalObj(c Codec, val reflect.Value, version float64, strTypes bool, opts Options) reflect.Value {
+	newTyp := buildUnmarshalType(c, val.Type(), version, strTypes, opts)
 	return reflect.New(newTyp).Elem()
 }
 
@@ -108,13 +148,23 @@ func BuildUnmarshalObj(val reflect.Value, version float64, strTypes bool) reflec
 // 3. converts "str" fields to types which will deserialize as strings or their real type (int,float.bool)
 //
 func BuildUnmarshalType(typ reflect.Type, version float64, strTypes bool) reflect.Type {
-	// TODO error if val has non-pointer fields newer than version (which can never be filled, but must be filled - ergo all non-base versions must be pointers to make any sense)
+	return buildUnmarshalType(JSONCodec, typ, version, strTypes, Options{})
+}
+
+// BuildUnmarshalTypeWithOptions is BuildUnmarshalType, with the tag names of opts.
+func BuildUnmarshalTypeWithOptions(typ reflect.Type, version float64, strTypes bool, opts Options) reflect.Type {
+	return buildUnmarshalType(JSONCodec, typ, version, strTypes, opts)
+}
+
+// buildUnmarshalType is BuildUnmarshalType, using the codec c for field tags and str types, and opts for api tags.
+func buildUnmarshalType(c Codec, typ reflect.Type, version float64, strTypes bool, opts Options) reflect.Type {
+	// Malformed tags, and non-pointer fields newer than the type's first version, are InternalErrors with Options.Strict. See ValidateType.
 
 	if typ.Kind() == reflect.Slice {
-		return reflect.SliceOf(BuildUnmarshalType(typ.Elem(), version, strTypes))
+		return reflect.SliceOf(buildUnmarshalType(c, typ.Elem(), version, strTypes, opts))
 	}
 	if typ.Kind() == reflect.Map {
-		return reflect.MapOf(BuildUnmarshalType(typ.Key(), version, strTypes), BuildUnmarshalType(typ.Elem(), version, strTypes))
+		return reflect.MapOf(buildUnmarshalType(c, typ.Key(), version, strTypes, opts), buildUnmarshalType(c, typ.Elem(), version, strTypes, opts))
 	}
 	if typ.Kind() != reflect.Struct {
 		return typ // if it's not a slice, map, or struct, return the type as-is
@@ -137,7 +187,7 @@ func BuildUnmarshalType(typ reflect.Type, version float64, strTypes bool) reflec
 
 		// fmt.Println("DEBUG but type '" + typ.String() + "' field '" + field.Name + "' PkgPath '" + field.PkgPath + "'")
 
-		props := GetTagProperties(field.Tag.Get(TagName))
+		props := opts.GetFieldProperties(typ, field)
 		if props.Version > version {
 			changedAnyFields = true // we skipped a field, structs are different
 			continue
@@ -148,14 +198,14 @@ func BuildUnmarshalType(typ reflect.Type, version float64, strTypes bool) reflec
 		newField.Type = field.Type
 		newField.PkgPath = field.PkgPath
 		if newField.Type.Kind() == reflect.Struct {
-			newType := BuildUnmarshalType(newField.Type, version, strTypes)
+			newType := buildUnmarshalType(c, newField.Type, version, strTypes, opts)
 			if newType != newField.Type {
 				changedAnyFields = true // we changed a field that was a struct, structs are different
 			}
 			newField.Type = newType
 		}
 
-		if newField.Type.Kind() != reflect.Ptr && props.Version != 0.0 {
+		if newField.Type.Kind() != reflect.Ptr && (props.Required || (props.Version != 0.0 && !props.Optional)) {
 			// no need to pointer-ify fields with no "api:version" tag
 			// TODO verify this is correct
 
@@ -167,45 +217,22 @@ func BuildUnmarshalType(typ reflect.Type, version float64, strTypes bool) reflec
 		}
 
 		if strTypes && props.Str {
-			switch newField.Type.Elem().Kind() {
-			case reflect.Bool:
-				newField.Type = reflect.PtrTo(reflect.TypeOf(BoolS(false)))
-				changedAnyFields = true // we changed a field str type, structs are different
-			case reflect.Int:
-				fallthrough
-			case reflect.Int8:
-				fallthrough
-			case reflect.Int16:
-				fallthrough
-			case reflect.Int32:
-				fallthrough
-			case reflect.Int64:
-				newField.Type = reflect.PtrTo(reflect.TypeOf(IntS(0)))
-				changedAnyFields = true // we changed a field str type, structs are different
-			case reflect.Uint:
-				fallthrough
-			case reflect.Uint8:
-				fallthrough
-			case reflect.Uint16:
-				fallthrough
-			case reflect.Uint32:
-				fallthrough
-			case reflect.Uint64:
-				fallthrough
-			case reflect.Uintptr:
-				newField.Type = reflect.PtrTo(reflect.TypeOf(UIntS(0)))
-				changedAnyFields = true // we changed a field str type, structs are different
-			case reflect.Float32:
-				fallthrough
-			case reflect.Float64:
-				newField.Type = reflect.PtrTo(reflect.TypeOf(FloatS(0)))
+			// unversioned and optional fields may not be pointers, in which case the str type isn't a pointer either, and missing values are its zero value.
+			if newField.Type.Kind() != reflect.Ptr {
+				if strType := c.StrType(newField.Type.Kind()); strType != nil {
+					newField.Type = strType
+					changedAnyFields = true // we changed a field str type, structs are different
+				}
+			} else if strType := c.StrType(newField.Type.Elem().Kind()); strType != nil {
+				newField.Type = reflect.PtrTo(strType)
 				changedAnyFields = true // we changed a field str type, structs are different
-			default:
-				// TODO error?
 			}
+			// str on other kinds is ignored, or an InternalError with Options.Strict. See ValidateType.
 		}
 
-		newField.Tag = field.Tag
+		if newField.Tag = schemaFieldTag(c, typ, field); newField.Tag != field.Tag {
+			changedAnyFields = true // we changed a field tag, structs are different
+		}
 
 		newTypeFields = append(newTypeFields, newField)
 	}
@@ -220,6 +247,11 @@ func BuildUnmarshalType(typ reflect.Type, version float64, strTypes bool) reflec
 // FromUnmarshalObj converts an object created with BuildUnmarshalObj, presumably after decoding data into it, into the real object.
 // Returns an error if any value fields in the realObj are nil in the val.
 func FromUnmarshalObj(fakeVal reflect.Value, realObj interface{}) error {
+	return fromUnmarshalObj(JSONCodec, fakeVal, realObj)
+}
+
+// fromUnmarshalObj is FromUnmarshalObj, for an object built for the codec c.
+func fromUnmarshalObj(c Codec, fakeVal reflect.Value, realObj interface{}) error {
 	realVal := reflect.ValueOf(realObj)
 	if realVal.Kind() != reflect.Ptr {
 		return InternalError{"object must be a pointer"}
@@ -227,10 +259,17 @@ func FromUnmarshalObj(fakeVal reflect.Value, realObj interface{}) error {
 	if realVal.IsNil() {
 		return InternalError{"object must not be nil"}
 	}
-	return SetUnmarshalObj(fakeVal, realVal)
+	return setUnmarshalObj(c, fakeVal, realVal)
 }
 
+// SetUnmarshalObj sets realVal from fakeVal, which was built with BuildUnmarshalObj and decoded into.
+// Returns a UserError naming the field by its json tag, if a required field in realVal is nil in fakeVal.
 func SetUnmarshalObj(fakeVal reflect.Value, realVal reflect.Value) error {
+	return setUnmarshalObj(JSONCodec, fakeVal, realVal)
+}
+
+// setUnmarshalObj is SetUnmarshalObj, for an object built for the codec c. Fields are named in errors by c.FieldName, and c's str types are converted to their real types.
+func setUnmarshalObj(c Codec, fakeVal reflect.Value, realVal reflect.Value) error {
 	fakeVal = reflect.Indirect(fakeVal)
 
 	if fakeVal == (reflect.Value{}) {
@@ -250,7 +289,7 @@ func SetUnmarshalObj(fakeVal reflect.Value, realVal reflect.Value) error {
 		}
 		for i := 0; i < fakeVal.Len(); i++ {
 			newRealValElem := reflect.New(realVal.Type().Elem())
-			if err := SetUnmarshalObj(fakeVal.Index(i), newRealValElem); err != nil {
+			if err := setUnmarshalObj(c, fakeVal.Index(i), newRealValElem); err != nil {
 				return errors.New("setting slice type '" + realVal.Type().String() + "': " + err.Error())
 			}
 			newRealValElem = reflect.Indirect(newRealValElem)
@@ -274,12 +313,12 @@ func SetUnmarshalObj(fakeVal reflect.Value, realVal reflect.Value) error {
 			fakeValVal := fakeVal.MapIndex(fakeValKey)
 
 			realValKey := reflect.New(realVal.Type().Key())
-			if err := SetUnmarshalObj(fakeValKey, realValKey); err != nil {
+			if err := setUnmarshalObj(c, fakeValKey, realValKey); err != nil {
 				return errors.New("setting map type '" + realVal.Type().String() + "' key: " + err.Error())
 			}
 
 			realValVal := reflect.New(realVal.Type().Elem())
-			if err := SetUnmarshalObj(fakeValVal, realValVal); err != nil {
+			if err := setUnmarshalObj(c, fakeValVal, realValVal); err != nil {
 				return errors.New("setting map type '" + realVal.Type().String() + "' val: " + err.Error())
 			}
 
@@ -293,6 +332,13 @@ func SetUnmarshalObj(fakeVal reflect.Value, realVal reflect.Value) error {
 			return InternalError{"realVal '" + realVal.Type().String() + "' struct type does not match fakeVal type '" + fakeVal.Type().String() + "'"}
 		}
 
+		if fakeVal.Type() == realVal.Type() && hasUnmarshalMethod(fakeVal.Type()) {
+			// The type was used verbatim, and decoded itself, so its unexported fields may be set, e.g. time.Time. Copy it verbatim.
+			// Other types are copied field by field, so nil pointers from missing fields and unexported fields don't overwrite the real object's values.
+			realVal.Set(fakeVal)
+			return nil
+		}
+
 		for i := 0; i < fakeVal.NumField(); i++ {
 			fakeValTypeField := fakeVal.Type().Field(i)
 			fakeValField := fakeVal.Field(i)
@@ -304,14 +350,8 @@ func SetUnmarshalObj(fakeVal reflect.Value, realVal reflect.Value) error {
 
 			// fmt.Println("DEBUG type '" + fakeVal.Type().String() + "' field '" + fakeValTypeField.Name + "' PkgPath '" + fakeValTypeField.PkgPath + "'")
 
-			// fieldTagName is the user-facing field name: json tag if it exists, else the struct field name.
-			// TODO remove/abstract json tag, so this func is fully encoder-agnostic?
-			fieldTagName := fakeValTypeField.Name
-			if jsonTag := fakeValTypeField.Tag.Get("json"); jsonTag != "" {
-				if jsonTagParts := strings.Split(jsonTag, ","); len(jsonTagParts) > 0 {
-					fieldTagName = jsonTagParts[0]
-				}
-			}
+			// fieldTagName is the user-facing field name, e.g. the json tag if it exists, else the struct field name.
+			fieldTagName := c.FieldName(fakeValTypeField)
 
 			if realValField == (reflect.Value{}) {
 				return InternalError{"object missing field in val '" + fieldTagName + "'"}
@@ -322,8 +362,8 @@ func SetUnmarshalObj(fakeVal reflect.Value, realVal reflect.Value) error {
 				return UserError{"missing required field: " + fieldTagName} // TODO make missing-required-field an err type?
 			}
 
-			if err := SetUnmarshalObj(fakeValField, realValField); err != nil {
-				// TODO better error objects, to set the "user field name" if SetUnmarshalObj returns a user err, and the struct name if it returns a system err.
+			if err := setUnmarshalObj(c, fakeValField, realValField); err != nil {
+				// TODO better error objects, to set the "user field name" if setUnmarshalObj returns a user err, and the struct name if it returns a system err.
 				return errors.New("field '" + fieldTagName + "':" + err.Error())
 			}
 		}
@@ -340,7 +380,7 @@ func SetUnmarshalObj(fakeVal reflect.Value, realVal reflect.Value) error {
 			fakeVal = reflect.Indirect(fakeVal)
 		}
 
-		if fakeVal.Type() == reflect.TypeOf(IntS(0)) || fakeVal.Type() == reflect.TypeOf(UIntS(0)) || fakeVal.Type() == reflect.TypeOf(FloatS(0)) || fakeVal.Type() == reflect.TypeOf(BoolS(false)) {
+		if strType := c.StrType(realVal.Kind()); strType != nil && fakeVal.Type() == strType {
 			if !realVal.Type().ConvertibleTo(fakeVal.Type()) {
 				// fmt.Println("DEBUG SetUnmarshalObj returning err not convertible")
 				return InternalError{"realVal type '" + realVal.Type().String() + "' is not convertible to fakeVal type '" + fakeVal.Type().String() + "'"}
@@ -360,12 +400,26 @@ func SetUnmarshalObj(fakeVal reflect.Value, realVal reflect.Value) error {
 		// fmt.Println("DEBUG SetUnmarshalObj returning nil success non-struct")
 		return nil
 	}
+}
 
-	return nil
+// tagFieldName returns the user-facing name of field: the name in its tagKey tag if it has one, else the struct field name.
+func tagFieldName(field reflect.StructField, tagKey string) string {
+	if tagName := strings.Split(field.Tag.Get(tagKey), ",")[0]; tagName != "" {
+		return tagName
+	}
+	return field.Name
 }
 
 func MarshalJSON(realObj interface{}, version float64) ([]byte, error) {
-	obj, err := BuildMarshalObj(realObj, version)
+	return MarshalJSONWithOptions(realObj, version, Options{})
+}
+
+// MarshalJSONWithOptions is MarshalJSON, with the tag names of opts.
+func MarshalJSONWithOptions(realObj interface{}, version float64, opts Options) ([]byte, error) {
+	if m, ok := versionMarshaler(realObj); ok && opts.marshalHooks() {
+		return m.MarshalJSONVersion(version)
+	}
+	obj, err := buildMarshalObj(JSONCodec, realObj, version, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -373,7 +427,12 @@ func MarshalJSON(realObj interface{}, version float64) ([]byte, error) {
 }
 
 func MarshalJSONIndent(realObj interface{}, prefix, indent string, version float64) ([]byte, error) {
-	obj, err := BuildMarshalObj(realObj, version)
+	return MarshalJSONIndentWithOptions(realObj, prefix, indent, version, Options{})
+}
+
+// MarshalJSONIndentWithOptions is MarshalJSONIndent, with the tag names of opts.
+func MarshalJSONIndentWithOptions(realObj interface{}, prefix, indent string, version float64, opts Options) ([]byte, error) {
+	obj, err := buildMarshalJSONObj(realObj, version, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -381,6 +440,11 @@ func MarshalJSONIndent(realObj interface{}, prefix, indent string, version float
 }
 
 func BuildMarshalObj(realObj interface{}, version float64) (interface{}, error) {
+	return buildMarshalJSONObj(realObj, version, Options{})
+}
+
+// buildMarshalObj is BuildMarshalObj, building the object for the codec c.
+func buildMarshalObj(c Codec, realObj interface{}, version float64, opts Options) (interface{}, error) {
 	// TODO add option to reject any bts with fields not in realObj - https://golang.org/pkg/encoding/json/#Decoder.DisallowUnknownFields
 	if realObj == nil {
 		return realObj, nil
@@ -397,7 +461,11 @@ func BuildMarshalObj(realObj interface{}, version float64) (interface{}, error)
 	// 	return nil, InternalError{"object must be a pointer to a struct"} // TODO handle slices of structs?
 	// }
 
-	fakeVal := BuildUnmarshalObj(obj, version, false)
+	if err := opts.checkType(c, obj.Type()); err != nil {
+		return nil, err
+	}
+
+	fakeVal := buildUnmarshalObj(c, obj, version, false, opts)
 	if err := CopyIntoMarshalObj(fakeVal, obj); err != nil {
 		return nil, err
 	}
@@ -42,6 +42,52 @@ func TestUnmarshalJSONPtr(t *testing.T) {
 	}
 }
 
+func TestUnmarshalJSONUnversionedKeepsPtr(t *testing.T) {
+	type Obj struct {
+		Foo int  `json:"foo"`
+		A   *int `json:"a"`
+	}
+
+	a := 49
+	obj := Obj{A: &a}
+	objJ := `{"foo": 42}`
+	err := UnmarshalJSON([]byte(objJ), &obj, 1.3)
+	if err != nil {
+		t.Errorf("UnmarshalJSON %+v error expected nil, actual %+v", objJ, err)
+	}
+	if obj.Foo != 42 {
+		t.Errorf("UnmarshalJSON obj.Foo expected: %+v, actual: %+v", 42, obj.Foo)
+	}
+	if obj.A == nil || *obj.A != 49 {
+		t.Errorf("UnmarshalJSON obj.A expected: %+v, actual: %+v", 49, obj.A)
+	}
+}
+
+func TestUnmarshalJSONUnexportedKeepsPtr(t *testing.T) {
+	type Obj struct {
+		Foo   int  `json:"foo"`
+		A     *int `json:"a"`
+		cache int
+	}
+
+	a := 49
+	obj := Obj{A: &a, cache: 7}
+	objJ := `{"foo": 42}`
+	err := UnmarshalJSON([]byte(objJ), &obj, 1.3)
+	if err != nil {
+		t.Errorf("UnmarshalJSON %+v error expected nil, actual %+v", objJ, err)
+	}
+	if obj.Foo != 42 {
+		t.Errorf("UnmarshalJSON obj.Foo expected: %+v, actual: %+v", 42, obj.Foo)
+	}
+	if obj.A == nil || *obj.A != 49 {
+		t.Errorf("UnmarshalJSON obj.A expected: %+v, actual: %+v", 49, obj.A)
+	}
+	if obj.cache != 7 {
+		t.Errorf("UnmarshalJSON obj.cache expected: %+v, actual: %+v", 7, obj.cache)
+	}
+}
+
 func TestUnmarshalJSONMissingVal(t *testing.T) {
 	type Obj struct {
 		Foo int     `json:"foo" api:"1.1,str"`
@@ -295,6 +341,59 @@ func TestUnmarshalJSONBoolStrZeroPointZeroTrue(t *testing.T) {
 	}
 }
 
+func TestBoolSTextDiffersFromJSON(t *testing.T) {
+	tests := map[string]bool{
+		"false": true,
+		"0.0":   true,
+		"0":     false,
+		"":      false,
+		"x":     true,
+	}
+	for text, expected := range tests {
+		jsonB := BoolS(!expected)
+		if err := jsonB.UnmarshalJSON([]byte(`"` + text + `"`)); err != nil || bool(jsonB) != expected {
+			t.Errorf("BoolS.UnmarshalJSON %q expected: %v, actual: %v %+v", text, expected, jsonB, err)
+		}
+	}
+
+	textTests := map[string]bool{
+		"false": false,
+		"0.0":   false,
+		"0":     false,
+		"":      false,
+		"x":     true,
+		"true":  true,
+	}
+	for text, expected := range textTests {
+		textB := BoolS(!expected)
+		if err := textB.UnmarshalText([]byte(text)); err != nil || bool(textB) != expected {
+			t.Errorf("BoolS.UnmarshalText %q expected: %v, actual: %v %+v", text, expected, textB, err)
+		}
+	}
+}
+
+func TestUnmarshalStrValue(t *testing.T) {
+	i := IntS(0)
+	if err := UnmarshalStrValue("42", &i); err != nil || i != 42 {
+		t.Errorf("UnmarshalStrValue IntS expected: %+v, actual: %+v %+v", 42, i, err)
+	}
+	u := UIntS(0)
+	if err := UnmarshalStrValue(int64(-1), &u); err == nil {
+		t.Errorf("UnmarshalStrValue UIntS negative error expected: %+v, actual: %+v", "not an integer", err)
+	}
+	f := FloatS(0)
+	if err := UnmarshalStrValue(uint64(3), &f); err != nil || f != 3 {
+		t.Errorf("UnmarshalStrValue FloatS expected: %+v, actual: %+v %+v", 3, f, err)
+	}
+	b := BoolS(true)
+	if err := UnmarshalStrValue("0", &b); err != nil || b != false {
+		t.Errorf("UnmarshalStrValue BoolS expected: %+v, actual: %+v %+v", false, b, err)
+	}
+	if err := UnmarshalStrValue("42", new(int64)); err == nil {
+		t.Errorf("UnmarshalStrValue non-str type error expected: %+v, actual: %+v", "must be", err)
+	}
+}
+
 func TestUnmarshalJSONBoolNumTrue(t *testing.T) {
 	type Obj struct {
 		Foo int  `json:"foo" api:"1.4,str"`
@@ -0,0 +1,135 @@
+// Package cbor provides versioned CBOR (RFC 8949) encoding and decoding, with the same semantics as the apiver JSON functions.
+// It's implemented as an apiver.Codec, using github.com/fxamacker/cbor.
+package cbor
+
+import (
+	"io"
+	"reflect"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/rob05c/apiver"
+)
+
+// Codec is the apiver.Codec for CBOR. It's registered with apiver as "cbor".
+// Built types have cbor tags derived from json tags, for fields without cbor tags, so the same struct definitions and names may be used for both encodings.
+var Codec apiver.Codec = codec{apiver.BaseCodec{Tag: "cbor"}}
+
+func init() {
+	apiver.RegisterCodec("cbor", Codec)
+}
+
+// Unmarshal parses CBOR for the given object.
+// This behaves exactly like apiver.UnmarshalJSON: fields newer than version are not deserialized into, 'str' fields accept strings, and missing required fields return the same apiver.UserError.
+func Unmarshal(bts []byte, realObj interface{}, version float64) error {
+	return apiver.UnmarshalCodec(Codec, bts, realObj, version)
+}
+
+// UnmarshalWithOptions is Unmarshal, with the tag names and behavior of opts, like apiver.UnmarshalJSONWithOptions.
+func UnmarshalWithOptions(bts []byte, realObj interface{}, version float64, opts apiver.Options) error {
+	return apiver.UnmarshalCodecWithOptions(Codec, bts, realObj, version, opts)
+}
+
+// Marshal serializes the given object as CBOR, omitting fields newer than version.
+func Marshal(realObj interface{}, version float64) ([]byte, error) {
+	return apiver.MarshalCodec(Codec, realObj, version)
+}
+
+// MarshalWithOptions is Marshal, with the tag names and behavior of opts.
+func MarshalWithOptions(realObj interface{}, version float64, opts apiver.Options) ([]byte, error) {
+	return apiver.MarshalCodecWithOptions(Codec, realObj, version, opts)
+}
+
+type codec struct{ apiver.BaseCodec }
+
+func (c codec) FieldTag(field reflect.StructField) reflect.StructTag {
+	return apiver.DeriveTagFromJSON(field, c.Tag)
+}
+
+func (c codec) StrType(kind reflect.Kind) reflect.Type {
+	switch apiver.DefaultStrType(kind) {
+	case reflect.TypeOf(apiver.IntS(0)):
+		return reflect.TypeOf(IntS(0))
+	case reflect.TypeOf(apiver.UIntS(0)):
+		return reflect.TypeOf(UIntS(0))
+	case reflect.TypeOf(apiver.FloatS(0)):
+		return reflect.TypeOf(FloatS(0))
+	case reflect.TypeOf(apiver.BoolS(false)):
+		return reflect.TypeOf(BoolS(false))
+	default:
+		return nil
+	}
+}
+
+func (c codec) Marshal(fakeObj interface{}, realType reflect.Type) ([]byte, error) {
+	return cbor.Marshal(fakeObj)
+}
+
+func (c codec) Unmarshal(data []byte, fakeObj interface{}) error {
+	return cbor.Unmarshal(data, fakeObj)
+}
+
+func (c codec) UnmarshalDisallowUnknownFields(data []byte, fakeObj interface{}) error {
+	return unknownFieldsDecMode.Unmarshal(data, fakeObj)
+}
+
+// unknownFieldsDecMode decodes like cbor.Unmarshal, but returns an error for map keys which aren't fields of the struct decoded into.
+var unknownFieldsDecMode = func() cbor.DecMode {
+	dm, err := cbor.DecOptions{ExtraReturnErrors: cbor.ExtraDecErrorUnknownField}.DecMode()
+	if err != nil {
+		panic("cbor: creating decode mode: " + err.Error()) // the options are constant, so this never happens
+	}
+	return dm
+}()
+
+// Decoder reads and decodes versioned CBOR objects from an input stream. It mirrors apiver.JSONDecoder.
+type Decoder struct {
+	Version float64
+	D       *cbor.Decoder
+	Options apiver.Options
+}
+
+// NewDecoder returns a new decoder that reads from r, and decodes objects at version.
+func NewDecoder(r io.Reader, version float64) *Decoder {
+	return &Decoder{Version: version, D: cbor.NewDecoder(r)}
+}
+
+// NewDecoderWithOptions is NewDecoder, decoding with the tag names and behavior of opts.
+func NewDecoderWithOptions(r io.Reader, version float64, opts apiver.Options) *Decoder {
+	if opts.DisallowUnknownFields {
+		return &Decoder{Version: version, D: unknownFieldsDecMode.NewDecoder(r), Options: opts}
+	}
+	return &Decoder{Version: version, D: cbor.NewDecoder(r), Options: opts}
+}
+func (d *Decoder) Buffered() io.Reader { return d.D.Buffered() }
+func (d *Decoder) NumBytesRead() int   { return d.D.NumBytesRead() }
+func (d *Decoder) Skip() error         { return d.D.Skip() }
+func (d *Decoder) Decode(realObj interface{}) error {
+	return apiver.UnmarshalCodecFuncWithOptions(Codec, realObj, d.Version, d.Options, d.D.Decode)
+}
+
+// Encoder writes versioned CBOR objects to an output stream. It mirrors apiver.JSONEncoder.
+type Encoder struct {
+	Version float64
+	E       *cbor.Encoder
+	Options apiver.Options
+}
+
+// NewEncoder returns a new encoder that writes to w, and encodes objects at version.
+func NewEncoder(w io.Writer, version float64) *Encoder {
+	return &Encoder{Version: version, E: cbor.NewEncoder(w)}
+}
+
+// NewEncoderWithOptions is NewEncoder, encoding with the tag names and behavior of opts.
+func NewEncoderWithOptions(w io.Writer, version float64, opts apiver.Options) *Encoder {
+	return &Encoder{Version: version, E: cbor.NewEncoder(w), Options: opts}
+}
+func (e *Encoder) StartIndefiniteArray() error { return e.E.StartIndefiniteArray() }
+func (e *Encoder) StartIndefiniteMap() error   { return e.E.StartIndefiniteMap() }
+func (e *Encoder) EndIndefinite() error        { return e.E.EndIndefinite() }
+func (e *Encoder) Encode(v interface{}) error {
+	obj, err := apiver.BuildMarshalCodecObjWithOptions(Codec, v, e.Version, e.Options)
+	if err != nil {
+		return err
+	}
+	return e.E.Encode(obj)
+}
@@ -0,0 +1,241 @@
+package cbor
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/rob05c/apiver"
+)
+
+func TestUnmarshalBasic(t *testing.T) {
+	type Obj struct {
+		Foo int  `json:"foo" api:"1.1"`
+		A   *int `json:"a" api:"1.4"`
+	}
+
+	bts, err := cbor.Marshal(map[string]interface{}{"foo": 42, "a": 24})
+	if err != nil {
+		t.Fatalf("cbor.Marshal error expected nil, actual %+v", err)
+	}
+
+	obj := Obj{}
+	if err := Unmarshal(bts, &obj, 1.3); err != nil {
+		t.Fatalf("Unmarshal error expected nil, actual %+v", err)
+	}
+	if obj.Foo != 42 {
+		t.Errorf("Unmarshal obj.Foo expected: %+v, actual: %+v", 42, obj.Foo)
+	}
+	if obj.A != nil {
+		t.Errorf("Unmarshal obj.A expected: nil, actual: %+v", *obj.A)
+	}
+}
+
+func TestUnmarshalStr(t *testing.T) {
+	type Obj struct {
+		I int     `json:"i" api:"1.1,str"`
+		U uint16  `json:"u" api:"1.1,str"`
+		F float32 `json:"f" api:"1.1,str"`
+		B bool    `json:"b" api:"1.1,str"`
+	}
+
+	bts, err := cbor.Marshal(map[string]interface{}{"i": "-42", "u": "42", "f": "4.5", "b": "asdf"})
+	if err != nil {
+		t.Fatalf("cbor.Marshal error expected nil, actual %+v", err)
+	}
+
+	obj := Obj{}
+	if err := Unmarshal(bts, &obj, 1.1); err != nil {
+		t.Fatalf("Unmarshal error expected nil, actual %+v", err)
+	}
+	expected := Obj{I: -42, U: 42, F: 4.5, B: true}
+	if obj != expected {
+		t.Errorf("Unmarshal expected: %+v, actual: %+v", expected, obj)
+	}
+}
+
+func TestUnmarshalStrNative(t *testing.T) {
+	type Obj struct {
+		I int     `json:"i" api:"1.1,str"`
+		U uint16  `json:"u" api:"1.1,str"`
+		F float32 `json:"f" api:"1.1,str"`
+		B bool    `json:"b" api:"1.1,str"`
+		Z bool    `json:"z" api:"1.1,str"`
+	}
+
+	bts, err := cbor.Marshal(map[string]interface{}{"i": int8(-42), "u": uint64(42), "f": float32(4.5), "b": true, "z": 0})
+	if err != nil {
+		t.Fatalf("cbor.Marshal error expected nil, actual %+v", err)
+	}
+
+	obj := Obj{Z: true}
+	if err := Unmarshal(bts, &obj, 1.1); err != nil {
+		t.Fatalf("Unmarshal error expected nil, actual %+v", err)
+	}
+	expected := Obj{I: -42, U: 42, F: 4.5, B: true}
+	if obj != expected {
+		t.Errorf("Unmarshal expected: %+v, actual: %+v", expected, obj)
+	}
+}
+
+func TestUnmarshalStrInvalid(t *testing.T) {
+	type Obj struct {
+		I int `json:"i" api:"1.1,str"`
+	}
+
+	bts, err := cbor.Marshal(map[string]interface{}{"i": "4.2"})
+	if err != nil {
+		t.Fatalf("cbor.Marshal error expected nil, actual %+v", err)
+	}
+
+	obj := Obj{}
+	if err := Unmarshal(bts, &obj, 1.1); err == nil {
+		t.Errorf("Unmarshal error expected non-nil, actual nil")
+	}
+}
+
+func TestUnmarshalMissingVal(t *testing.T) {
+	type Obj struct {
+		Foo int `json:"foo" api:"1.1"`
+		A   int `json:"a" api:"1.4"`
+	}
+
+	bts, err := cbor.Marshal(map[string]interface{}{"foo": 42})
+	if err != nil {
+		t.Fatalf("cbor.Marshal error expected nil, actual %+v", err)
+	}
+
+	obj := Obj{}
+	err = Unmarshal(bts, &obj, 1.4)
+	if err == nil || err.Error() != "missing required field: a" {
+		t.Errorf("Unmarshal error expected 'missing required field: a', actual %+v", err)
+	}
+	if _, ok := err.(apiver.UserError); !ok {
+		t.Errorf("Unmarshal error expected UserError, actual %T", err)
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	type Obj struct {
+		Foo int  `json:"foo" api:"1.1,str"`
+		Bar int  `cbor:"cb_bar" json:"bar" api:"1.1"`
+		A   *int `json:"a" api:"1.4"`
+	}
+	a := 24
+
+	bts, err := Marshal(Obj{Foo: 42, Bar: 7, A: &a}, 1.3)
+	if err != nil {
+		t.Fatalf("Marshal error expected nil, actual %+v", err)
+	}
+
+	actual := map[string]interface{}{}
+	if err := cbor.Unmarshal(bts, &actual); err != nil {
+		t.Fatalf("cbor.Unmarshal error expected nil, actual %+v", err)
+	}
+	if len(actual) != 2 {
+		t.Errorf("Marshal expected 2 keys, actual: %+v", actual)
+	}
+	if v, ok := actual["foo"]; !ok || v != uint64(42) {
+		t.Errorf("Marshal foo expected: %+v, actual: %T %+v", 42, v, v)
+	}
+	if v, ok := actual["cb_bar"]; !ok || v != uint64(7) {
+		t.Errorf("Marshal cb_bar expected: %+v, actual: %T %+v", 7, v, v)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	type B struct {
+		Val    int  `json:"val" api:"1.1,str"`
+		NewVal *int `json:"new_val" api:"1.5,str"`
+	}
+	type Obj struct {
+		Name string `json:"name" api:"1.1"`
+		B    B      `json:"b" api:"1.2"`
+	}
+	newVal := 9
+	obj := Obj{Name: "foo", B: B{Val: 42, NewVal: &newVal}}
+
+	bts, err := Marshal(obj, 1.4)
+	if err != nil {
+		t.Fatalf("Marshal error expected nil, actual %+v", err)
+	}
+	newObj := Obj{}
+	if err := Unmarshal(bts, &newObj, 1.5); err != nil {
+		t.Fatalf("Unmarshal error expected nil, actual %+v", err)
+	}
+	if newObj.Name != obj.Name || newObj.B.Val != obj.B.Val || newObj.B.NewVal != nil {
+		t.Errorf("round trip expected %+v, actual %+v", Obj{Name: "foo", B: B{Val: 42}}, newObj)
+	}
+}
+
+func TestRegistered(t *testing.T) {
+	c, ok := apiver.GetCodec("cbor")
+	if !ok {
+		t.Fatalf("GetCodec cbor expected: exists, actual: missing")
+	}
+	if c.TagName() != "cbor" {
+		t.Errorf("GetCodec cbor TagName expected: cbor, actual: %+v", c.TagName())
+	}
+}
+
+func TestEncoderDecoder(t *testing.T) {
+	type Obj struct {
+		Foo int  `json:"foo" api:"1.1"`
+		A   *int `json:"a" api:"1.4"`
+	}
+	a := 24
+
+	buf := &bytes.Buffer{}
+	enc := NewEncoder(buf, 1.3)
+	for i := 0; i < 2; i++ {
+		if err := enc.Encode(Obj{Foo: 42 + i, A: &a}); err != nil {
+			t.Fatalf("Encoder.Encode error expected nil, actual %+v", err)
+		}
+	}
+
+	dec := NewDecoder(buf, 1.4)
+	for i := 0; i < 2; i++ {
+		obj := Obj{}
+		if err := dec.Decode(&obj); err != nil {
+			t.Fatalf("Decoder.Decode error expected nil, actual %+v", err)
+		}
+		if obj.Foo != 42+i {
+			t.Errorf("Decoder.Decode obj.Foo expected: %+v, actual: %+v", 42+i, obj.Foo)
+		}
+		if obj.A != nil {
+			t.Errorf("Decoder.Decode obj.A expected: nil, actual: %+v", *obj.A)
+		}
+	}
+}
+
+func TestUnmarshalWithOptions(t *testing.T) {
+	type Obj struct {
+		Foo int `json:"foo" api:"1.1"`
+		A   int `json:"a" api:"1.4"`
+	}
+
+	bts, err := cbor.Marshal(map[string]interface{}{"foo": 42, "a": 24})
+	if err != nil {
+		t.Fatalf("cbor.Marshal error expected nil, actual %+v", err)
+	}
+
+	obj := Obj{}
+	if err := UnmarshalWithOptions(bts, &obj, 1.3, apiver.Options{}); err != nil || obj.Foo != 42 {
+		t.Errorf("UnmarshalWithOptions expected: %+v, actual: %+v %+v", 42, obj.Foo, err)
+	}
+	if err := UnmarshalWithOptions(bts, &obj, 1.3, apiver.Options{DisallowUnknownFields: true}); err == nil {
+		t.Errorf("UnmarshalWithOptions newer field error expected: unknown field, actual: %+v", err)
+	}
+	if err := UnmarshalWithOptions(bts, &obj, 1.4, apiver.Options{DisallowUnknownFields: true}); err != nil || obj.A != 24 {
+		t.Errorf("UnmarshalWithOptions expected: %+v, actual: %+v %+v", 24, obj.A, err)
+	}
+
+	actual, err := MarshalWithOptions(Obj{Foo: 42, A: 24}, 1.3, apiver.Options{TagName: "since"})
+	if err != nil {
+		t.Fatalf("MarshalWithOptions error expected nil, actual %+v", err)
+	}
+	m := map[string]interface{}{}
+	if err := cbor.Unmarshal(actual, &m); err != nil || len(m) != 2 {
+		t.Errorf("MarshalWithOptions since tag expected: every field, actual: %+v %+v", m, err)
+	}
+}
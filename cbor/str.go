@@ -0,0 +1,51 @@
+package cbor
+
+import (
+	"github.com/fxamacker/cbor/v2"
+	"github.com/rob05c/apiver"
+)
+
+// IntS decodes a CBOR integer or text string as an integer. It's the CBOR equivalent of apiver.IntS.
+type IntS int64
+
+func (i *IntS) UnmarshalCBOR(d []byte) error {
+	v := interface{}(nil)
+	if err := cbor.Unmarshal(d, &v); err != nil {
+		return err
+	}
+	return apiver.UnmarshalStrValue(v, (*apiver.IntS)(i))
+}
+
+// UIntS decodes a CBOR unsigned integer or text string as an unsigned integer. It's the CBOR equivalent of apiver.UIntS.
+type UIntS uint64
+
+func (i *UIntS) UnmarshalCBOR(d []byte) error {
+	v := interface{}(nil)
+	if err := cbor.Unmarshal(d, &v); err != nil {
+		return err
+	}
+	return apiver.UnmarshalStrValue(v, (*apiver.UIntS)(i))
+}
+
+// FloatS decodes a CBOR number or text string as a number. It's the CBOR equivalent of apiver.FloatS.
+type FloatS float64
+
+func (i *FloatS) UnmarshalCBOR(d []byte) error {
+	v := interface{}(nil)
+	if err := cbor.Unmarshal(d, &v); err != nil {
+		return err
+	}
+	return apiver.UnmarshalStrValue(v, (*apiver.FloatS)(i))
+}
+
+// BoolS decodes a CBOR bool, number, or text string as a boolean. It's the CBOR equivalent of apiver.BoolS.
+// Numbers are false if they're zero, and strings are decoded like Perl: "" and "0" are false; all other values are true.
+type BoolS bool
+
+func (i *BoolS) UnmarshalCBOR(d []byte) error {
+	v := interface{}(nil)
+	if err := cbor.Unmarshal(d, &v); err != nil {
+		return err
+	}
+	return apiver.UnmarshalStrValue(v, (*apiver.BoolS)(i))
+}
@@ -0,0 +1,505 @@
+// Code generated by apivergen -type=Server,Cache; DO NOT EDIT.
+
+package gentest
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/rob05c/apiver"
+	"strconv"
+	"time"
+)
+
+var _ apiver.VersionMarshaler = Server{}
+var _ apiver.VersionUnmarshaler = (*Server)(nil)
+
+// MarshalJSONVersion returns the JSON of o at version, exactly like apiver.MarshalJSON without generated methods.
+func (o Server) MarshalJSONVersion(version float64) ([]byte, error) {
+	buf := []byte{'{'}
+	bts := []byte(nil)
+	err := error(nil)
+
+	// Name
+	if len(buf) > 1 {
+		buf = append(buf, ',')
+	}
+	buf = append(buf, "\"name\":"...)
+	if bts, err = json.Marshal(o.Name); err != nil {
+		return nil, err
+	}
+	buf = append(buf, bts...)
+
+	// Port
+	if version >= 1.1 {
+		if len(buf) > 1 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, "\"port\":"...)
+		buf = strconv.AppendInt(buf, int64(o.Port), 10)
+	}
+
+	// Weight
+	if version >= 1.2 {
+		if o.Weight != nil {
+			if len(buf) > 1 {
+				buf = append(buf, ',')
+			}
+			buf = append(buf, "\"weight\":"...)
+			if bts, err = json.Marshal(*o.Weight); err != nil {
+				return nil, err
+			}
+			buf = append(buf, bts...)
+		}
+	}
+
+	// Up
+	if version >= 1.1 {
+		if len(buf) > 1 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, "\"up\":"...)
+		buf = strconv.AppendBool(buf, o.Up)
+	}
+
+	// Status
+	if version >= 1.3 {
+		if len(buf) > 1 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, "\"status\":"...)
+		if o.Status == nil {
+			buf = append(buf, "null"...)
+		} else {
+			if bts, err = json.Marshal(*o.Status); err != nil {
+				return nil, err
+			}
+			buf = append(buf, bts...)
+		}
+	}
+
+	// Retries
+	if !(o.Retries == 0) {
+		if len(buf) > 1 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, "\"retries\":"...)
+		buf = strconv.AppendUint(buf, uint64(o.Retries), 10)
+	}
+
+	// Ratio
+	if version >= 1.2 {
+		if len(buf) > 1 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, "\"ratio\":"...)
+		if bts, err = json.Marshal(o.Ratio); err != nil {
+			return nil, err
+		}
+		buf = append(buf, bts...)
+	}
+
+	// Count
+	if version >= 1.1 {
+		if len(buf) > 1 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, "\"count\":"...)
+		if o.Count == nil {
+			buf = append(buf, "null"...)
+		} else {
+			buf = strconv.AppendInt(buf, int64(*o.Count), 10)
+		}
+	}
+
+	// Tags
+	if version >= 1.1 {
+		if bts, err = apiver.MarshalJSON(struct {
+			Tags []string `json:"tags" api:"1.1"`
+		}{o.Tags}, version); err != nil {
+			return nil, err
+		}
+		if len(bts) > 2 {
+			if len(buf) > 1 {
+				buf = append(buf, ',')
+			}
+			buf = append(buf, bts[1:len(bts)-1]...)
+		}
+	}
+
+	// Updated
+	if version >= 1.4 {
+		if bts, err = apiver.MarshalJSON(struct {
+			Updated time.Time `json:"updated" api:"1.4"`
+		}{o.Updated}, version); err != nil {
+			return nil, err
+		}
+		if len(bts) > 2 {
+			if len(buf) > 1 {
+				buf = append(buf, ',')
+			}
+			buf = append(buf, bts[1:len(bts)-1]...)
+		}
+	}
+
+	// Cache
+	if version >= 1.2 {
+		if bts, err = o.Cache.MarshalJSONVersion(version); err != nil {
+			return nil, err
+		}
+		if len(buf) > 1 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, "\"cache\":"...)
+		buf = append(buf, bts...)
+	}
+
+	// Parent
+	if bts, err = o.Parent.MarshalJSONVersion(version); err != nil {
+		return nil, err
+	}
+	if len(buf) > 1 {
+		buf = append(buf, ',')
+	}
+	buf = append(buf, "\"parent\":"...)
+	buf = append(buf, bts...)
+
+	// Caches
+	if bts, err = apiver.MarshalJSON(struct {
+		Caches []Cache `json:"caches,omitempty"`
+	}{o.Caches}, version); err != nil {
+		return nil, err
+	}
+	if len(bts) > 2 {
+		if len(buf) > 1 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, bts[1:len(bts)-1]...)
+	}
+
+	// Profile
+	if version >= 1.3 {
+		if bts, err = apiver.MarshalJSON(struct {
+			Profile *Cache `json:"profile" api:"1.3"`
+		}{o.Profile}, version); err != nil {
+			return nil, err
+		}
+		if len(bts) > 2 {
+			if len(buf) > 1 {
+				buf = append(buf, ',')
+			}
+			buf = append(buf, bts[1:len(bts)-1]...)
+		}
+	}
+
+	// Note
+	if version >= 1.1 {
+		if len(buf) > 1 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, "\"note\":"...)
+		if bts, err = json.Marshal(o.Note); err != nil {
+			return nil, err
+		}
+		buf = append(buf, bts...)
+	}
+
+	buf = append(buf, '}')
+	return buf, nil
+}
+
+// UnmarshalJSONVersion parses bts into o at version, exactly like apiver.UnmarshalJSON without generated methods.
+func (o *Server) UnmarshalJSONVersion(bts []byte, version float64) error {
+	fake := struct {
+		Name    string          `json:"name"`
+		Port    json.RawMessage `json:"port"`
+		Weight  json.RawMessage `json:"weight"`
+		Up      json.RawMessage `json:"up"`
+		Status  json.RawMessage `json:"status"`
+		Retries uint8           `json:"retries"`
+		Ratio   json.RawMessage `json:"ratio"`
+		Count   json.RawMessage `json:"count"`
+		Tags    json.RawMessage `json:"tags"`
+		Updated json.RawMessage `json:"updated"`
+		Cache   json.RawMessage `json:"cache"`
+		Parent  json.RawMessage `json:"parent"`
+		Caches  json.RawMessage `json:"caches"`
+		Profile json.RawMessage `json:"profile"`
+		Note    json.RawMessage `json:"note"`
+	}{}
+	if err := json.Unmarshal(bts, &fake); err != nil {
+		return err
+	}
+
+	// Name
+	o.Name = fake.Name
+
+	// Port
+	if version >= 1.1 {
+		if !(len(fake.Port) == 0 || string(fake.Port) == "null") {
+			val := apiver.IntS(0)
+			if err := json.Unmarshal(fake.Port, &val); err != nil {
+				return err
+			}
+			o.Port = int(val)
+		} else {
+			return apiver.UserError{Msg: "missing required field: port"}
+		}
+	}
+
+	// Weight
+	if version >= 1.2 {
+		if !(len(fake.Weight) == 0 || string(fake.Weight) == "null") {
+			val := float64(0)
+			if err := json.Unmarshal(fake.Weight, &val); err != nil {
+				return err
+			}
+			if o.Weight == nil {
+				o.Weight = new(float64)
+			}
+			*o.Weight = val
+		}
+	}
+
+	// Up
+	if version >= 1.1 {
+		if !(len(fake.Up) == 0 || string(fake.Up) == "null") {
+			val := apiver.BoolS(false)
+			if err := json.Unmarshal(fake.Up, &val); err != nil {
+				return err
+			}
+			o.Up = bool(val)
+		} else {
+			return apiver.UserError{Msg: "missing required field: up"}
+		}
+	}
+
+	// Status
+	if version >= 1.3 {
+		if !(len(fake.Status) == 0 || string(fake.Status) == "null") {
+			val := ""
+			if err := json.Unmarshal(fake.Status, &val); err != nil {
+				return err
+			}
+			if o.Status == nil {
+				o.Status = new(string)
+			}
+			*o.Status = val
+		}
+	}
+
+	// Retries
+	o.Retries = fake.Retries
+
+	// Ratio
+	if version >= 1.2 {
+		if !(len(fake.Ratio) == 0 || string(fake.Ratio) == "null") {
+			val := float32(0)
+			if err := json.Unmarshal(fake.Ratio, &val); err != nil {
+				return err
+			}
+			o.Ratio = val
+		} else {
+			return apiver.UserError{Msg: "missing required field: ratio"}
+		}
+	}
+
+	// Count
+	if version >= 1.1 {
+		if !(len(fake.Count) == 0 || string(fake.Count) == "null") {
+			val := apiver.IntS(0)
+			if err := json.Unmarshal(fake.Count, &val); err != nil {
+				return err
+			}
+			if o.Count == nil {
+				o.Count = new(int)
+			}
+			*o.Count = int(val)
+		}
+	}
+
+	// Tags
+	if version >= 1.1 {
+		{
+			field := struct {
+				Tags []string `json:"tags" api:"1.1"`
+			}{o.Tags}
+			doc := []byte("{}")
+			if len(fake.Tags) != 0 {
+				doc = append(append([]byte("{\"tags\":"), fake.Tags...), '}')
+			}
+			if err := apiver.UnmarshalJSON(doc, &field, version); err != nil {
+				return err
+			}
+			o.Tags = field.Tags
+		}
+	}
+
+	// Updated
+	if version >= 1.4 {
+		{
+			field := struct {
+				Updated time.Time `json:"updated" api:"1.4"`
+			}{o.Updated}
+			doc := []byte("{}")
+			if len(fake.Updated) != 0 {
+				doc = append(append([]byte("{\"updated\":"), fake.Updated...), '}')
+			}
+			if err := apiver.UnmarshalJSON(doc, &field, version); err != nil {
+				return err
+			}
+			o.Updated = field.Updated
+		}
+	}
+
+	// Cache
+	if version >= 1.2 {
+		if len(fake.Cache) == 0 || string(fake.Cache) == "null" {
+			return apiver.UserError{Msg: "missing required field: cache"}
+		}
+		if err := o.Cache.UnmarshalJSONVersion(fake.Cache, version); err != nil {
+			return errors.New("field 'cache':" + err.Error())
+		}
+	}
+
+	// Parent
+	if len(fake.Parent) == 0 || string(fake.Parent) == "null" {
+		fake.Parent = json.RawMessage("null")
+	}
+	if err := o.Parent.UnmarshalJSONVersion(fake.Parent, version); err != nil {
+		return errors.New("field 'parent':" + err.Error())
+	}
+
+	// Caches
+	{
+		field := struct {
+			Caches []Cache `json:"caches,omitempty"`
+		}{o.Caches}
+		doc := []byte("{}")
+		if len(fake.Caches) != 0 {
+			doc = append(append([]byte("{\"caches\":"), fake.Caches...), '}')
+		}
+		if err := apiver.UnmarshalJSON(doc, &field, version); err != nil {
+			return err
+		}
+		o.Caches = field.Caches
+	}
+
+	// Profile
+	if version >= 1.3 {
+		{
+			field := struct {
+				Profile *Cache `json:"profile" api:"1.3"`
+			}{o.Profile}
+			doc := []byte("{}")
+			if len(fake.Profile) != 0 {
+				doc = append(append([]byte("{\"profile\":"), fake.Profile...), '}')
+			}
+			if err := apiver.UnmarshalJSON(doc, &field, version); err != nil {
+				return err
+			}
+			o.Profile = field.Profile
+		}
+	}
+
+	// Note
+	if version >= 1.1 {
+		if !(len(fake.Note) == 0 || string(fake.Note) == "null") {
+			val := ""
+			if err := json.Unmarshal(fake.Note, &val); err != nil {
+				return err
+			}
+			o.Note = val
+		} else {
+			return apiver.UserError{Msg: "missing required field: note"}
+		}
+	}
+
+	return nil
+}
+
+var _ apiver.VersionMarshaler = Cache{}
+var _ apiver.VersionUnmarshaler = (*Cache)(nil)
+
+// MarshalJSONVersion returns the JSON of o at version, exactly like apiver.MarshalJSON without generated methods.
+func (o Cache) MarshalJSONVersion(version float64) ([]byte, error) {
+	buf := []byte{'{'}
+	bts := []byte(nil)
+	err := error(nil)
+
+	// Host
+	if version >= 1.1 {
+		if len(buf) > 1 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, "\"host\":"...)
+		if bts, err = json.Marshal(o.Host); err != nil {
+			return nil, err
+		}
+		buf = append(buf, bts...)
+	}
+
+	// TTL
+	if version >= 1.3 {
+		if o.TTL != nil {
+			if len(buf) > 1 {
+				buf = append(buf, ',')
+			}
+			buf = append(buf, "\"ttl\":"...)
+			buf = strconv.AppendInt(buf, int64(*o.TTL), 10)
+		}
+	}
+
+	// Primary
+	if len(buf) > 1 {
+		buf = append(buf, ',')
+	}
+	buf = append(buf, "\"primary\":"...)
+	buf = strconv.AppendBool(buf, o.Primary)
+
+	buf = append(buf, '}')
+	return buf, nil
+}
+
+// UnmarshalJSONVersion parses bts into o at version, exactly like apiver.UnmarshalJSON without generated methods.
+func (o *Cache) UnmarshalJSONVersion(bts []byte, version float64) error {
+	fake := struct {
+		Host    json.RawMessage `json:"host"`
+		TTL     json.RawMessage `json:"ttl"`
+		Primary bool            `json:"primary"`
+	}{}
+	if err := json.Unmarshal(bts, &fake); err != nil {
+		return err
+	}
+
+	// Host
+	if version >= 1.1 {
+		if !(len(fake.Host) == 0 || string(fake.Host) == "null") {
+			val := ""
+			if err := json.Unmarshal(fake.Host, &val); err != nil {
+				return err
+			}
+			o.Host = val
+		} else {
+			return apiver.UserError{Msg: "missing required field: host"}
+		}
+	}
+
+	// TTL
+	if version >= 1.3 {
+		if !(len(fake.TTL) == 0 || string(fake.TTL) == "null") {
+			val := apiver.IntS(0)
+			if err := json.Unmarshal(fake.TTL, &val); err != nil {
+				return err
+			}
+			if o.TTL == nil {
+				o.TTL = new(int64)
+			}
+			*o.TTL = int64(val)
+		}
+	}
+
+	// Primary
+	o.Primary = fake.Primary
+
+	return nil
+}
@@ -0,0 +1,34 @@
+// Package gentest has types with apivergen methods, to test the generated methods behave exactly like the reflection path.
+package gentest
+
+import (
+	"time"
+)
+
+//go:generate go run github.com/rob05c/apiver/cmd/apivergen -type=Server,Cache
+
+type Server struct {
+	Name     string    `json:"name"`
+	Port     int       `json:"port" api:"1.1,str"`
+	Weight   *float64  `json:"weight,omitempty" api:"1.2"`
+	Up       bool      `json:"up" api:"1.1,str"`
+	Status   *string   `json:"status" api:"1.3"`
+	Retries  uint8     `json:"retries,omitempty"`
+	Ratio    float32   `json:"ratio,omitempty" api:"1.2"`
+	Count    *int      `json:"count" api:"1.1,str"`
+	Tags     []string  `json:"tags" api:"1.1"`
+	Updated  time.Time `json:"updated" api:"1.4"`
+	Cache    Cache     `json:"cache" api:"1.2"`
+	Parent   Cache     `json:"parent"`
+	Caches   []Cache   `json:"caches,omitempty"`
+	Profile  *Cache    `json:"profile" api:"1.3"`
+	Note     string    `json:"note,omitempty" api:"1.1"`
+	Internal string    `json:"-"`
+	internal int
+}
+
+type Cache struct {
+	Host    string `json:"host" api:"1.1"`
+	TTL     *int64 `json:"ttl,omitempty" api:"1.3,str"`
+	Primary bool   `json:"primary"`
+}
@@ -0,0 +1,89 @@
+package gentest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rob05c/apiver"
+)
+
+var versions = []float64{1.0, 1.1, 1.2, 1.3, 1.4}
+
+func TestUnmarshalGeneratedMatchesReflection(t *testing.T) {
+	inputs := []string{
+		`{"name":"a","port":80,"up":true,"note":"n","parent":{"host":"p"}}`,
+		`{"name":"a","port":"80","up":"0","note":"n","count":"7","tags":["x","y"],"parent":{"host":"p","primary":true}}`,
+		`{"name":"a","port":80,"up":true,"note":"n","weight":0.5,"ratio":1.5,"cache":{"host":"c","ttl":"30"},"parent":{"host":"p","ttl":5}}`,
+		`{"name":"a","port":80,"up":true,"note":"n","ratio":0,"cache":{"host":"c"},"parent":{"host":"p"},"status":"ok","profile":{"host":"x"},"caches":[{"host":"y","ttl":1}]}`,
+		`{"name":"a","port":80,"up":true,"note":"n","ratio":0,"cache":{"host":"c"},"parent":{"host":"p"},"updated":"2020-01-02T03:04:05Z","retries":3}`,
+		`{"port":80,"up":true,"note":"n","ratio":0,"cache":{},"parent":{"host":"p"}}`,
+		`{"port":80,"up":true,"note":"n","parent":{}}`,
+		`{"port":80,"up":true,"note":"n"}`,
+		`{"port":null,"up":true,"note":"n","parent":{"host":"p"}}`,
+		`{"up":true,"note":"n","parent":{"host":"p"}}`,
+		`{"name":"a","port":80,"up":true,"note":"n","parent":{"host":"p"},"status":null,"count":null,"weight":null}`,
+		`{"name":"a","port":"eighty","up":true,"note":"n","parent":{"host":"p"}}`,
+		`{"name":5,"port":80,"up":true,"note":"n","parent":{"host":"p"}}`,
+		`[1,2]`,
+		`{"name":"a"`,
+	}
+	for _, input := range inputs {
+		for _, version := range versions {
+			expected := Server{Name: "old", Retries: 9}
+			expectedErr := apiver.UnmarshalCodec(apiver.JSONCodec, []byte(input), &expected, version)
+
+			actual := Server{Name: "old", Retries: 9}
+			actualErr := apiver.UnmarshalJSON([]byte(input), &actual, version)
+
+			if (expectedErr == nil) != (actualErr == nil) {
+				t.Errorf("UnmarshalJSON %+v %+v error expected: %+v, actual: %+v", input, version, expectedErr, actualErr)
+				continue
+			}
+			if _, ok := expectedErr.(apiver.UserError); ok && expectedErr.Error() != actualErr.Error() {
+				t.Errorf("UnmarshalJSON %+v %+v UserError expected: %+v, actual: %T %+v", input, version, expectedErr, actualErr, actualErr)
+			}
+			if expectedErr == nil && !reflect.DeepEqual(expected, actual) {
+				t.Errorf("UnmarshalJSON %+v %+v expected: %+v, actual: %+v", input, version, expected, actual)
+			}
+		}
+	}
+}
+
+func TestMarshalGeneratedMatchesReflection(t *testing.T) {
+	weight := 0.5
+	status := "<ok>"
+	count := 0
+	ttl := int64(30)
+	objs := []Server{
+		{},
+		{Name: "a", Port: 80, Up: true, Retries: 3, Ratio: 1.5, Note: "n", Internal: "secret"},
+		{Name: "a", Weight: &weight, Status: &status, Count: &count, Tags: []string{"x"}, Cache: Cache{Host: "c", TTL: &ttl}},
+		{Parent: Cache{Host: "p", Primary: true}, Caches: []Cache{{Host: "y", TTL: &ttl}}, Profile: &Cache{Host: "z", TTL: &ttl}},
+	}
+	for _, obj := range objs {
+		for _, version := range versions {
+			expected, err := apiver.MarshalCodec(apiver.JSONCodec, obj, version)
+			if err != nil {
+				t.Fatalf("MarshalCodec error expected: nil, actual: %+v", err)
+			}
+			actual, err := apiver.MarshalJSON(obj, version)
+			if err != nil {
+				t.Fatalf("MarshalJSON error expected: nil, actual: %+v", err)
+			}
+			if string(actual) != string(expected) {
+				t.Errorf("MarshalJSON %+v expected ''%+v'', actual ''%+v''", version, string(expected), string(actual))
+			}
+		}
+	}
+}
+
+func TestMarshalGeneratedNilPointer(t *testing.T) {
+	obj := (*Server)(nil)
+	actual, err := apiver.MarshalJSON(obj, 1.1)
+	if err != nil {
+		t.Fatalf("MarshalJSON error expected: nil, actual: %+v", err)
+	}
+	if string(actual) != "null" {
+		t.Errorf("MarshalJSON nil expected: null, actual: %+v", string(actual))
+	}
+}
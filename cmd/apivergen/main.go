@@ -0,0 +1,594 @@
+// Command apivergen generates reflection-free versioned JSON methods for structs with apiver tags.
+//
+// For each type, it generates MarshalJSONVersion and UnmarshalJSONVersion methods, which apiver.MarshalJSON, apiver.UnmarshalJSON, and the apiver JSON drop-ins call automatically, instead of building objects with reflection. The methods have the same semantics as the reflection path: fields newer than the version are omitted, 'str' fields accept strings, and missing required fields return the same apiver.UserError.
+//
+// Fields of builtin types, pointers to builtin types, and other generated types are encoded and decoded directly. Fields of any other type, such as slices, maps, and types from other packages, are delegated to the reflection path, one field at a time.
+//
+// Usage:
+//
+//  //go:generate go run github.com/rob05c/apiver/cmd/apivergen -type=Server,DeliveryService
+//
+// The generated file is named after the first type, e.g. server_apiver.go, in the package directory. Use -output to change it.
+//
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rob05c/apiver"
+)
+
+func main() {
+	typeNames := flag.String("type", "", "comma-separated list of struct type names; must be set")
+	output := flag.String("output", "", "output file name; default <dir>/<type>_apiver.go")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: apivergen -type T[,T...] [-output file] [dir]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *typeNames == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+	types := strings.Split(*typeNames, ",")
+
+	src, err := Generate(dir, types)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "apivergen: "+err.Error())
+		os.Exit(1)
+	}
+
+	outputName := *output
+	if outputName == "" {
+		outputName = filepath.Join(dir, strings.ToLower(types[0])+"_apiver.go")
+	}
+	if err := ioutil.WriteFile(outputName, src, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, "apivergen: writing output: "+err.Error())
+		os.Exit(1)
+	}
+}
+
+// Generate returns the formatted source of the generated methods for the given struct types, in the package in dir. Test files are ignored.
+func Generate(dir string, typeNames []string) ([]byte, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go") && !strings.HasSuffix(fi.Name(), "_apiver.go")
+	}, 0)
+	if err != nil {
+		return nil, errors.New("parsing package: " + err.Error())
+	}
+	if len(pkgs) != 1 {
+		return nil, errors.New("expected 1 package in '" + dir + "', found " + strconv.Itoa(len(pkgs)))
+	}
+
+	pkg := (*ast.Package)(nil)
+	for _, p := range pkgs {
+		pkg = p
+	}
+
+	g := &generator{
+		fset:      fset,
+		typeSpecs: map[string]*ast.TypeSpec{},
+		typeFiles: map[string]*ast.File{},
+		generated: map[string]bool{},
+		imports:   map[string]string{},
+	}
+	for _, file := range pkg.Files {
+		for _, decl := range file.Decls {
+			genDecl, ok := decl.(*ast.GenDecl)
+			if !ok || genDecl.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range genDecl.Specs {
+				typeSpec := spec.(*ast.TypeSpec)
+				g.typeSpecs[typeSpec.Name.Name] = typeSpec
+				g.typeFiles[typeSpec.Name.Name] = file
+			}
+		}
+	}
+	for _, name := range typeNames {
+		g.generated[name] = true
+	}
+
+	body := &bytes.Buffer{}
+	for _, name := range typeNames {
+		if err := g.generateType(body, name); err != nil {
+			return nil, errors.New("type '" + name + "': " + err.Error())
+		}
+	}
+
+	src := &bytes.Buffer{}
+	fmt.Fprintf(src, "// Code generated by apivergen -type=%s; DO NOT EDIT.\n\n", strings.Join(typeNames, ","))
+	fmt.Fprintf(src, "package %s\n\n", pkg.Name)
+	fmt.Fprintf(src, "import (\n")
+	for _, imp := range g.sortedImports() {
+		fmt.Fprintf(src, "\t%s\n", imp)
+	}
+	fmt.Fprintf(src, ")\n")
+	src.Write(body.Bytes())
+
+	formatted, err := format.Source(src.Bytes())
+	if err != nil {
+		return nil, errors.New("formatting generated source: " + err.Error() + "\n" + src.String())
+	}
+	return formatted, nil
+}
+
+type generator struct {
+	fset *token.FileSet
+	// typeSpecs and typeFiles are every type declared in the package, and the file it's declared in, by name.
+	typeSpecs map[string]*ast.TypeSpec
+	typeFiles map[string]*ast.File
+	// generated is the names of the types methods are being generated for.
+	generated map[string]bool
+	// imports is the import path of each package name used by the generated code.
+	imports map[string]string
+}
+
+// fieldKind is how a field is encoded and decoded.
+type fieldKind int
+
+const (
+	// fieldBasic is a builtin type, such as int or string.
+	fieldBasic fieldKind = iota
+	// fieldBasicPtr is a pointer to a builtin type.
+	fieldBasicPtr
+	// fieldGenerated is a type methods are being generated for.
+	fieldGenerated
+	// fieldOther is any other type, which is delegated to the reflection path.
+	fieldOther
+)
+
+type field struct {
+	Name     string
+	JSONName string
+	// JSONKey is the JSON of the name, with the colon, e.g. "foo":
+	JSONKey   string
+	OmitEmpty bool
+	Props     apiver.TagProperties
+	Kind      fieldKind
+	// Type is the field's type expression. For fieldBasicPtr, it's the type pointed to.
+	Type string
+	// Tag is the field's tag, as a Go literal.
+	Tag string
+}
+
+// versioned returns whether the field has a version, and is therefore required if it isn't a pointer.
+func (f field) versioned() bool { return f.Props.Version != 0 }
+
+// version returns the field's version as a Go literal.
+func (f field) version() string { return strconv.FormatFloat(f.Props.Version, 'g', -1, 64) }
+
+func (g *generator) generateType(w *bytes.Buffer, name string) error {
+	typeSpec, ok := g.typeSpecs[name]
+	if !ok {
+		return errors.New("not found")
+	}
+	structType, ok := typeSpec.Type.(*ast.StructType)
+	if !ok {
+		return errors.New("not a struct")
+	}
+	if typeSpec.TypeParams != nil {
+		return errors.New("generic types are not supported")
+	}
+
+	fields := []field{}
+	names := map[string]bool{}
+	for _, astField := range structType.Fields.List {
+		if len(astField.Names) == 0 {
+			return errors.New("embedded fields are not supported")
+		}
+		for _, ident := range astField.Names {
+			if !ident.IsExported() {
+				continue // encoding/json ignores unexported fields
+			}
+			f, skip, err := g.parseField(ident.Name, astField, g.typeFiles[name])
+			if err != nil {
+				return errors.New("field '" + ident.Name + "': " + err.Error())
+			}
+			if skip {
+				continue
+			}
+			if names[strings.ToLower(f.JSONName)] {
+				return errors.New("field '" + ident.Name + "': duplicate json name '" + f.JSONName + "'")
+			}
+			names[strings.ToLower(f.JSONName)] = true
+			fields = append(fields, f)
+		}
+	}
+
+	g.imports["json"] = "encoding/json"
+	g.imports["apiver"] = "github.com/rob05c/apiver"
+	fmt.Fprintf(w, "\nvar _ apiver.VersionMarshaler = %s{}\nvar _ apiver.VersionUnmarshaler = (*%s)(nil)\n", name, name)
+	g.generateMarshal(w, name, fields)
+	g.generateUnmarshal(w, name, fields)
+	return nil
+}
+
+// parseField returns the field with the given name, and whether it isn't encoded at all.
+func (g *generator) parseField(name string, astField *ast.Field, file *ast.File) (field, bool, error) {
+	tag := reflect.StructTag("")
+	tagLit := "``"
+	if astField.Tag != nil {
+		tagLit = astField.Tag.Value
+		unquoted, err := strconv.Unquote(tagLit)
+		if err != nil {
+			return field{}, false, errors.New("malformed tag: " + err.Error())
+		}
+		tag = reflect.StructTag(unquoted)
+	}
+
+	f := field{Name: name, JSONName: name, Tag: tagLit}
+	if jsonTag, ok := tag.Lookup("json"); ok {
+		parts := strings.Split(jsonTag, ",")
+		if parts[0] == "-" && len(parts) == 1 {
+			return field{}, true, nil
+		}
+		if parts[0] != "" {
+			f.JSONName = parts[0]
+		}
+		for _, opt := range parts[1:] {
+			switch opt {
+			case "omitempty":
+				f.OmitEmpty = true
+			case "string":
+				return field{}, false, errors.New("the json ',string' option is not supported")
+			}
+		}
+	}
+	keyBts, err := json.Marshal(f.JSONName)
+	if err != nil {
+		return field{}, false, err
+	}
+	f.JSONKey = string(keyBts) + ":"
+	props, err := apiver.ParseTagProperties(tag.Get(apiver.TagName))
+	if err != nil {
+		return field{}, false, err
+	}
+	f.Props = props
+	if f.Props.Required || f.Props.Optional {
+		return field{}, false, errors.New("the api 'required' and 'optional' properties are not supported")
+	}
+
+	switch typ := astField.Type.(type) {
+	case *ast.Ident:
+		if basicKind(typ.Name) != "" {
+			f.Kind = fieldBasic
+			f.Type = typ.Name
+			return f, false, nil
+		}
+		if g.generated[typ.Name] {
+			f.Kind = fieldGenerated
+			f.Type = typ.Name
+			return f, false, nil
+		}
+	case *ast.StarExpr:
+		if ident, ok := typ.X.(*ast.Ident); ok && basicKind(ident.Name) != "" {
+			f.Kind = fieldBasicPtr
+			f.Type = ident.Name
+			return f, false, nil
+		}
+	}
+
+	f.Kind = fieldOther
+	if err := g.addImports(astField.Type, file); err != nil {
+		return field{}, false, err
+	}
+	typeSrc := &bytes.Buffer{}
+	if err := printer.Fprint(typeSrc, g.fset, astField.Type); err != nil {
+		return field{}, false, err
+	}
+	f.Type = typeSrc.String()
+	return f, false, nil
+}
+
+// addImports adds the imports of the packages referenced by typ, from the imports of the file it's declared in.
+func (g *generator) addImports(typ ast.Expr, file *ast.File) error {
+	err := error(nil)
+	ast.Inspect(typ, func(node ast.Node) bool {
+		sel, ok := node.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		pkgIdent, ok := sel.X.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		for _, imp := range file.Imports {
+			path, _ := strconv.Unquote(imp.Path.Value)
+			if importName(imp, path) == pkgIdent.Name {
+				g.imports[pkgIdent.Name] = path
+				return false
+			}
+		}
+		err = errors.New("package '" + pkgIdent.Name + "' not imported")
+		return false
+	})
+	return err
+}
+
+// importName returns the name imp is referenced by. Unnamed imports are assumed to be named after the last element of their path, without any major version suffix like '/v2' or '.v3', or 'go-' prefix or '-go' suffix.
+func importName(imp *ast.ImportSpec, path string) string {
+	if imp.Name != nil {
+		return imp.Name.Name
+	}
+	parts := strings.Split(path, "/")
+	name := parts[len(parts)-1]
+	if len(parts) > 1 && isMajorVersion(name) {
+		name = parts[len(parts)-2]
+	}
+	if i := strings.Index(name, ".v"); i > 0 {
+		name = name[:i]
+	}
+	name = strings.TrimSuffix(strings.TrimPrefix(name, "go-"), "-go")
+	return strings.Replace(name, "-", "_", -1)
+}
+
+func isMajorVersion(s string) bool {
+	if len(s) < 2 || s[0] != 'v' {
+		return false
+	}
+	_, err := strconv.Atoi(s[1:])
+	return err == nil
+}
+
+func (g *generator) sortedImports() []string {
+	imps := []string{}
+	for name, path := range g.imports {
+		if importName(&ast.ImportSpec{}, path) == name {
+			imps = append(imps, strconv.Quote(path))
+		} else {
+			imps = append(imps, name+" "+strconv.Quote(path))
+		}
+	}
+	sort.Strings(imps)
+	return imps
+}
+
+// basicKind returns the kind of a builtin type name: "int", "uint", "float", "bool", or "string". Returns the empty string for any other name.
+func basicKind(name string) string {
+	switch name {
+	case "int", "int8", "int16", "int32", "int64", "rune":
+		return "int"
+	case "uint", "uint8", "uint16", "uint32", "uint64", "uintptr", "byte":
+		return "uint"
+	case "float32", "float64":
+		return "float"
+	case "bool":
+		return "bool"
+	case "string":
+		return "string"
+	default:
+		return ""
+	}
+}
+
+// strType returns the apiver str type for the builtin type name, or the empty string if it has none.
+func strType(name string) string {
+	switch basicKind(name) {
+	case "int":
+		return "apiver.IntS"
+	case "uint":
+		return "apiver.UIntS"
+	case "float":
+		return "apiver.FloatS"
+	case "bool":
+		return "apiver.BoolS"
+	default:
+		return ""
+	}
+}
+
+// decodeType returns the type to decode a basic field into: its str type if it has the str property and one exists, else its own type.
+func decodeType(f field) string {
+	if f.Props.Str {
+		if typ := strType(f.Type); typ != "" {
+			return typ
+		}
+	}
+	return f.Type
+}
+
+// convert returns expr converted from the decode type of f to the type of f, if they're different.
+func convert(f field, expr string) string {
+	if decodeType(f) == f.Type {
+		return expr
+	}
+	return f.Type + "(" + expr + ")"
+}
+
+// zeroValue returns the zero value of the type a versioned basic field is decoded into, as a Go expression.
+func zeroValue(f field) string {
+	switch basicKind(f.Type) {
+	case "string":
+		return `""`
+	case "bool":
+		return decodeType(f) + "(false)"
+	default:
+		return decodeType(f) + "(0)"
+	}
+}
+
+// appendBasic returns the code appending the JSON of the basic value expr of type typ to buf.
+func appendBasic(expr string, typ string) string {
+	switch basicKind(typ) {
+	case "int":
+		return "buf = strconv.AppendInt(buf, int64(" + expr + "), 10)\n"
+	case "uint":
+		return "buf = strconv.AppendUint(buf, uint64(" + expr + "), 10)\n"
+	case "bool":
+		return "buf = strconv.AppendBool(buf, " + expr + ")\n"
+	default:
+		return "if bts, err = json.Marshal(" + expr + "); err != nil {\nreturn nil, err\n}\nbuf = append(buf, bts...)\n"
+	}
+}
+
+// emptyCheck returns the expression for whether the basic value expr of type typ is empty, per encoding/json omitempty.
+func emptyCheck(expr string, typ string) string {
+	switch basicKind(typ) {
+	case "bool":
+		return "!" + expr
+	case "string":
+		return expr + ` == ""`
+	default:
+		return expr + " == 0"
+	}
+}
+
+func (g *generator) generateMarshal(w *bytes.Buffer, name string, fields []field) {
+	for _, f := range fields {
+		if f.Kind == fieldBasic || f.Kind == fieldBasicPtr {
+			if basicKind(f.Type) != "string" && basicKind(f.Type) != "float" {
+				g.imports["strconv"] = "strconv"
+			}
+		}
+	}
+
+	fmt.Fprintf(w, "\n// MarshalJSONVersion returns the JSON of o at version, exactly like apiver.MarshalJSON without generated methods.\n")
+	fmt.Fprintf(w, "func (o %s) MarshalJSONVersion(version float64) ([]byte, error) {\n", name)
+	fmt.Fprintf(w, "buf := []byte{'{'}\n")
+	for _, f := range fields {
+		if f.Kind == fieldOther || f.Kind == fieldGenerated || basicKind(f.Type) == "string" || basicKind(f.Type) == "float" {
+			fmt.Fprintf(w, "bts := []byte(nil)\nerr := error(nil)\n")
+			break
+		}
+	}
+	for _, f := range fields {
+		fmt.Fprintf(w, "\n// %s\n", f.Name)
+		if f.versioned() {
+			fmt.Fprintf(w, "if version >= %s {\n", f.version())
+		}
+		switch f.Kind {
+		case fieldBasic:
+			// Versioned fields are pointers in the reflection path's built object, and thus never empty.
+			omit := f.OmitEmpty && !f.versioned()
+			if omit {
+				fmt.Fprintf(w, "if !(%s) {\n", emptyCheck("o."+f.Name, f.Type))
+			}
+			g.writeKey(w, f)
+			fmt.Fprint(w, appendBasic("o."+f.Name, f.Type))
+			if omit {
+				fmt.Fprintf(w, "}\n")
+			}
+		case fieldBasicPtr:
+			if f.OmitEmpty {
+				fmt.Fprintf(w, "if o.%s != nil {\n", f.Name)
+				g.writeKey(w, f)
+				fmt.Fprint(w, appendBasic("*o."+f.Name, f.Type))
+				fmt.Fprintf(w, "}\n")
+			} else {
+				g.writeKey(w, f)
+				fmt.Fprintf(w, "if o.%s == nil {\nbuf = append(buf, \"null\"...)\n} else {\n", f.Name)
+				fmt.Fprint(w, appendBasic("*o."+f.Name, f.Type))
+				fmt.Fprintf(w, "}\n")
+			}
+		case fieldGenerated:
+			fmt.Fprintf(w, "if bts, err = o.%s.MarshalJSONVersion(version); err != nil {\nreturn nil, err\n}\n", f.Name)
+			g.writeKey(w, f)
+			fmt.Fprintf(w, "buf = append(buf, bts...)\n")
+		case fieldOther:
+			fmt.Fprintf(w, "if bts, err = apiver.MarshalJSON(struct {\n%s %s %s\n}{o.%s}, version); err != nil {\nreturn nil, err\n}\n", f.Name, f.Type, f.Tag, f.Name)
+			fmt.Fprintf(w, "if len(bts) > 2 {\n")
+			fmt.Fprintf(w, "if len(buf) > 1 {\nbuf = append(buf, ',')\n}\n")
+			fmt.Fprintf(w, "buf = append(buf, bts[1:len(bts)-1]...)\n")
+			fmt.Fprintf(w, "}\n")
+		}
+		if f.versioned() {
+			fmt.Fprintf(w, "}\n")
+		}
+	}
+	fmt.Fprintf(w, "\nbuf = append(buf, '}')\nreturn buf, nil\n}\n")
+}
+
+// writeKey writes the code appending a comma if necessary, and the field's key.
+func (g *generator) writeKey(w *bytes.Buffer, f field) {
+	fmt.Fprintf(w, "if len(buf) > 1 {\nbuf = append(buf, ',')\n}\n")
+	fmt.Fprintf(w, "buf = append(buf, %s...)\n", strconv.Quote(f.JSONKey))
+}
+
+func (g *generator) generateUnmarshal(w *bytes.Buffer, name string, fields []field) {
+	fmt.Fprintf(w, "\n// UnmarshalJSONVersion parses bts into o at version, exactly like apiver.UnmarshalJSON without generated methods.\n")
+	fmt.Fprintf(w, "func (o *%s) UnmarshalJSONVersion(bts []byte, version float64) error {\n", name)
+
+	// Unversioned basic fields are decoded directly. Versioned, generated, and other fields are decoded from raw JSON, so fields newer than version are never decoded, missing fields can be detected, and the reflection path only decodes its own field's value.
+	fmt.Fprintf(w, "fake := struct {\n")
+	for _, f := range fields {
+		jsonTag := "`json:" + strconv.Quote(f.JSONName) + "`"
+		switch {
+		case f.Kind == fieldOther || f.Kind == fieldGenerated || f.versioned():
+			fmt.Fprintf(w, "%s json.RawMessage %s\n", f.Name, jsonTag)
+		case f.Kind == fieldBasicPtr:
+			fmt.Fprintf(w, "%s *%s %s\n", f.Name, decodeType(f), jsonTag)
+		default:
+			fmt.Fprintf(w, "%s %s %s\n", f.Name, decodeType(f), jsonTag)
+		}
+	}
+	fmt.Fprintf(w, "}{}\n")
+	fmt.Fprintf(w, "if err := json.Unmarshal(bts, &fake); err != nil {\nreturn err\n}\n")
+
+	for _, f := range fields {
+		fmt.Fprintf(w, "\n// %s\n", f.Name)
+		if f.versioned() {
+			fmt.Fprintf(w, "if version >= %s {\n", f.version())
+		}
+		switch {
+		case f.Kind == fieldOther:
+			// The reflection path decodes an object of only this field, so it checks and names the field exactly like the whole object.
+			fmt.Fprintf(w, "{\nfield := struct {\n%s %s %s\n}{o.%s}\n", f.Name, f.Type, f.Tag, f.Name)
+			fmt.Fprintf(w, "doc := []byte(\"{}\")\n")
+			fmt.Fprintf(w, "if len(fake.%s) != 0 {\ndoc = append(append([]byte(%s), fake.%s...), '}')\n}\n", f.Name, strconv.Quote("{"+f.JSONKey), f.Name)
+			fmt.Fprintf(w, "if err := apiver.UnmarshalJSON(doc, &field, version); err != nil {\nreturn err\n}\n")
+			fmt.Fprintf(w, "o.%s = field.%s\n}\n", f.Name, f.Name)
+		case f.Kind == fieldGenerated:
+			missing := "len(fake." + f.Name + `) == 0 || string(fake.` + f.Name + `) == "null"`
+			if f.versioned() {
+				fmt.Fprintf(w, "if %s {\nreturn apiver.UserError{Msg: %s}\n}\n", missing, strconv.Quote("missing required field: "+f.JSONName))
+			} else {
+				fmt.Fprintf(w, "if %s {\nfake.%s = json.RawMessage(\"null\")\n}\n", missing, f.Name)
+			}
+			fmt.Fprintf(w, "if err := o.%s.UnmarshalJSONVersion(fake.%s, version); err != nil {\nreturn errors.New(%s + err.Error())\n}\n", f.Name, f.Name, strconv.Quote("field '"+f.JSONName+"':"))
+			g.imports["errors"] = "errors"
+		case f.versioned():
+			missing := "len(fake." + f.Name + `) == 0 || string(fake.` + f.Name + `) == "null"`
+			fmt.Fprintf(w, "if !(%s) {\n", missing)
+			fmt.Fprintf(w, "val := %s\n", zeroValue(f))
+			fmt.Fprintf(w, "if err := json.Unmarshal(fake.%s, &val); err != nil {\nreturn err\n}\n", f.Name)
+			if f.Kind == fieldBasicPtr {
+				fmt.Fprintf(w, "if o.%s == nil {\no.%s = new(%s)\n}\n*o.%s = %s\n", f.Name, f.Name, f.Type, f.Name, convert(f, "val"))
+				fmt.Fprintf(w, "}\n")
+			} else {
+				fmt.Fprintf(w, "o.%s = %s\n", f.Name, convert(f, "val"))
+				fmt.Fprintf(w, "} else {\nreturn apiver.UserError{Msg: %s}\n}\n", strconv.Quote("missing required field: "+f.JSONName))
+			}
+		case f.Kind == fieldBasicPtr:
+			fmt.Fprintf(w, "if fake.%s != nil {\nif o.%s == nil {\no.%s = new(%s)\n}\n*o.%s = %s\n}\n", f.Name, f.Name, f.Name, f.Type, f.Name, convert(f, "*fake."+f.Name))
+		default:
+			fmt.Fprintf(w, "o.%s = %s\n", f.Name, convert(f, "fake."+f.Name))
+		}
+		if f.versioned() {
+			fmt.Fprintf(w, "}\n")
+		}
+	}
+	fmt.Fprintf(w, "\nreturn nil\n}\n")
+}
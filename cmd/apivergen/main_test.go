@@ -0,0 +1,69 @@
+package main
+
+import (
+	"go/ast"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateGolden(t *testing.T) {
+	actual, err := Generate(filepath.Join("internal", "gentest"), []string{"Server", "Cache"})
+	if err != nil {
+		t.Fatalf("Generate error expected: nil, actual: %+v", err)
+	}
+	expected, err := ioutil.ReadFile(filepath.Join("internal", "gentest", "server_apiver.go"))
+	if err != nil {
+		t.Fatalf("reading generated file error expected: nil, actual: %+v", err)
+	}
+	if string(actual) != string(expected) {
+		t.Errorf("Generate expected internal/gentest/server_apiver.go, actual differs; run go generate ./...\n%s", string(actual))
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	tests := map[string]string{
+		"type Obj struct {\n\tInner\n}\ntype Inner struct{}\n":               "embedded fields are not supported",
+		"type Obj struct {\n\tA int `json:\"a,string\"`\n}\n":                "the json ',string' option is not supported",
+		"type Obj struct {\n\tA int `json:\"a\" api:\"required\"`\n}\n":      "the api 'required' and 'optional' properties are not supported",
+		"type Obj struct {\n\tA int `json:\"a\" api:\"1,4\"`\n}\n":           "multiple versions '1' and '4'",
+		"type Obj struct {\n\tA int `json:\"a\"`\n\tB int `json:\"A\"`\n}\n": "duplicate json name 'A'",
+		"type Obj int\n":        "not a struct",
+		"type Other struct{}\n": "not found",
+		"type Obj struct {\n\tA missing.Type `json:\"a\"`\n}\n": "package 'missing' not imported",
+	}
+	for src, expected := range tests {
+		dir, err := ioutil.TempDir("", "apivergen")
+		if err != nil {
+			t.Fatalf("TempDir error expected: nil, actual: %+v", err)
+		}
+		defer os.RemoveAll(dir)
+		if err := ioutil.WriteFile(filepath.Join(dir, "obj.go"), []byte("package obj\n\n"+src), 0644); err != nil {
+			t.Fatalf("WriteFile error expected: nil, actual: %+v", err)
+		}
+
+		_, err = Generate(dir, []string{"Obj"})
+		if err == nil || !strings.HasSuffix(err.Error(), expected) {
+			t.Errorf("Generate %+v error expected: '%+v', actual: %+v", src, expected, err)
+		}
+	}
+}
+
+func TestImportName(t *testing.T) {
+	tests := map[string]string{
+		"time":                         "time",
+		"encoding/json":                "json",
+		"gopkg.in/yaml.v3":             "yaml",
+		"github.com/fxamacker/cbor/v2": "cbor",
+		"github.com/foo/go-bar":        "bar",
+		"github.com/foo/bar-go":        "bar",
+		"github.com/foo/bar-baz":       "bar_baz",
+	}
+	for path, expected := range tests {
+		if actual := importName(&ast.ImportSpec{}, path); actual != expected {
+			t.Errorf("importName %+v expected: %+v, actual: %+v", path, expected, actual)
+		}
+	}
+}
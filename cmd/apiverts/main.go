@@ -0,0 +1,516 @@
+// Command apiverts generates TypeScript declarations of the JSON of structs with apiver tags, at one or more versions.
+//
+// Each type is an interface with only the fields which exist at the version. Embedded structs are properties named by their type, as apiver marshals them, unless the type has no versioned fields, in which case it's encoded verbatim, and its interface extends them, like encoding/json. Fields apiver requires, which are versioned fields which aren't pointers and fields with the required property, are required properties, and all other fields are optional. Pointers may be null, and str fields are a union of their type and string, such as `number | string`. Fields with the deprecated property are marked @deprecated.
+//
+// Struct types in the package referenced by the given types are generated too. Types from other packages are unknown, except time.Time, which is a string. Schemas registered with apiver.RegisterSchema aren't known, because only the source is parsed.
+//
+// Usage:
+//
+//	go run github.com/rob05c/apiver/cmd/apiverts -type=Server,DeliveryService -version=1.3 -output=types.d.ts
+//
+// With one version, the interfaces are at the top level. With a comma-separated list of versions, such as -version=1.1,1.2,1.3, each version's interfaces are in a namespace, such as V1_2.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/rob05c/apiver"
+)
+
+func main() {
+	typeNames := flag.String("type", "", "comma-separated list of struct type names; must be set")
+	versionList := flag.String("version", "", "comma-separated list of versions; must be set")
+	output := flag.String("output", "", "output file name; default standard output")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: apiverts -type T[,T...] -version V[,V...] [-output file] [dir]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *typeNames == "" || *versionList == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	versions := []float64{}
+	for _, v := range strings.Split(*versionList, ",") {
+		version, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "apiverts: malformed version '"+v+"'")
+			os.Exit(2)
+		}
+		versions = append(versions, version)
+	}
+
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+
+	src, err := Generate(dir, strings.Split(*typeNames, ","), versions)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "apiverts: "+err.Error())
+		os.Exit(1)
+	}
+
+	if *output == "" {
+		os.Stdout.Write(src)
+		return
+	}
+	if err := ioutil.WriteFile(*output, src, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, "apiverts: writing output: "+err.Error())
+		os.Exit(1)
+	}
+}
+
+// Generate returns the TypeScript declarations of the given struct types, in the package in dir, at each version. With more than one version, each version is in a namespace. Test files are ignored.
+func Generate(dir string, typeNames []string, versions []float64) ([]byte, error) {
+	if len(versions) == 0 {
+		return nil, errors.New("no versions")
+	}
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return nil, errors.New("parsing package: " + err.Error())
+	}
+	if len(pkgs) != 1 {
+		return nil, errors.New("expected 1 package in '" + dir + "', found " + strconv.Itoa(len(pkgs)))
+	}
+
+	g := &generator{typeSpecs: map[string]*ast.TypeSpec{}, typeFiles: map[string]*ast.File{}, methods: map[string]map[string]bool{}}
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				if funcDecl, ok := decl.(*ast.FuncDecl); ok && funcDecl.Recv != nil && len(funcDecl.Recv.List) == 1 {
+					if recv := embeddedStruct(funcDecl.Recv.List[0]); recv != "" {
+						if g.methods[recv] == nil {
+							g.methods[recv] = map[string]bool{}
+						}
+						g.methods[recv][funcDecl.Name.Name] = true
+					}
+					continue
+				}
+				genDecl, ok := decl.(*ast.GenDecl)
+				if !ok || genDecl.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range genDecl.Specs {
+					typeSpec := spec.(*ast.TypeSpec)
+					g.typeSpecs[typeSpec.Name.Name] = typeSpec
+					g.typeFiles[typeSpec.Name.Name] = file
+				}
+			}
+		}
+	}
+
+	versionStrs := []string{}
+	for _, version := range versions {
+		versionStrs = append(versionStrs, strconv.FormatFloat(version, 'f', -1, 64))
+	}
+	w := &bytes.Buffer{}
+	fmt.Fprintf(w, "// Code generated by apiverts -type=%s -version=%s; DO NOT EDIT.\n", strings.Join(typeNames, ","), strings.Join(versionStrs, ","))
+	for i, version := range versions {
+		body := &bytes.Buffer{}
+		if err := g.generateVersion(body, typeNames, version); err != nil {
+			return nil, err
+		}
+		if len(versions) == 1 {
+			w.Write(body.Bytes())
+			break
+		}
+		fmt.Fprintf(w, "\nexport namespace %s {\n", namespaceName(versionStrs[i]))
+		for _, line := range strings.SplitAfter(strings.TrimPrefix(body.String(), "\n"), "\n") {
+			if line != "\n" && line != "" {
+				line = "\t" + line
+			}
+			w.WriteString(line)
+		}
+		fmt.Fprintf(w, "}\n")
+	}
+	return w.Bytes(), nil
+}
+
+// namespaceName returns the TypeScript namespace name of the version, such as V1_2 for 1.2.
+func namespaceName(version string) string {
+	return "V" + strings.Replace(version, ".", "_", -1)
+}
+
+type generator struct {
+	// typeSpecs and typeFiles are every type declared in the package, and the file it's declared in, by name.
+	typeSpecs map[string]*ast.TypeSpec
+	typeFiles map[string]*ast.File
+	// methods is the names of the methods of each type in the package.
+	methods map[string]map[string]bool
+
+	// version, queued, and queue are the version being generated, and the struct types to generate, in order.
+	version float64
+	queued  map[string]bool
+	queue   []string
+}
+
+// generateVersion writes the interfaces of typeNames at version, and of the struct types in the package they reference.
+func (g *generator) generateVersion(w *bytes.Buffer, typeNames []string, version float64) error {
+	g.version = version
+	g.queued = map[string]bool{}
+	g.queue = nil
+	for _, name := range typeNames {
+		spec, ok := g.typeSpecs[name]
+		if !ok {
+			return errors.New("type '" + name + "': not found")
+		}
+		if _, ok := spec.Type.(*ast.StructType); !ok {
+			return errors.New("type '" + name + "': not a struct")
+		}
+		g.enqueue(name)
+	}
+	for i := 0; i < len(g.queue); i++ {
+		name := g.queue[i]
+		if err := g.generateInterface(w, name); err != nil {
+			return errors.New("type '" + name + "': " + err.Error())
+		}
+	}
+	return nil
+}
+
+func (g *generator) enqueue(name string) {
+	if !g.queued[name] {
+		g.queued[name] = true
+		g.queue = append(g.queue, name)
+	}
+}
+
+func (g *generator) generateInterface(w *bytes.Buffer, name string) error {
+	spec := g.typeSpecs[name]
+	if spec.TypeParams != nil {
+		return errors.New("generic types are not supported")
+	}
+	structType := spec.Type.(*ast.StructType)
+
+	extends := []string{}
+	for _, astField := range structType.Fields.List {
+		if len(astField.Names) != 0 || !g.verbatim(structType) {
+			continue
+		}
+		if g.flattened(astField) {
+			ident := embeddedStruct(astField)
+			g.enqueue(ident)
+			extends = append(extends, ident)
+		}
+	}
+
+	fmt.Fprintf(w, "\nexport interface %s ", name)
+	if len(extends) > 0 {
+		fmt.Fprintf(w, "extends %s ", strings.Join(extends, ", "))
+	}
+	body, err := g.structBody(structType, name, "")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "%s\n", body)
+	return nil
+}
+
+// flattened returns whether encoding/json flattens the embedded field astField into a verbatim struct: if it's a struct type without a json name, which doesn't have its own MarshalJSON method. Interfaces extend the types of flattened fields.
+func (g *generator) flattened(astField *ast.Field) bool {
+	ident := embeddedStruct(astField)
+	return ident != "" && g.isStruct(ident) && jsonName(astField) == "" && !g.methods[ident]["MarshalJSON"]
+}
+
+// propertyName matches names which don't need quotes as TypeScript properties.
+var propertyName = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// structBody returns the TypeScript object type of the fields of structType which exist at the generator's version, indented by indent. If structType is encoded verbatim, embedded fields of struct types without json names are skipped, because they're extended.
+func (g *generator) structBody(structType *ast.StructType, typeName string, indent string) (string, error) {
+	verbatim := g.verbatim(structType)
+	w := &bytes.Buffer{}
+	w.WriteString("{\n")
+	for _, astField := range structType.Fields.List {
+		tag := reflect.StructTag("")
+		if astField.Tag != nil {
+			unquoted, err := strconv.Unquote(astField.Tag.Value)
+			if err != nil {
+				return "", errors.New("malformed tag: " + err.Error())
+			}
+			tag = reflect.StructTag(unquoted)
+		}
+		props, err := apiver.ParseTagProperties(tag.Get(apiver.TagName))
+		if err != nil {
+			return "", err
+		}
+		if props.Version > g.version {
+			continue
+		}
+
+		names := []string{}
+		for _, ident := range astField.Names {
+			if ident.IsExported() {
+				names = append(names, ident.Name)
+			}
+		}
+		if len(astField.Names) == 0 {
+			ident := embeddedStruct(astField)
+			if ident == "" || (verbatim && g.flattened(astField)) || !ast.IsExported(ident) {
+				continue // extended, or not encoded
+			}
+			names = append(names, ident)
+		}
+
+		jsonTag, quoted, skip := parseJSONTag(tag)
+		if skip {
+			continue
+		}
+		typ, err := g.fieldType(astField.Type, props, quoted, typeName, indent+"\t")
+		if err != nil {
+			return "", err
+		}
+		_, isPtr := astField.Type.(*ast.StarExpr)
+		optional := "?"
+		if !isPtr && (props.Required || (props.Version != 0 && !props.Optional)) {
+			optional = ""
+		}
+
+		for _, name := range names {
+			if jsonTag != "" {
+				name = jsonTag
+			}
+			if !propertyName.MatchString(name) {
+				name = strconv.Quote(name)
+			}
+			if props.Deprecated {
+				fmt.Fprintf(w, "%s\t/** @deprecated */\n", indent)
+			}
+			fmt.Fprintf(w, "%s\t%s%s: %s;\n", indent, name, optional, typ)
+		}
+	}
+	w.WriteString(indent + "}")
+	return w.String(), nil
+}
+
+// fieldType returns the TypeScript type of a field of the Go type expr, with the given properties, and whether it has the json ',string' option.
+func (g *generator) fieldType(expr ast.Expr, props apiver.TagProperties, quoted bool, typeName string, indent string) (string, error) {
+	elem, isPtr := expr, false
+	if star, ok := expr.(*ast.StarExpr); ok {
+		elem, isPtr = star.X, true
+	}
+	nullable := func(typ string) string {
+		if isPtr {
+			return typ + " | null"
+		}
+		return typ
+	}
+
+	if basic := g.basicType(elem); basic != "" && basic != "string" {
+		if quoted {
+			return nullable("string"), nil
+		}
+		if props.Str {
+			return nullable(basic + " | string"), nil
+		}
+	}
+	return g.tsType(expr, typeName, indent)
+}
+
+// basicType returns the TypeScript type of expr if it's a builtin type, or a package type whose underlying type is one, else the empty string.
+func (g *generator) basicType(expr ast.Expr) string {
+	ident, ok := expr.(*ast.Ident)
+	if !ok {
+		return ""
+	}
+	switch ident.Name {
+	case "int", "int8", "int16", "int32", "int64", "rune", "uint", "uint8", "uint16", "uint32", "uint64", "uintptr", "byte", "float32", "float64":
+		return "number"
+	case "bool":
+		return "boolean"
+	case "string":
+		return "string"
+	}
+	if spec, ok := g.typeSpecs[ident.Name]; ok && !g.methods[ident.Name]["MarshalJSON"] && !g.methods[ident.Name]["MarshalText"] {
+		if underlying, ok := spec.Type.(*ast.Ident); ok && underlying.Name != ident.Name {
+			return g.basicType(underlying)
+		}
+	}
+	return ""
+}
+
+// tsType returns the TypeScript type of the Go type expr, in the type typeName, enqueueing any struct types of the package it references.
+func (g *generator) tsType(expr ast.Expr, typeName string, indent string) (string, error) {
+	if basic := g.basicType(expr); basic != "" {
+		return basic, nil
+	}
+	switch typ := expr.(type) {
+	case *ast.Ident:
+		if typ.Name == "any" || typ.Name == "error" {
+			return "unknown", nil
+		}
+		spec, ok := g.typeSpecs[typ.Name]
+		if !ok || g.methods[typ.Name]["MarshalJSON"] {
+			return "unknown", nil
+		}
+		if g.methods[typ.Name]["MarshalText"] {
+			return "string", nil
+		}
+		if _, ok := spec.Type.(*ast.StructType); ok {
+			g.enqueue(typ.Name)
+			return typ.Name, nil
+		}
+		if typ.Name == typeName {
+			return "unknown", nil // a recursive non-struct type
+		}
+		return g.tsType(spec.Type, typ.Name, indent)
+	case *ast.StarExpr:
+		elem, err := g.tsType(typ.X, typeName, indent)
+		if err != nil {
+			return "", err
+		}
+		return elem + " | null", nil
+	case *ast.ArrayType:
+		if ident, ok := typ.Elt.(*ast.Ident); ok && typ.Len == nil && (ident.Name == "byte" || ident.Name == "uint8") {
+			return "string", nil // encoding/json encodes []byte as base64
+		}
+		elem, err := g.tsType(typ.Elt, typeName, indent)
+		if err != nil {
+			return "", err
+		}
+		if strings.Contains(elem, " ") {
+			elem = "(" + elem + ")"
+		}
+		return elem + "[]", nil
+	case *ast.MapType:
+		elem, err := g.tsType(typ.Value, typeName, indent)
+		if err != nil {
+			return "", err
+		}
+		return "Record<string, " + elem + ">", nil
+	case *ast.StructType:
+		return g.structBody(typ, typeName, indent)
+	case *ast.SelectorExpr:
+		if pkg, ok := typ.X.(*ast.Ident); ok && typ.Sel.Name == "Time" && g.importPath(typeName, pkg.Name) == "time" {
+			return "string", nil
+		}
+		return "unknown", nil
+	case *ast.InterfaceType:
+		return "unknown", nil
+	default:
+		return "", errors.New("unsupported type " + fmt.Sprintf("%T", expr))
+	}
+}
+
+// importPath returns the path of the package imported as name in the file of typeName, or the empty string if it isn't imported.
+func (g *generator) importPath(typeName string, name string) string {
+	file, ok := g.typeFiles[typeName]
+	if !ok {
+		return ""
+	}
+	for _, imp := range file.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		if (imp.Name != nil && imp.Name.Name == name) || (imp.Name == nil && path[strings.LastIndex(path, "/")+1:] == name) {
+			return path
+		}
+	}
+	return ""
+}
+
+// verbatim returns whether apiver marshals structType at the generator's version as the type itself, because no field is omitted or pointer-ized, and no struct field's type changes, like BuildUnmarshalType. Verbatim types are encoded by encoding/json, which flattens embedded structs; all other types are built without embedded fields.
+func (g *generator) verbatim(structType *ast.StructType) bool {
+	for _, astField := range structType.Fields.List {
+		props := apiver.GetTagProperties(fieldTag(astField).Get(apiver.TagName))
+		if props.Version > g.version {
+			return false
+		}
+		if _, isPtr := astField.Type.(*ast.StarExpr); !isPtr && (props.Required || (props.Version != 0 && !props.Optional)) {
+			return false
+		}
+		switch typ := astField.Type.(type) {
+		case *ast.StructType:
+			if !g.verbatim(typ) {
+				return false
+			}
+		case *ast.Ident:
+			if spec, ok := g.typeSpecs[typ.Name]; ok {
+				if fieldStruct, ok := spec.Type.(*ast.StructType); ok && !g.verbatim(fieldStruct) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// fieldTag returns the tag of field, or the empty tag if it has none or it's malformed.
+func fieldTag(field *ast.Field) reflect.StructTag {
+	if field.Tag == nil {
+		return ""
+	}
+	unquoted, err := strconv.Unquote(field.Tag.Value)
+	if err != nil {
+		return ""
+	}
+	return reflect.StructTag(unquoted)
+}
+
+// isStruct returns whether name is a struct type in the package.
+func (g *generator) isStruct(name string) bool {
+	spec, ok := g.typeSpecs[name]
+	if !ok {
+		return false
+	}
+	_, ok = spec.Type.(*ast.StructType)
+	return ok
+}
+
+// embeddedStruct returns the name of the type of the embedded field, if it's a type of the package or a pointer to one, else the empty string.
+func embeddedStruct(field *ast.Field) string {
+	typ := field.Type
+	if star, ok := typ.(*ast.StarExpr); ok {
+		typ = star.X
+	}
+	if ident, ok := typ.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
+
+// jsonName returns the name in the json tag of field, if any.
+func jsonName(field *ast.Field) string {
+	if field.Tag == nil {
+		return ""
+	}
+	unquoted, err := strconv.Unquote(field.Tag.Value)
+	if err != nil {
+		return ""
+	}
+	name, _, _ := parseJSONTag(reflect.StructTag(unquoted))
+	return name
+}
+
+// parseJSONTag returns the name in the json tag, whether it has the ',string' option, and whether encoding/json ignores the field.
+func parseJSONTag(tag reflect.StructTag) (string, bool, bool) {
+	jsonTag, ok := tag.Lookup("json")
+	if !ok {
+		return "", false, false
+	}
+	parts := strings.Split(jsonTag, ",")
+	if parts[0] == "-" && len(parts) == 1 {
+		return "", false, true
+	}
+	quoted := false
+	for _, opt := range parts[1:] {
+		if opt == "string" {
+			quoted = true
+		}
+	}
+	return parts[0], quoted, false
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateGolden(t *testing.T) {
+	tests := map[string][]float64{
+		"server.d.ts":   {1.2},
+		"versions.d.ts": {1.0, 1.1, 1.2},
+	}
+	for golden, versions := range tests {
+		actual, err := Generate("testdata", []string{"Server"}, versions)
+		if err != nil {
+			t.Fatalf("Generate %v error expected: nil, actual: %+v", versions, err)
+		}
+		expected, err := ioutil.ReadFile(filepath.Join("testdata", golden))
+		if err != nil {
+			t.Fatalf("reading golden file error expected: nil, actual: %+v", err)
+		}
+		if string(actual) != string(expected) {
+			t.Errorf("Generate %v expected testdata/%s, actual differs:\n%s", versions, golden, string(actual))
+		}
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	tests := map[string]string{
+		"Missing": "not found",
+		"Status":  "not a struct",
+	}
+	for typeName, expected := range tests {
+		if _, err := Generate("testdata", []string{typeName}, []float64{1.0}); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Generate %v error expected: %+v, actual: %+v", typeName, expected, err)
+		}
+	}
+	if _, err := Generate("testdata", []string{"Server"}, nil); err == nil {
+		t.Errorf("Generate no versions error expected: %+v, actual: %+v", "no versions", err)
+	}
+}
+
+func TestGenerateEmbedded(t *testing.T) {
+	actual, err := Generate("testdata", []string{"Listing"}, []float64{1.2})
+	if err != nil {
+		t.Fatalf("Generate error expected: nil, actual: %+v", err)
+	}
+	expected := "export interface Listing extends Base {\n\ttitle?: string;\n}\n"
+	if !strings.Contains(string(actual), expected) {
+		t.Errorf("Generate verbatim expected: %v, actual: %s", expected, actual)
+	}
+
+	actual, err = Generate("testdata", []string{"Server"}, []float64{1.2})
+	if err != nil {
+		t.Fatalf("Generate error expected: nil, actual: %+v", err)
+	}
+	if expected := "export interface Server {\n\tBase?: Base;\n"; !strings.Contains(string(actual), expected) {
+		t.Errorf("Generate versioned expected: %v, actual: %s", expected, actual)
+	}
+
+	actual, err = Generate("testdata", []string{"Stamped"}, []float64{1.2})
+	if err != nil {
+		t.Fatalf("Generate error expected: nil, actual: %+v", err)
+	}
+	if expected := "export interface Stamped {\n\tStamp?: unknown;\n"; !strings.Contains(string(actual), expected) {
+		t.Errorf("Generate MarshalJSON expected: %v, actual: %s", expected, actual)
+	}
+}
@@ -0,0 +1,36 @@
+// Code generated by apiverts -type=Server -version=1.2; DO NOT EDIT.
+
+export interface Server {
+	Base?: Base;
+	name: string;
+	port: number | string;
+	up?: boolean | string | null;
+	weight?: number | null;
+	retries?: number;
+	id: string;
+	/** @deprecated */
+	old: string;
+	status: string;
+	level?: string;
+	caches: (Cache | null)[];
+	labels?: Record<string, string>;
+	updated: string;
+	raw?: unknown;
+	data?: string;
+	any?: unknown;
+	inline?: {
+		A?: number;
+	};
+	"-"?: string;
+	"x-hyphen"?: string;
+}
+
+export interface Base {
+	created?: string;
+}
+
+export interface Cache {
+	host: string;
+	next?: Cache | null;
+	mode: number | string;
+}
@@ -0,0 +1,66 @@
+package testdata
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type Server struct {
+	Base
+	Name     string            `json:"name" api:"required"`
+	Port     int               `json:"port" api:"1.1,str"`
+	Up       *bool             `json:"up" api:"1.2,str"`
+	Weight   *float64          `json:"weight" api:"1.2"`
+	Retries  uint8             `json:"retries,omitempty" api:"1.1,optional"`
+	ID       int64             `json:"id,string" api:"1.1"`
+	Old      string            `json:"old" api:"1.1,deprecated"`
+	Status   Status            `json:"status" api:"1.2"`
+	Level    Level             `json:"level"`
+	Caches   []*Cache          `json:"caches" api:"1.2"`
+	Labels   map[string]string `json:"labels"`
+	Updated  time.Time         `json:"updated" api:"1.1"`
+	Raw      json.RawMessage   `json:"raw"`
+	Data     []byte            `json:"data"`
+	Any      interface{}       `json:"any"`
+	Inline   struct{ A int }   `json:"inline"`
+	Internal string            `json:"-"`
+	Dash     string            `json:"-,"`
+	Hyphen   string            `json:"x-hyphen"`
+	private  string
+}
+
+// Listing has no versioned fields, so apiver encodes it verbatim, and encoding/json flattens Base.
+type Listing struct {
+	Base
+	Title string `json:"title"`
+}
+
+// Stamped has no versioned fields, but Stamp has its own MarshalJSON, so it's a property named by its type, not extended.
+type Stamped struct {
+	Stamp
+	Title string `json:"title"`
+}
+
+type Stamp struct {
+	At int64 `json:"at"`
+}
+
+func (s Stamp) MarshalJSON() ([]byte, error) { return json.Marshal(s.At) }
+
+type Base struct {
+	Created time.Time `json:"created"`
+}
+
+type Cache struct {
+	Host string    `json:"host" api:"1.2"`
+	Next *Cache    `json:"next"`
+	Mode CacheMode `json:"mode" api:"1.2,str"`
+}
+
+type Status string
+
+type Level int
+
+func (l Level) MarshalText() ([]byte, error) { return []byte("level"), nil }
+
+type CacheMode uint16
@@ -0,0 +1,367 @@
+// Command apivervet reports mistakes in apiver struct tags, which apiver otherwise silently ignores or only reports at runtime.
+//
+// It reports:
+//
+//   - malformed api tags, such as the typo `api:"1,4"`
+//   - the str property on fields which aren't numbers or booleans, or pointers to them
+//   - non-pointer fields newer than the type's base version, its oldest field version, without the optional property
+//   - fields with the same json name, even at different versions
+//   - fields renamed by apiver.RegisterSchema to the json name of another field
+//
+// Structs are checked if any field has an api tag, or the type is registered with a literal apiver.RegisterSchema call in the package.
+//
+// Usage:
+//
+//	go install github.com/rob05c/apiver/cmd/apivervet
+//	go vet -vettool=$(which apivervet) ./...
+//
+// The Analyzer may also be used with other go/analysis drivers, such as multichecker.
+package main
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/rob05c/apiver"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(Analyzer)
+}
+
+// Analyzer reports mistakes in apiver struct tags.
+var Analyzer = &analysis.Analyzer{
+	Name: "apivervet",
+	Doc:  "report mistakes in apiver struct tags: malformed versions, unsupported str fields, non-pointer fields newer than the base version, and duplicate or colliding json names",
+	Run:  run,
+}
+
+// tagName is the name of the tag with versions and properties, from the -tag flag.
+var tagName string
+
+func init() {
+	Analyzer.Flags.StringVar(&tagName, "tag", apiver.TagName, "name of the tag with versions and properties, as with apiver.Options.TagName")
+}
+
+// schemaField is a field's properties from a literal apiver.RegisterSchema call, and the position of the Rename, if any.
+type schemaField struct {
+	Schema    apiver.FieldSchema
+	Pos       token.Pos
+	RenamePos token.Pos
+}
+
+// vetField is a struct field to check.
+type vetField struct {
+	Name     string
+	Pos      token.Pos
+	TagPos   token.Pos
+	Type     types.Type
+	JSONName string
+	Props    apiver.TagProperties
+	// Malformed is whether the field's tag is malformed, in which case its properties aren't checked further.
+	Malformed bool
+	// RenamePos is the position of the field's rename in a RegisterSchema call, or token.NoPos if it isn't renamed.
+	RenamePos token.Pos
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	schemas := findSchemas(pass)
+
+	structNames := map[*ast.StructType]*types.TypeName{}
+	for _, file := range pass.Files {
+		ast.Inspect(file, func(node ast.Node) bool {
+			if spec, ok := node.(*ast.TypeSpec); ok {
+				if structType, ok := spec.Type.(*ast.StructType); ok {
+					if obj, ok := pass.TypesInfo.Defs[spec.Name].(*types.TypeName); ok {
+						structNames[structType] = obj
+					}
+				}
+			}
+			return true
+		})
+	}
+
+	for _, file := range pass.Files {
+		ast.Inspect(file, func(node ast.Node) bool {
+			if structType, ok := node.(*ast.StructType); ok {
+				var schema map[string]schemaField
+				if obj := structNames[structType]; obj != nil {
+					schema = schemas[obj]
+				}
+				checkStruct(pass, structType, schema)
+			}
+			return true
+		})
+	}
+	return nil, nil
+}
+
+// checkStruct reports the mistakes in the fields of structType, with the properties of schema merged into its tags.
+func checkStruct(pass *analysis.Pass, structType *ast.StructType, schema map[string]schemaField) {
+	opts := apiver.Options{TagName: tagName}
+	fields := []vetField{}
+	hasTags := len(schema) > 0
+	for _, astField := range structType.Fields.List {
+		if len(astField.Names) == 0 {
+			continue // embedded fields are flattened by encoding/json, so their names aren't known here
+		}
+		tag := reflect.StructTag("")
+		tagPos := astField.Pos()
+		if astField.Tag != nil {
+			unquoted, err := strconv.Unquote(astField.Tag.Value)
+			if err != nil {
+				continue // the compiler reports malformed tags
+			}
+			tag = reflect.StructTag(unquoted)
+			tagPos = astField.Tag.Pos()
+		}
+		apiTag, hasTag := tag.Lookup(opts.TagName)
+		hasTags = hasTags || hasTag
+		props, err := opts.ParseTagProperties(apiTag)
+		if err != nil {
+			pass.Reportf(tagPos, "%s", err.Error())
+		}
+
+		jsonName, skip := jsonFieldName(tag)
+		for _, ident := range astField.Names {
+			if !ident.IsExported() {
+				continue // encoding/json ignores unexported fields
+			}
+			f := vetField{Name: ident.Name, Pos: ident.Pos(), TagPos: tagPos, Type: pass.TypesInfo.TypeOf(astField.Type), JSONName: jsonName, Props: props, Malformed: err != nil}
+			if f.JSONName == "" {
+				f.JSONName = ident.Name
+			}
+			if s, ok := schema[ident.Name]; ok {
+				mergeSchema(&f, s)
+			} else if skip {
+				continue
+			}
+			fields = append(fields, f)
+		}
+	}
+	if !hasTags {
+		return
+	}
+
+	baseVersion := 0.0
+	for _, f := range fields {
+		if f.Props.Version != 0 && (baseVersion == 0 || f.Props.Version < baseVersion) {
+			baseVersion = f.Props.Version
+		}
+	}
+
+	names := map[string]vetField{}
+	for _, f := range fields {
+		if f.Props.Str && !f.Malformed && !strKind(f.Type) {
+			pass.Reportf(f.TagPos, "field %s: str property on unsupported type %s", f.Name, types.TypeString(f.Type, types.RelativeTo(pass.Pkg)))
+		}
+
+		if _, isPtr := f.Type.Underlying().(*types.Pointer); !isPtr && !f.Malformed && f.Props.Version > baseVersion && !f.Props.Optional {
+			pass.Reportf(f.Pos, "field %s: version %s is newer than the type's base version %s, so it must be a pointer or optional", f.Name, formatVersion(f.Props.Version), formatVersion(baseVersion))
+		}
+
+		key := strings.ToLower(f.JSONName)
+		other, ok := names[key]
+		if !ok {
+			names[key] = f
+			continue
+		}
+		if f.RenamePos != token.NoPos {
+			pass.Reportf(f.RenamePos, "field %s: rename to %q collides with the json name %q of field %s", f.Name, f.JSONName, other.JSONName, other.Name)
+		} else if other.RenamePos != token.NoPos {
+			pass.Reportf(other.RenamePos, "field %s: rename to %q collides with the json name %q of field %s", other.Name, other.JSONName, f.JSONName, f.Name)
+		} else {
+			pass.Reportf(f.Pos, "field %s: duplicate json name %q of field %s", f.Name, f.JSONName, other.Name)
+		}
+	}
+}
+
+// mergeSchema merges the properties of s into f, like apiver.Options.GetFieldProperties. Problems with the str property are reported at the schema, if it added it.
+func mergeSchema(f *vetField, s schemaField) {
+	if s.Schema.Version != 0 {
+		f.Props.Version = s.Schema.Version
+	}
+	if s.Schema.Str && !f.Props.Str {
+		f.TagPos = s.Pos
+	}
+	f.Props.Str = f.Props.Str || s.Schema.Str
+	f.Props.Required = f.Props.Required || s.Schema.Required
+	f.Props.Optional = f.Props.Optional || s.Schema.Optional
+	f.Props.Deprecated = f.Props.Deprecated || s.Schema.Deprecated
+	if s.Schema.Rename != "" {
+		f.JSONName = s.Schema.Rename
+		f.RenamePos = s.RenamePos
+	}
+}
+
+// jsonFieldName returns the name in the json tag, and whether encoding/json ignores the field.
+func jsonFieldName(tag reflect.StructTag) (string, bool) {
+	jsonTag, ok := tag.Lookup("json")
+	if !ok {
+		return "", false
+	}
+	if jsonTag == "-" {
+		return "", true
+	}
+	return strings.Split(jsonTag, ",")[0], false
+}
+
+// strKind returns whether typ, or the type it points to, is a number or boolean, which the str property supports.
+func strKind(typ types.Type) bool {
+	if ptr, ok := typ.Underlying().(*types.Pointer); ok {
+		typ = ptr.Elem()
+	}
+	basic, ok := typ.Underlying().(*types.Basic)
+	if !ok {
+		return false
+	}
+	info := basic.Info()
+	return info&types.IsComplex == 0 && info&(types.IsInteger|types.IsFloat|types.IsBoolean) != 0
+}
+
+func formatVersion(version float64) string {
+	return strconv.FormatFloat(version, 'f', -1, 64)
+}
+
+// findSchemas returns the field schemas of literal apiver.RegisterSchema calls in the package, by type, reporting fields which don't exist.
+// Only calls whose type is reflect.TypeOf of a value or pointer, and whose fields are a map literal with constant keys and properties, are found.
+func findSchemas(pass *analysis.Pass) map[*types.TypeName]map[string]schemaField {
+	schemas := map[*types.TypeName]map[string]schemaField{}
+	for _, file := range pass.Files {
+		ast.Inspect(file, func(node ast.Node) bool {
+			call, ok := node.(*ast.CallExpr)
+			if !ok || len(call.Args) != 2 || !isFunc(pass, call.Fun, "github.com/rob05c/apiver", "RegisterSchema") {
+				return true
+			}
+			obj, structType := reflectTypeOf(pass, call.Args[0])
+			lit, ok := call.Args[1].(*ast.CompositeLit)
+			if obj == nil || !ok {
+				return true
+			}
+
+			schema := map[string]schemaField{}
+			for _, elt := range lit.Elts {
+				kv, ok := elt.(*ast.KeyValueExpr)
+				if !ok {
+					continue
+				}
+				name, ok := constString(pass, kv.Key)
+				if !ok {
+					continue
+				}
+				if !hasField(structType, name) {
+					pass.Reportf(kv.Key.Pos(), "RegisterSchema: type %s has no field %q", obj.Name(), name)
+					continue
+				}
+				schema[name] = parseSchemaField(pass, kv.Value)
+			}
+			schemas[obj] = schema
+			return true
+		})
+	}
+	return schemas
+}
+
+// parseSchemaField returns the constant properties of the apiver.FieldSchema literal expr.
+func parseSchemaField(pass *analysis.Pass, expr ast.Expr) schemaField {
+	f := schemaField{Pos: expr.Pos()}
+	lit, ok := expr.(*ast.CompositeLit)
+	if !ok {
+		return f
+	}
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			continue
+		}
+		key, ok := kv.Key.(*ast.Ident)
+		if !ok {
+			continue
+		}
+		val := pass.TypesInfo.Types[kv.Value].Value
+		if val == nil {
+			continue
+		}
+		switch key.Name {
+		case "Version":
+			f.Schema.Version, _ = constant.Float64Val(constant.ToFloat(val))
+		case "Str":
+			f.Schema.Str = val.Kind() == constant.Bool && constant.BoolVal(val)
+		case "Required":
+			f.Schema.Required = val.Kind() == constant.Bool && constant.BoolVal(val)
+		case "Optional":
+			f.Schema.Optional = val.Kind() == constant.Bool && constant.BoolVal(val)
+		case "Deprecated":
+			f.Schema.Deprecated = val.Kind() == constant.Bool && constant.BoolVal(val)
+		case "Rename":
+			if val.Kind() == constant.String {
+				f.Schema.Rename = constant.StringVal(val)
+				f.RenamePos = kv.Value.Pos()
+			}
+		}
+	}
+	return f
+}
+
+// reflectTypeOf returns the named struct type of reflect.TypeOf(x), or reflect.TypeOf(x).Elem(), where x is a value of the type or a pointer to it. Returns nil for any other expression.
+func reflectTypeOf(pass *analysis.Pass, expr ast.Expr) (*types.TypeName, *types.Struct) {
+	call, ok := expr.(*ast.CallExpr)
+	if !ok {
+		return nil, nil
+	}
+	if sel, ok := call.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "Elem" && len(call.Args) == 0 {
+		if inner, ok := sel.X.(*ast.CallExpr); ok {
+			call = inner
+		}
+	}
+	if len(call.Args) != 1 || !isFunc(pass, call.Fun, "reflect", "TypeOf") {
+		return nil, nil
+	}
+	typ := pass.TypesInfo.TypeOf(call.Args[0])
+	if ptr, ok := typ.(*types.Pointer); ok {
+		typ = ptr.Elem()
+	}
+	named, ok := typ.(*types.Named)
+	if !ok {
+		return nil, nil
+	}
+	structType, ok := named.Underlying().(*types.Struct)
+	if !ok {
+		return nil, nil
+	}
+	return named.Obj(), structType
+}
+
+// isFunc returns whether fun is the package-level function name of the package with the path pkgPath.
+func isFunc(pass *analysis.Pass, fun ast.Expr, pkgPath string, name string) bool {
+	sel, ok := fun.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	obj, ok := pass.TypesInfo.Uses[sel.Sel].(*types.Func)
+	return ok && obj.Pkg() != nil && obj.Pkg().Path() == pkgPath && obj.Name() == name
+}
+
+func constString(pass *analysis.Pass, expr ast.Expr) (string, bool) {
+	val := pass.TypesInfo.Types[expr].Value
+	if val == nil || val.Kind() != constant.String {
+		return "", false
+	}
+	return constant.StringVal(val), true
+}
+
+func hasField(structType *types.Struct, name string) bool {
+	for i := 0; i < structType.NumFields(); i++ {
+		if structType.Field(i).Name() == name {
+			return true
+		}
+	}
+	return false
+}
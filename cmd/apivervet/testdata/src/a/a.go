@@ -0,0 +1,59 @@
+package a
+
+import (
+	"reflect"
+
+	"github.com/rob05c/apiver"
+)
+
+type Valid struct {
+	Name   string   `json:"name" api:"1.1"`
+	Port   int      `json:"port" api:"1.1,str"`
+	Ratio  *float64 `json:"ratio" api:"1.2,str"`
+	Limit  int      `json:"limit" api:"1.3,optional"`
+	Ignore int      `json:"-" api:"1.1,str"`
+	Inner  struct {
+		Enabled bool `json:"enabled" api:"str"`
+	} `json:"inner"`
+	private string
+}
+
+type Tags struct {
+	Typo   int    `json:"typo" api:"1,4"`        // want `malformed api tag '1,4': multiple versions '1' and '4'`
+	Bogus  int    `json:"bogus" api:"1,strr"`    // want `malformed api tag '1,strr': unknown property 'strr'`
+	Name   int    `json:"name" api:"1,str,str2"` // want `unknown property 'str2'`
+	Empty  int    `json:"empty" api:"1,"`        // want `empty property`
+	Text   string `json:"text" api:"1,str"`      // want `field Text: str property on unsupported type string`
+	Ints   []int  `json:"ints" api:"1,str"`      // want `field Ints: str property on unsupported type \[\]int`
+	Newer  int    `json:"newer" api:"1.2"`       // want `field Newer: version 1.2 is newer than the type's base version 1, so it must be a pointer or optional`
+	Newest *int   `json:"newest" api:"1.3"`
+}
+
+type Duplicates struct {
+	Port  int  `json:"port" api:"1.1"`
+	PortS *int `json:"port" api:"1.2"` // want `field PortS: duplicate json name "port" of field Port`
+	Host  string
+	HOST  *string `api:"1.2"` // want `field HOST: duplicate json name "HOST" of field Host`
+}
+
+// Untagged isn't checked, because it has no api tags.
+type Untagged struct {
+	A int `json:"a"`
+	B int `json:"a"`
+}
+
+type Foreign struct {
+	Name  string `json:"name"`
+	Cache string `json:"cache"`
+	Group string `json:"group"`
+	Text  string `json:"text"`
+}
+
+func init() {
+	apiver.RegisterSchema(reflect.TypeOf(Foreign{}), map[string]apiver.FieldSchema{
+		"Cache":   {Version: 1.1, Rename: "group"}, // want `field Cache: rename to "group" collides with the json name "group" of field Group`
+		"Group":   {Version: 1.1},
+		"Text":    {Version: 1.1, Str: true}, // want `field Text: str property on unsupported type string`
+		"Missing": {Version: 1.2},            // want `RegisterSchema: type Foreign has no field "Missing"`
+	})
+}
@@ -0,0 +1,14 @@
+// Package apiver is a stub of the apiver RegisterSchema API, for analyzer tests.
+package apiver
+
+import "reflect"
+
+type FieldSchema struct {
+	Version  float64
+	Str      bool
+	Required bool
+	Optional bool
+	Rename   string
+}
+
+func RegisterSchema(typ reflect.Type, fields map[string]FieldSchema) error { return nil }
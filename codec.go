@@ -0,0 +1,219 @@
+package apiver
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Codec is an encoding which uses the versioning core, such as JSON, YAML, or XML.
+// The core builds a new type for each real type and version, omitting newer fields, pointer-izing versioned fields, and replacing 'str' fields with the codec's str types. The codec serializes and deserializes the built objects.
+//
+// Third-party encodings, such as CBOR or TOML, may implement Codec and be used with UnmarshalCodec and MarshalCodec, without any changes to this package. Most codecs should embed BaseCodec, and only implement Marshal and Unmarshal.
+type Codec interface {
+	// TagName is the struct tag key of the encoding, such as "json".
+	TagName() string
+	// FieldName returns the user-facing name of the given field of a built type, used in UserError messages. This is typically the name in the codec's tag, else the struct field name.
+	FieldName(field reflect.StructField) string
+	// FieldTag returns the tag of the given real field, to use in the built type. Most codecs return field.Tag, but codecs may use it to add their own tags, for example deriving names from json tags.
+	FieldTag(field reflect.StructField) reflect.StructTag
+	// StrType returns the type to decode 'str' fields of the given kind into, or nil if 'str' isn't supported for the kind.
+	// The type must be convertible to the kind, and should decode both strings and the kind's native encoding.
+	StrType(kind reflect.Kind) reflect.Type
+	// Marshal serializes the built object fakeObj. The realType is the type of the real object fakeObj was built from, for encodings such as XML which name things after types.
+	Marshal(fakeObj interface{}, realType reflect.Type) ([]byte, error)
+	// Unmarshal deserializes data into the built object fakeObj, which is always a pointer.
+	Unmarshal(data []byte, fakeObj interface{}) error
+}
+
+// BaseCodec implements the Codec methods besides Marshal and Unmarshal, for encodings which name fields by the Tag tag, and use the IntS, UIntS, FloatS, and BoolS str types.
+type BaseCodec struct {
+	Tag string
+}
+
+func (c BaseCodec) TagName() string { return c.Tag }
+
+func (c BaseCodec) FieldName(field reflect.StructField) string { return tagFieldName(field, c.Tag) }
+
+func (c BaseCodec) FieldTag(field reflect.StructField) reflect.StructTag { return field.Tag }
+
+func (c BaseCodec) StrType(kind reflect.Kind) reflect.Type { return DefaultStrType(kind) }
+
+// DefaultStrType returns the str type of this package for the given kind: IntS, UIntS, FloatS, or BoolS. Returns nil for any other kind.
+// These types decode JSON, YAML, XML, and text.
+func DefaultStrType(kind reflect.Kind) reflect.Type {
+	switch kind {
+	case reflect.Bool:
+		return reflect.TypeOf(BoolS(false))
+	case reflect.Int:
+		fallthrough
+	case reflect.Int8:
+		fallthrough
+	case reflect.Int16:
+		fallthrough
+	case reflect.Int32:
+		fallthrough
+	case reflect.Int64:
+		return reflect.TypeOf(IntS(0))
+	case reflect.Uint:
+		fallthrough
+	case reflect.Uint8:
+		fallthrough
+	case reflect.Uint16:
+		fallthrough
+	case reflect.Uint32:
+		fallthrough
+	case reflect.Uint64:
+		fallthrough
+	case reflect.Uintptr:
+		return reflect.TypeOf(UIntS(0))
+	case reflect.Float32:
+		fallthrough
+	case reflect.Float64:
+		return reflect.TypeOf(FloatS(0))
+	default:
+		return nil
+	}
+}
+
+// DeriveTagFromJSON returns the field's tag, with a tagKey tag with the json tag name and omitempty option added, if the field has a json tag but no tagKey tag.
+// This is designed for the FieldTag of codecs such as YAML, so the same struct definitions and names may be used with both JSON and the codec.
+func DeriveTagFromJSON(field reflect.StructField, tagKey string) reflect.StructTag {
+	if _, ok := field.Tag.Lookup(tagKey); ok {
+		return field.Tag
+	}
+	jsonTag, ok := field.Tag.Lookup("json")
+	if !ok {
+		return field.Tag
+	}
+
+	jsonTagParts := strings.Split(jsonTag, ",")
+	tag := jsonTagParts[0]
+	if tag == "-" && len(jsonTagParts) == 1 {
+		return reflect.StructTag(string(field.Tag) + ` ` + tagKey + `:"-"`)
+	}
+	for _, opt := range jsonTagParts[1:] {
+		if opt == "omitempty" {
+			tag += ",omitempty"
+		}
+	}
+	if tag == "" {
+		return field.Tag
+	}
+	return reflect.StructTag(string(field.Tag) + ` ` + tagKey + `:"` + tag + `"`)
+}
+
+// JSONCodec is the Codec for encoding/json.
+var JSONCodec Codec = jsonCodec{BaseCodec{Tag: "json"}}
+
+type jsonCodec struct{ BaseCodec }
+
+func (c jsonCodec) Marshal(fakeObj interface{}, realType reflect.Type) ([]byte, error) {
+	return json.Marshal(fakeObj)
+}
+
+func (c jsonCodec) Unmarshal(data []byte, fakeObj interface{}) error {
+	return json.Unmarshal(data, fakeObj)
+}
+
+func (c jsonCodec) UnmarshalDisallowUnknownFields(data []byte, fakeObj interface{}) error {
+	if !json.Valid(data) {
+		return json.Unmarshal(data, fakeObj) // returns the *json.SyntaxError, which a json.Decoder doesn't for trailing data
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(fakeObj)
+}
+
+// UnknownFieldsCodec is a Codec which can reject data with fields which don't exist in the built object, for Options.DisallowUnknownFields.
+// The JSON, YAML, MessagePack, and CBOR codecs implement it.
+type UnknownFieldsCodec interface {
+	Codec
+	// UnmarshalDisallowUnknownFields is Unmarshal, but returns an error if data has any field which doesn't exist in fakeObj.
+	UnmarshalDisallowUnknownFields(data []byte, fakeObj interface{}) error
+}
+
+// UnmarshalCodec parses bts with the codec c into realObj, with the same versioning, str, and required-field semantics as UnmarshalJSON.
+func UnmarshalCodec(c Codec, bts []byte, realObj interface{}, version float64) error {
+	return UnmarshalCodecWithOptions(c, bts, realObj, version, Options{})
+}
+
+// UnmarshalCodecWithOptions is UnmarshalCodec, with the tag names and behavior of opts.
+// Returns an InternalError if opts.DisallowUnknownFields is set and c isn't an UnknownFieldsCodec.
+func UnmarshalCodecWithOptions(c Codec, bts []byte, realObj interface{}, version float64, opts Options) error {
+	unmarshal := c.Unmarshal
+	if opts.DisallowUnknownFields {
+		uc, ok := c.(UnknownFieldsCodec)
+		if !ok {
+			return InternalError{"codec '" + c.TagName() + "' can't disallow unknown fields"}
+		}
+		unmarshal = uc.UnmarshalDisallowUnknownFields
+	}
+	return unmarshalObj(c, realObj, version, opts, func(fakeObj interface{}) error {
+		return unmarshal(bts, fakeObj)
+	})
+}
+
+// UnmarshalCodecFunc is UnmarshalCodec, but calls decode with the built object to decode into, rather than c.Unmarshal.
+// This is designed for streaming decoders, which decode from a reader rather than bytes.
+func UnmarshalCodecFunc(c Codec, realObj interface{}, version float64, decode func(fakeObj interface{}) error) error {
+	return UnmarshalCodecFuncWithOptions(c, realObj, version, Options{}, decode)
+}
+
+// UnmarshalCodecFuncWithOptions is UnmarshalCodecFunc, with the tag names and behavior of opts. The decode func must reject unknown fields itself, if opts.DisallowUnknownFields is set.
+func UnmarshalCodecFuncWithOptions(c Codec, realObj interface{}, version float64, opts Options, decode func(fakeObj interface{}) error) error {
+	return unmarshalObj(c, realObj, version, opts, decode)
+}
+
+// MarshalCodec serializes realObj with the codec c, omitting fields newer than version.
+func MarshalCodec(c Codec, realObj interface{}, version float64) ([]byte, error) {
+	return MarshalCodecWithOptions(c, realObj, version, Options{})
+}
+
+// MarshalCodecWithOptions is MarshalCodec, with the tag names and behavior of opts.
+func MarshalCodecWithOptions(c Codec, realObj interface{}, version float64, opts Options) ([]byte, error) {
+	obj, err := BuildMarshalCodecObjWithOptions(c, realObj, version, opts)
+	if err != nil {
+		return nil, err
+	}
+	return c.Marshal(obj, reflect.TypeOf(realObj))
+}
+
+// BuildMarshalCodecObj is BuildMarshalObj, building the object for the codec c.
+// This is designed for streaming encoders, which encode to a writer rather than bytes.
+func BuildMarshalCodecObj(c Codec, realObj interface{}, version float64) (interface{}, error) {
+	return BuildMarshalCodecObjWithOptions(c, realObj, version, Options{})
+}
+
+// BuildMarshalCodecObjWithOptions is BuildMarshalCodecObj, with the tag names and behavior of opts.
+func BuildMarshalCodecObjWithOptions(c Codec, realObj interface{}, version float64, opts Options) (interface{}, error) {
+	return buildMarshalObj(c, realObj, version, opts)
+}
+
+var codecsMutex sync.RWMutex
+var codecs = map[string]Codec{}
+
+func init() {
+	RegisterCodec("json", JSONCodec)
+	RegisterCodec("yaml", YAMLCodec)
+	RegisterCodec("xml", XMLCodec)
+}
+
+// RegisterCodec registers the codec c with the given name, such as a media type or file extension, to be found with GetCodec.
+// Registering a name again replaces the codec.
+func RegisterCodec(name string, c Codec) {
+	codecsMutex.Lock()
+	defer codecsMutex.Unlock()
+	codecs[name] = c
+}
+
+// GetCodec returns the codec registered with name, and whether it exists.
+// The codecs "json", "yaml", and "xml" are always registered.
+func GetCodec(name string) (Codec, bool) {
+	codecsMutex.RLock()
+	defer codecsMutex.RUnlock()
+	c, ok := codecs[name]
+	return c, ok
+}
@@ -0,0 +1,157 @@
+package apiver
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// wireCodec is a test third-party Codec, which names fields by a "wire" tag, encodes as JSON, and only supports 'str' for ints, as hexadecimal strings.
+type wireCodec struct{ BaseCodec }
+
+func (c wireCodec) FieldTag(field reflect.StructField) reflect.StructTag {
+	name, ok := field.Tag.Lookup("wire")
+	if !ok {
+		return field.Tag
+	}
+	return reflect.StructTag(`json:"` + name + `"`)
+}
+
+func (c wireCodec) StrType(kind reflect.Kind) reflect.Type {
+	if kind == reflect.Int {
+		return reflect.TypeOf(wireHexInt(0))
+	}
+	return nil
+}
+
+func (c wireCodec) Marshal(fakeObj interface{}, realType reflect.Type) ([]byte, error) {
+	return json.Marshal(fakeObj)
+}
+
+func (c wireCodec) Unmarshal(data []byte, fakeObj interface{}) error {
+	return json.Unmarshal(data, fakeObj)
+}
+
+type wireHexInt int
+
+func (i *wireHexInt) UnmarshalJSON(d []byte) error {
+	s := ""
+	if err := json.Unmarshal(d, &s); err != nil {
+		return err
+	}
+	v, err := strconv.ParseInt(strings.TrimPrefix(s, "0x"), 16, 64)
+	if err != nil {
+		return err
+	}
+	*i = wireHexInt(v)
+	return nil
+}
+
+func TestUnmarshalCodec(t *testing.T) {
+	type Obj struct {
+		Foo int  `wire:"w_foo" api:"1.1,str"`
+		A   *int `wire:"w_a" api:"1.4,str"`
+	}
+
+	c := wireCodec{BaseCodec{Tag: "json"}}
+	obj := Obj{}
+	objW := `{"w_foo": "0x2a", "w_a": "0x18"}`
+	if err := UnmarshalCodec(c, []byte(objW), &obj, 1.3); err != nil {
+		t.Fatalf("UnmarshalCodec %+v error expected nil, actual %+v", objW, err)
+	}
+	if obj.Foo != 42 {
+		t.Errorf("UnmarshalCodec %+v obj.Foo expected: %+v, actual: %+v", objW, 42, obj.Foo)
+	}
+	if obj.A != nil {
+		t.Errorf("UnmarshalCodec %+v obj.A expected: nil, actual: %+v", objW, *obj.A)
+	}
+}
+
+func TestUnmarshalCodecMissingVal(t *testing.T) {
+	type Obj struct {
+		Foo int `wire:"w_foo" api:"1.1,str"`
+		A   int `wire:"w_a" api:"1.4"`
+	}
+
+	c := wireCodec{BaseCodec{Tag: "json"}}
+	obj := Obj{}
+	objW := `{"w_foo": "0x2a"}`
+	err := UnmarshalCodec(c, []byte(objW), &obj, 1.4)
+	if err == nil || err.Error() != "missing required field: w_a" {
+		t.Errorf("UnmarshalCodec %+v error expected 'missing required field: w_a', actual %+v", objW, err)
+	}
+	if _, ok := err.(UserError); !ok {
+		t.Errorf("UnmarshalCodec %+v error expected UserError, actual %T", objW, err)
+	}
+}
+
+func TestUnmarshalCodecUnsupportedStr(t *testing.T) {
+	type Obj struct {
+		F float64 `wire:"w_f" api:"1.1,str"`
+	}
+
+	c := wireCodec{BaseCodec{Tag: "json"}}
+	obj := Obj{}
+	objW := `{"w_f": "4.2"}`
+	if err := UnmarshalCodec(c, []byte(objW), &obj, 1.1); err == nil {
+		t.Errorf("UnmarshalCodec %+v unsupported str kind error expected non-nil, actual nil", objW)
+	}
+}
+
+func TestMarshalCodec(t *testing.T) {
+	type Obj struct {
+		Foo int  `wire:"w_foo" api:"1.1,str"`
+		A   *int `wire:"w_a" api:"1.4,str"`
+	}
+	a := 24
+
+	c := wireCodec{BaseCodec{Tag: "json"}}
+	actual, err := MarshalCodec(c, Obj{Foo: 42, A: &a}, 1.3)
+	if err != nil {
+		t.Fatalf("MarshalCodec error expected: nil, actual: %+v", err)
+	}
+
+	expected := `{"w_foo":42}`
+	if string(actual) != expected {
+		t.Errorf("MarshalCodec expected ''%+v'', actual ''%+v''", expected, string(actual))
+	}
+}
+
+func TestGetCodec(t *testing.T) {
+	for _, name := range []string{"json", "yaml", "xml"} {
+		c, ok := GetCodec(name)
+		if !ok {
+			t.Errorf("GetCodec %+v expected: exists, actual: missing", name)
+			continue
+		}
+		if c.TagName() != name {
+			t.Errorf("GetCodec %+v TagName expected: %+v, actual: %+v", name, name, c.TagName())
+		}
+	}
+
+	if _, ok := GetCodec("wire"); ok {
+		t.Errorf("GetCodec unregistered expected: missing, actual: exists")
+	}
+	RegisterCodec("wire", wireCodec{BaseCodec{Tag: "json"}})
+	if _, ok := GetCodec("wire"); !ok {
+		t.Errorf("GetCodec registered expected: exists, actual: missing")
+	}
+}
+
+func TestDefaultStrType(t *testing.T) {
+	tests := map[reflect.Kind]reflect.Type{
+		reflect.Bool:    reflect.TypeOf(BoolS(false)),
+		reflect.Int8:    reflect.TypeOf(IntS(0)),
+		reflect.Uint32:  reflect.TypeOf(UIntS(0)),
+		reflect.Float32: reflect.TypeOf(FloatS(0)),
+		reflect.String:  nil,
+		reflect.Struct:  nil,
+	}
+	for kind, expected := range tests {
+		if actual := DefaultStrType(kind); actual != expected {
+			t.Errorf("DefaultStrType %+v expected: %+v, actual: %+v", kind, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package apiver
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/csv"
+	"io"
+	"reflect"
+	"strconv"
+)
+
+// MarshalCSV serializes the given slice of structs, or pointers to structs, as CSV, with a header row.
+// Columns are the fields which exist at version, in struct order, with headers from the field's csv tag, else its json tag, else the field name. Nil pointers are empty cells.
+func MarshalCSV(realObjs interface{}, version float64) ([]byte, error) {
+	return MarshalCSVWithOptions(realObjs, version, Options{})
+}
+
+// MarshalCSVWithOptions is MarshalCSV, with the tag names and behavior of opts.
+func MarshalCSVWithOptions(realObjs interface{}, version float64, opts Options) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w := csv.NewWriter(buf)
+	if err := WriteCSVWithOptions(w, realObjs, version, opts); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalCSV parses CSV with a header row into the given pointer to a slice of structs, or pointers to structs, appending a struct for each row.
+// This behaves like UnmarshalJSON: columns of fields newer than version, and unknown columns, are ignored; numbers and booleans are parsed with the 'str' types; and rows missing required fields return the same UserError, prefixed with the row number.
+// Empty cells are missing values, except for strings, which are empty strings.
+func UnmarshalCSV(bts []byte, realObjs interface{}, version float64) error {
+	return UnmarshalCSVWithOptions(bts, realObjs, version, Options{})
+}
+
+// UnmarshalCSVWithOptions is UnmarshalCSV, with the tag names and behavior of opts. With opts.DisallowUnknownFields, unknown columns, including columns of fields newer than version, return a UserError.
+func UnmarshalCSVWithOptions(bts []byte, realObjs interface{}, version float64, opts Options) error {
+	return ReadCSVWithOptions(csv.NewReader(bytes.NewReader(bts)), realObjs, version, opts)
+}
+
+// WriteCSV is MarshalCSV, writing to w and flushing it. The writer may be configured, e.g. with a different Comma, before calling.
+func WriteCSV(w *csv.Writer, realObjs interface{}, version float64) error {
+	return WriteCSVWithOptions(w, realObjs, version, Options{})
+}
+
+// WriteCSVWithOptions is WriteCSV, with the tag names and behavior of opts.
+func WriteCSVWithOptions(w *csv.Writer, realObjs interface{}, version float64, opts Options) error {
+	realVal := reflect.Indirect(reflect.ValueOf(realObjs))
+	if realVal.Kind() != reflect.Slice {
+		return InternalError{"object must be a slice"}
+	}
+	structType := realVal.Type().Elem()
+	for structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return InternalError{"object must be a slice of structs"}
+	}
+	if err := opts.checkType(csvCodec, structType); err != nil {
+		return err
+	}
+
+	fakeType := buildUnmarshalType(csvCodec, structType, version, false, opts)
+	fields := csvFields(fakeType)
+
+	header := make([]string, 0, len(fields))
+	for _, field := range fields {
+		header = append(header, csvCodec.FieldName(field))
+	}
+	if err := w.Write(header); err != nil {
+		return err
+	}
+
+	for i := 0; i < realVal.Len(); i++ {
+		elem := realVal.Index(i)
+		for elem.Kind() == reflect.Ptr && !elem.IsNil() {
+			elem = elem.Elem()
+		}
+		if elem.Kind() == reflect.Ptr {
+			continue // nil elements have no row
+		}
+		row := make([]string, 0, len(fields))
+		for _, field := range fields {
+			cell, err := formatStringValue(elem.FieldByName(field.Name))
+			if err != nil {
+				return prefixError("field '"+csvCodec.FieldName(field)+"': ", err)
+			}
+			row = append(row, cell)
+		}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// ReadCSV is UnmarshalCSV, reading from r. The reader may be configured, e.g. with a different Comma, before calling.
+// Rows are numbered in errors like a spreadsheet, with the header as row 1.
+func ReadCSV(r *csv.Reader, realObjs interface{}, version float64) error {
+	return ReadCSVWithOptions(r, realObjs, version, Options{})
+}
+
+// ReadCSVWithOptions is ReadCSV, with the tag names and behavior of opts, like UnmarshalCSVWithOptions.
+func ReadCSVWithOptions(r *csv.Reader, realObjs interface{}, version float64, opts Options) error {
+	realVal := reflect.ValueOf(realObjs)
+	if realVal.Kind() != reflect.Ptr {
+		return InternalError{"object must be a pointer"}
+	}
+	if realVal.IsNil() {
+		return InternalError{"object must not be nil"}
+	}
+	realVal = realVal.Elem()
+	if realVal.Kind() != reflect.Slice {
+		return InternalError{"object must be a pointer to a slice"}
+	}
+	elemType := realVal.Type().Elem()
+	structType := elemType
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return InternalError{"object must be a pointer to a slice of structs"}
+	}
+	if err := opts.checkType(csvCodec, structType); err != nil {
+		return err
+	}
+
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil
+	} else if err != nil {
+		return UserError{"malformed csv: " + err.Error()}
+	}
+
+	fakeType := buildUnmarshalType(csvCodec, structType, version, true, opts)
+	fieldsByName := map[string]reflect.StructField{}
+	for _, field := range csvFields(fakeType) {
+		fieldsByName[csvCodec.FieldName(field)] = field
+	}
+	if opts.DisallowUnknownFields {
+		if err := checkCSVHeader(header, fieldsByName, structType, version); err != nil {
+			return err
+		}
+	}
+	columns := make([]*reflect.StructField, len(header))
+	for i, name := range header {
+		if field, ok := fieldsByName[name]; ok {
+			columns[i] = &field
+		}
+	}
+
+	for rowNum := 2; ; rowNum++ {
+		row, err := r.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return UserError{"malformed csv: " + err.Error()}
+		}
+
+		fakeVal := reflect.New(fakeType).Elem()
+		for i, cell := range row {
+			if i >= len(columns) || columns[i] == nil {
+				continue
+			}
+			fakeField := fakeVal.FieldByIndex(columns[i].Index)
+			if cell == "" && baseKind(fakeField.Type()) != reflect.String {
+				continue
+			}
+			if err := setStringValues(fakeField, []string{cell}); err != nil {
+				return prefixError("row "+strconv.Itoa(rowNum)+": field '"+header[i]+"': ", err)
+			}
+		}
+
+		realElem := reflect.New(structType)
+		if err := setUnmarshalObj(csvCodec, fakeVal, realElem); err != nil {
+			return prefixError("row "+strconv.Itoa(rowNum)+": ", err)
+		}
+		if elemType.Kind() != reflect.Ptr {
+			realElem = realElem.Elem()
+		}
+		realVal.Set(reflect.Append(realVal, realElem))
+	}
+}
+
+// checkCSVHeader returns a UserError if header has any column which isn't in fieldsByName, the fields of the built type. Columns of fields of realType newer than version are named in the error; other unknown columns are not, because UserErrors never reflect user data.
+func checkCSVHeader(header []string, fieldsByName map[string]reflect.StructField, realType reflect.Type, version float64) error {
+	newer := map[string]struct{}{}
+	for i := 0; i < realType.NumField(); i++ {
+		field := realType.Field(i)
+		field.Tag = schemaFieldTag(csvCodec, realType, field)
+		newer[csvCodec.FieldName(field)] = struct{}{}
+	}
+	for _, name := range header {
+		if _, ok := fieldsByName[name]; ok {
+			continue
+		}
+		if _, ok := newer[name]; ok {
+			return UserError{"csv column '" + name + "' does not exist in version " + strconv.FormatFloat(version, 'f', -1, 64)}
+		}
+		return UserError{"unknown csv column"}
+	}
+	return nil
+}
+
+// csvCodec names fields for CSV columns. It's only used to build types; rows are read and written by ReadCSV and WriteCSV.
+var csvCodec Codec = csvCodecT{BaseCodec{Tag: "csv"}}
+
+type csvCodecT struct{ BaseCodec }
+
+func (c csvCodecT) FieldTag(field reflect.StructField) reflect.StructTag {
+	return DeriveTagFromJSON(field, c.Tag)
+}
+
+func (c csvCodecT) Marshal(fakeObj interface{}, realType reflect.Type) ([]byte, error) {
+	return nil, InternalError{"csv objects must be marshalled with MarshalCSV"}
+}
+
+func (c csvCodecT) Unmarshal(data []byte, fakeObj interface{}) error {
+	return InternalError{"csv objects must be unmarshalled with UnmarshalCSV"}
+}
+
+// csvFields returns the exported fields of the built type fakeType which are CSV columns.
+func csvFields(fakeType reflect.Type) []reflect.StructField {
+	fields := []reflect.StructField{}
+	for i := 0; i < fakeType.NumField(); i++ {
+		field := fakeType.Field(i)
+		if isExported := field.PkgPath == ""; !isExported {
+			continue
+		}
+		if csvCodec.FieldName(field) == "-" {
+			continue
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// formatStringValue returns val as a string, with its TextMarshaler if it has one. Nil pointers are the empty string.
+func formatStringValue(val reflect.Value) (string, error) {
+	for val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return "", nil
+		}
+		val = val.Elem()
+	}
+	if marshaler, ok := val.Interface().(encoding.TextMarshaler); ok {
+		text, err := marshaler.MarshalText()
+		return string(text), err
+	}
+	switch val.Kind() {
+	case reflect.String:
+		return val.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(val.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(val.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(val.Uint(), 10), nil
+	case reflect.Float32:
+		return strconv.FormatFloat(val.Float(), 'f', -1, 32), nil
+	case reflect.Float64:
+		return strconv.FormatFloat(val.Float(), 'f', -1, 64), nil
+	default:
+		return "", InternalError{"type '" + val.Type().String() + "' can't be formatted as a string"}
+	}
+}
+
+// baseKind returns the kind of typ, after dereferencing any pointers.
+func baseKind(typ reflect.Type) reflect.Kind {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ.Kind()
+}
@@ -0,0 +1,121 @@
+package apiver
+
+import (
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"testing"
+)
+
+type csvServer struct {
+	Name    string   `json:"name" api:"1.1"`
+	Port    int      `csv:"port_num" json:"port" api:"1.1,str"`
+	Weight  *float64 `json:"weight" api:"1.2"`
+	Up      bool     `json:"up" api:"1.1"`
+	NewOne  *string  `json:"new_one" api:"1.4"`
+	Ignored string   `json:"-"`
+}
+
+func TestMarshalCSV(t *testing.T) {
+	weight := 0.5
+	newOne := "new"
+	objs := []csvServer{
+		{Name: "a", Port: 80, Weight: &weight, Up: true, NewOne: &newOne, Ignored: "x"},
+		{Name: "b,c", Port: 443},
+	}
+
+	actual, err := MarshalCSV(objs, 1.3)
+	if err != nil {
+		t.Fatalf("MarshalCSV error expected nil, actual %+v", err)
+	}
+	expected := "name,port_num,weight,up\na,80,0.5,true\n\"b,c\",443,,false\n"
+	if string(actual) != expected {
+		t.Errorf("MarshalCSV expected ''%+v'', actual ''%+v''", expected, string(actual))
+	}
+}
+
+func TestMarshalCSVPointers(t *testing.T) {
+	objs := []*csvServer{{Name: "a", Port: 80}, nil}
+
+	actual, err := MarshalCSV(objs, 1.1)
+	if err != nil {
+		t.Fatalf("MarshalCSV error expected nil, actual %+v", err)
+	}
+	expected := "name,port_num,up\na,80,false\n"
+	if string(actual) != expected {
+		t.Errorf("MarshalCSV expected ''%+v'', actual ''%+v''", expected, string(actual))
+	}
+}
+
+func TestUnmarshalCSV(t *testing.T) {
+	data := "up,port_num,name,new_one,unknown\n1,80,a,new,x\nfalse,443,,,y\n"
+
+	objs := []csvServer{}
+	if err := UnmarshalCSV([]byte(data), &objs, 1.3); err != nil {
+		t.Fatalf("UnmarshalCSV error expected nil, actual %+v", err)
+	}
+	expected := []csvServer{{Name: "a", Port: 80, Up: true}, {Name: "", Port: 443, Up: false}}
+	if !reflect.DeepEqual(objs, expected) {
+		t.Errorf("UnmarshalCSV expected: %+v, actual: %+v", expected, objs)
+	}
+}
+
+func TestUnmarshalCSVPointers(t *testing.T) {
+	data := "name,port_num,up,weight\na,80,true,0.5\n"
+
+	objs := []*csvServer{}
+	if err := UnmarshalCSV([]byte(data), &objs, 1.2); err != nil {
+		t.Fatalf("UnmarshalCSV error expected nil, actual %+v", err)
+	}
+	if len(objs) != 1 || objs[0].Name != "a" || objs[0].Weight == nil || *objs[0].Weight != 0.5 {
+		t.Errorf("UnmarshalCSV expected: %+v, actual: %+v", "[{Name:a Port:80 Weight:0.5 Up:true}]", objs)
+	}
+}
+
+func TestUnmarshalCSVMissingVal(t *testing.T) {
+	data := "name,port_num,up\na,80,true\nb,,true\n"
+
+	objs := []csvServer{}
+	err := UnmarshalCSV([]byte(data), &objs, 1.1)
+	if err == nil || err.Error() != "row 3: missing required field: port_num" {
+		t.Errorf("UnmarshalCSV error expected 'row 3: missing required field: port_num', actual %+v", err)
+	}
+	if _, ok := err.(UserError); !ok {
+		t.Errorf("UnmarshalCSV error expected UserError, actual %T", err)
+	}
+}
+
+func TestUnmarshalCSVInvalid(t *testing.T) {
+	data := "name,port_num,up\na,eighty,true\n"
+
+	objs := []csvServer{}
+	err := UnmarshalCSV([]byte(data), &objs, 1.1)
+	if err == nil || err.Error() != "row 2: field 'port_num': not an integer" {
+		t.Errorf("UnmarshalCSV error expected 'row 2: field 'port_num': not an integer', actual %+v", err)
+	}
+	if _, ok := err.(UserError); !ok {
+		t.Errorf("UnmarshalCSV error expected UserError, actual %T", err)
+	}
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+	weight := 2.0
+	objs := []csvServer{{Name: "a", Port: 80, Weight: &weight, Up: true}, {Name: "b", Port: 81}}
+
+	buf := &bytes.Buffer{}
+	w := csv.NewWriter(buf)
+	w.Comma = '\t'
+	if err := WriteCSV(w, objs, 1.2); err != nil {
+		t.Fatalf("WriteCSV error expected nil, actual %+v", err)
+	}
+
+	r := csv.NewReader(buf)
+	r.Comma = '\t'
+	newObjs := []csvServer{}
+	if err := ReadCSV(r, &newObjs, 1.2); err != nil {
+		t.Fatalf("ReadCSV error expected nil, actual %+v", err)
+	}
+	if !reflect.DeepEqual(objs, newObjs) {
+		t.Errorf("CSV round trip expected: %+v, actual: %+v", objs, newObjs)
+	}
+}
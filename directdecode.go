@@ -0,0 +1,571 @@
+package apiver
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// UnmarshalJSONDirect parses JSON for the given object, exactly like UnmarshalJSON, but without building and decoding into an intermediate object.
+// It reads the JSON and writes values straight into realObj, via a plan cached per type and version, so values aren't allocated twice. Values of types without any versioned or 'str' fields, such as time.Time, are still decoded by encoding/json.
+// Unlike UnmarshalJSON, realObj may be partially written if the JSON is valid but an error is returned, and errors for values of the wrong type don't include the struct field they were in.
+func UnmarshalJSONDirect(bts []byte, realObj interface{}, version float64) error {
+	return UnmarshalJSONDirectWithOptions(bts, realObj, version, Options{})
+}
+
+// UnmarshalJSONDirectWithOptions is UnmarshalJSONDirect, with the tag names and behavior of opts, exactly like UnmarshalJSONWithOptions.
+func UnmarshalJSONDirectWithOptions(bts []byte, realObj interface{}, version float64, opts Options) error {
+	if u, ok := versionUnmarshaler(realObj); ok && opts.unmarshalHooks() {
+		return u.UnmarshalJSONVersion(bts, version)
+	}
+
+	obj := reflect.ValueOf(realObj)
+	if obj.Kind() != reflect.Ptr {
+		return InternalError{"object must be a pointer"}
+	}
+	if obj.IsNil() {
+		return InternalError{"object must not be nil"}
+	}
+	obj = obj.Elem()
+	if err := opts.checkType(JSONCodec, obj.Type()); err != nil {
+		return err
+	}
+	return unmarshalJSONDirect(bts, obj, getDecodePlan(obj.Type(), version, opts), opts.DisallowUnknownFields)
+}
+
+// unmarshalJSONDirect decodes bts into val, per plan. If disallowUnknownFields, keys which aren't fields return an error, like json.Decoder.DisallowUnknownFields.
+func unmarshalJSONDirect(bts []byte, val reflect.Value, plan *decodePlan, disallowUnknownFields bool) error {
+	if !json.Valid(bts) {
+		return json.Unmarshal(bts, new(interface{})) // returns the *json.SyntaxError
+	}
+
+	d := &directDecoder{data: bts, disallowUnknownFields: disallowUnknownFields}
+	if err := d.decode(plan, val); err != nil {
+		if decodeErr, ok := err.(directDecodeError); ok {
+			return decodeErr.error
+		}
+		return err
+	}
+	return nil
+}
+
+// decodePlanKind is how a decodePlan decodes a value.
+type decodePlanKind int
+
+const (
+	// planBasic decodes strings, bools, and numbers directly.
+	planBasic decodePlanKind = iota
+	// planStruct decodes objects into structs with versioned or 'str' fields, field by field.
+	planStruct
+	// planSlice decodes arrays into slices of versioned structs, element by element.
+	planSlice
+	// planMap decodes objects into maps with string keys of versioned structs, value by value.
+	planMap
+	// planFallback decodes values with encoding/json into a new fake value, then sets the real value, exactly like UnmarshalJSON.
+	planFallback
+)
+
+// decodePlan is the plan to decode JSON into a real type, built from the fake type BuildUnmarshalType builds for it, so the semantics always match UnmarshalJSON.
+type decodePlan struct {
+	kind decodePlanKind
+	fake reflect.Type
+	real reflect.Type
+	// fields and fieldsByName are the fields of a planStruct.
+	fields       []decodeField
+	fieldsByName map[string]int
+	// elem is the plan of planSlice elements and planMap values.
+	elem *decodePlan
+}
+
+type decodeField struct {
+	// name is the field's JSON name.
+	name      string
+	realIndex []int
+	// required is whether the field is versioned and not a pointer, so it must exist in the JSON.
+	required bool
+	// realPtr is whether the real field is a pointer, which is allocated if the field exists in the JSON.
+	realPtr bool
+	// ignored is whether encoding/json never decodes the field, because it's tagged '-'.
+	ignored bool
+	// zeroFake is the zero value of the fake field, which is set into the real field if the field doesn't exist in the JSON, exactly like UnmarshalJSON.
+	zeroFake reflect.Value
+	// strType is the str type of the field, if it's a 'str' field, else nil.
+	strType reflect.Type
+	// plan is the plan of the field's value, or of the value pointed to if realPtr.
+	plan *decodePlan
+}
+
+var decodePlans sync.Map // map[planKey]*decodePlan
+
+// getDecodePlan returns the cached plan to decode into realType at version with the tag names of opts, building it if necessary.
+func getDecodePlan(realType reflect.Type, version float64, opts Options) *decodePlan {
+	key := newPlanKey(realType, version, opts)
+	if plan, ok := decodePlans.Load(key); ok {
+		return plan.(*decodePlan)
+	}
+	plan := buildDecodePlan(buildUnmarshalType(JSONCodec, realType, version, true, opts), realType)
+	decodePlans.Store(key, plan)
+	return plan
+}
+
+func buildDecodePlan(fake reflect.Type, real reflect.Type) *decodePlan {
+	plan := &decodePlan{kind: planFallback, fake: fake, real: real}
+	if fake == real {
+		if isBasicKind(real.Kind()) && !hasUnmarshalMethod(real) {
+			plan.kind = planBasic
+		}
+		return plan
+	}
+
+	switch fake.Kind() {
+	case reflect.Slice:
+		plan.kind = planSlice
+		plan.elem = buildDecodePlan(fake.Elem(), real.Elem())
+	case reflect.Map:
+		if fake.Key().Kind() == reflect.String && !hasUnmarshalMethod(fake.Key()) {
+			plan.kind = planMap
+			plan.elem = buildDecodePlan(fake.Elem(), real.Elem())
+		}
+	case reflect.Struct:
+		plan.kind = planStruct
+		plan.fieldsByName = map[string]int{}
+		for i := 0; i < fake.NumField(); i++ {
+			fakeField := fake.Field(i)
+			if isExported := fakeField.PkgPath == ""; !isExported {
+				continue // UnmarshalJSON never sets unexported fields
+			}
+			realField, _ := real.FieldByName(fakeField.Name)
+
+			field := decodeField{
+				name:      JSONCodec.FieldName(fakeField),
+				realIndex: realField.Index,
+				realPtr:   realField.Type.Kind() == reflect.Ptr,
+				ignored:   fakeField.Tag.Get("json") == "-",
+				zeroFake:  reflect.Zero(fakeField.Type),
+			}
+			field.required = fakeField.Type.Kind() == reflect.Ptr && !field.realPtr
+
+			fakeValType := fakeField.Type
+			realValType := realField.Type
+			if fakeValType.Kind() == reflect.Ptr && (field.required || fakeValType != realValType) {
+				fakeValType = fakeValType.Elem() // pointer-ized or str field
+				if field.realPtr {
+					realValType = realValType.Elem()
+				}
+				if strType := JSONCodec.StrType(realValType.Kind()); strType != nil && fakeValType == strType {
+					field.strType = strType
+				}
+			} else if fakeValType.Kind() == reflect.Ptr {
+				field.realPtr = false // the fake and real pointers are the same type, so decode the pointer itself, like encoding/json
+			} else if strType := JSONCodec.StrType(realValType.Kind()); strType != nil && fakeValType == strType && fakeValType != realValType {
+				field.strType = strType // unversioned or optional str field
+			}
+			if field.strType == nil {
+				field.plan = buildDecodePlan(fakeValType, realValType)
+			}
+
+			if !field.ignored {
+				plan.fieldsByName[field.name] = len(plan.fields)
+			}
+			plan.fields = append(plan.fields, field)
+		}
+	}
+	return plan
+}
+
+func isBasicKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
+// hasUnmarshalMethod returns whether encoding/json would decode typ with a method, rather than by its kind.
+func hasUnmarshalMethod(typ reflect.Type) bool {
+	ptr := reflect.PtrTo(typ)
+	return ptr.Implements(reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()) || ptr.Implements(reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem())
+}
+
+// directDecodeError is an error decoding a value, such as a type mismatch, as opposed to an error setting the real object, such as a missing required field.
+// UnmarshalJSON returns decode errors directly, but prefixes errors setting nested values with where they are, so errors must be distinguished to return the same errors.
+type directDecodeError struct{ error }
+
+// directDecoder decodes valid JSON. All syntax errors must be checked before decoding, e.g. with json.Valid.
+type directDecoder struct {
+	data []byte
+	pos  int
+	// disallowUnknownFields is whether object keys which aren't fields of a struct return an error.
+	disallowUnknownFields bool
+}
+
+func (d *directDecoder) decode(plan *decodePlan, val reflect.Value) error {
+	switch plan.kind {
+	case planBasic:
+		return d.decodeBasic(val)
+	case planStruct:
+		return d.decodeStruct(plan, val)
+	case planSlice:
+		return d.decodeSlice(plan, val)
+	case planMap:
+		return d.decodeMap(plan, val)
+	default:
+		return d.decodeFallback(plan, val)
+	}
+}
+
+func (d *directDecoder) decodeFallback(plan *decodePlan, val reflect.Value) error {
+	fakeVal := reflect.New(plan.fake)
+	if err := d.unmarshalFallback(d.skipValue(), fakeVal.Interface()); err != nil {
+		return directDecodeError{err}
+	}
+	return setUnmarshalObj(JSONCodec, fakeVal.Elem(), val)
+}
+
+// unmarshalFallback decodes the valid JSON value bts into obj with encoding/json, disallowing unknown fields if the decoder does.
+func (d *directDecoder) unmarshalFallback(bts []byte, obj interface{}) error {
+	if !d.disallowUnknownFields {
+		return json.Unmarshal(bts, obj)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(bts))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(obj)
+}
+
+func (d *directDecoder) decodeBasic(val reflect.Value) error {
+	offset := d.pos
+	literal := d.skipValue()
+	if literal[0] == 'n' {
+		val.Set(reflect.Zero(val.Type())) // null leaves the fake value zero, which UnmarshalJSON sets
+		return nil
+	}
+
+	switch val.Kind() {
+	case reflect.String:
+		if literal[0] == '"' {
+			val.SetString(unquoteJSON(literal))
+			return nil
+		}
+	case reflect.Bool:
+		if literal[0] == 't' || literal[0] == 'f' {
+			val.SetBool(literal[0] == 't')
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if isNumber(literal) {
+			i, err := strconv.ParseInt(string(literal), 10, 64)
+			if err != nil || val.OverflowInt(i) {
+				return directDecodeError{&json.UnmarshalTypeError{Value: "number " + string(literal), Type: val.Type(), Offset: int64(d.pos)}}
+			}
+			val.SetInt(i)
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if isNumber(literal) {
+			u, err := strconv.ParseUint(string(literal), 10, 64)
+			if err != nil || val.OverflowUint(u) {
+				return directDecodeError{&json.UnmarshalTypeError{Value: "number " + string(literal), Type: val.Type(), Offset: int64(d.pos)}}
+			}
+			val.SetUint(u)
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		if isNumber(literal) {
+			f, err := strconv.ParseFloat(string(literal), val.Type().Bits())
+			if err != nil || val.OverflowFloat(f) {
+				return directDecodeError{&json.UnmarshalTypeError{Value: "number " + string(literal), Type: val.Type(), Offset: int64(d.pos)}}
+			}
+			val.SetFloat(f)
+			return nil
+		}
+	}
+	return directDecodeError{&json.UnmarshalTypeError{Value: literalKind(literal), Type: val.Type(), Offset: int64(offset)}}
+}
+
+func (d *directDecoder) decodeStruct(plan *decodePlan, val reflect.Value) error {
+	offset := d.pos
+	d.skipSpace()
+	if d.data[d.pos] != '{' && d.data[d.pos] != 'n' {
+		return directDecodeError{&json.UnmarshalTypeError{Value: literalKind(d.skipValue()), Type: val.Type(), Offset: int64(offset)}}
+	}
+
+	// seen is whether each field existed, and wasn't null. A null object leaves the fake struct zero, so no fields are seen.
+	seen := make([]bool, len(plan.fields))
+	for d.nextKey() {
+		key := d.readKey()
+		i, ok := plan.fieldsByName[key]
+		if !ok {
+			i, ok = plan.fieldByFold(key)
+		}
+		if !ok {
+			if d.disallowUnknownFields {
+				return directDecodeError{errors.New("json: unknown field " + strconv.Quote(key))}
+			}
+			d.skipValue()
+			continue
+		}
+		field := &plan.fields[i]
+
+		d.skipSpace()
+		if d.data[d.pos] == 'n' {
+			d.skipValue() // null leaves fake pointers nil, just like missing fields
+			seen[i] = false
+			continue
+		}
+		seen[i] = true
+
+		fieldVal := val.FieldByIndex(field.realIndex)
+		if field.realPtr {
+			if fieldVal.IsNil() {
+				fieldVal.Set(reflect.New(fieldVal.Type().Elem()))
+			}
+			fieldVal = fieldVal.Elem()
+		}
+
+		if field.strType != nil {
+			strVal := reflect.New(field.strType)
+			if err := strVal.Interface().(json.Unmarshaler).UnmarshalJSON(d.skipValue()); err != nil {
+				return directDecodeError{err}
+			}
+			fieldVal.Set(strVal.Elem().Convert(fieldVal.Type()))
+			continue
+		}
+
+		if err := d.decode(field.plan, fieldVal); err != nil {
+			if _, ok := err.(directDecodeError); ok {
+				return err
+			}
+			return errors.New("field '" + field.name + "':" + err.Error())
+		}
+	}
+
+	for i := range plan.fields {
+		if seen[i] {
+			continue
+		}
+		field := &plan.fields[i]
+		if field.required {
+			return UserError{"missing required field: " + field.name}
+		}
+		if field.zeroFake.Kind() == reflect.Ptr {
+			continue // nil pointers leave the real field unchanged
+		}
+		if err := setUnmarshalObj(JSONCodec, field.zeroFake, val.FieldByIndex(field.realIndex)); err != nil {
+			return errors.New("field '" + field.name + "':" + err.Error())
+		}
+	}
+	return nil
+}
+
+// fieldByFold returns the index of the field whose name matches key case-insensitively, like encoding/json.
+func (plan *decodePlan) fieldByFold(key string) (int, bool) {
+	for i, field := range plan.fields {
+		if !field.ignored && strings.EqualFold(field.name, key) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func (d *directDecoder) decodeSlice(plan *decodePlan, val reflect.Value) error {
+	offset := d.pos
+	d.skipSpace()
+	if d.data[d.pos] == 'n' {
+		d.skipValue() // null leaves the fake slice nil, which appends nothing
+		return nil
+	}
+	if d.data[d.pos] != '[' {
+		return directDecodeError{&json.UnmarshalTypeError{Value: literalKind(d.skipValue()), Type: val.Type(), Offset: int64(offset)}}
+	}
+	d.pos++
+	for d.nextElem() {
+		elem := reflect.New(val.Type().Elem()).Elem()
+		if err := d.decode(plan.elem, elem); err != nil {
+			if _, ok := err.(directDecodeError); ok {
+				return err
+			}
+			return errors.New("setting slice type '" + val.Type().String() + "': " + err.Error())
+		}
+		val.Set(reflect.Append(val, elem))
+	}
+	return nil
+}
+
+func (d *directDecoder) decodeMap(plan *decodePlan, val reflect.Value) error {
+	offset := d.pos
+	d.skipSpace()
+	if d.data[d.pos] == 'n' {
+		d.skipValue() // null leaves the fake map nil, which sets nothing
+		return nil
+	}
+	if d.data[d.pos] != '{' {
+		return directDecodeError{&json.UnmarshalTypeError{Value: literalKind(d.skipValue()), Type: val.Type(), Offset: int64(offset)}}
+	}
+	if val.IsNil() {
+		val.Set(reflect.MakeMap(val.Type()))
+	}
+	for d.nextKey() {
+		key := reflect.New(val.Type().Key()).Elem()
+		key.SetString(d.readKey())
+		elem := reflect.New(val.Type().Elem()).Elem()
+		if err := d.decode(plan.elem, elem); err != nil {
+			if _, ok := err.(directDecodeError); ok {
+				return err
+			}
+			return errors.New("setting map type '" + val.Type().String() + "' val: " + err.Error())
+		}
+		val.SetMapIndex(key, elem)
+	}
+	return nil
+}
+
+func (d *directDecoder) skipSpace() {
+	for d.pos < len(d.data) {
+		switch d.data[d.pos] {
+		case ' ', '\t', '\r', '\n':
+			d.pos++
+		default:
+			return
+		}
+	}
+}
+
+// nextKey advances past the '{' or ',' before the next key of an object, and returns whether there is one. If there isn't, it advances past the '}'.
+// If the value at the current position is null, it advances past it, and returns false.
+func (d *directDecoder) nextKey() bool {
+	d.skipSpace()
+	switch d.data[d.pos] {
+	case 'n':
+		d.pos += len("null")
+		return false
+	case '}':
+		d.pos++
+		return false
+	case '{', ',':
+		d.pos++
+		d.skipSpace()
+		if d.data[d.pos] == '}' {
+			d.pos++
+			return false
+		}
+		return true
+	}
+	return false // unreachable for valid JSON
+}
+
+// readKey reads an object key and the colon after it.
+func (d *directDecoder) readKey() string {
+	d.skipSpace()
+	key := unquoteJSON(d.skipValue())
+	d.skipSpace()
+	d.pos++ // ':'
+	return key
+}
+
+// nextElem advances past the ',' before the next element of an array, and returns whether there is one. The '[' must already have been read. If there isn't an element, it advances past the ']'.
+func (d *directDecoder) nextElem() bool {
+	d.skipSpace()
+	switch d.data[d.pos] {
+	case ']':
+		d.pos++
+		return false
+	case ',':
+		d.pos++
+	}
+	return true
+}
+
+// skipValue advances past the next value, and returns it.
+func (d *directDecoder) skipValue() []byte {
+	d.skipSpace()
+	start := d.pos
+	switch d.data[d.pos] {
+	case '"':
+		d.skipString()
+	case '{', '[':
+		depth := 0
+		for {
+			switch d.data[d.pos] {
+			case '"':
+				d.skipString()
+				continue
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+			}
+			d.pos++
+			if depth == 0 {
+				return d.data[start:d.pos]
+			}
+		}
+	default:
+		for d.pos < len(d.data) {
+			switch d.data[d.pos] {
+			case ',', '}', ']', ' ', '\t', '\r', '\n':
+				return d.data[start:d.pos]
+			}
+			d.pos++
+		}
+	}
+	return d.data[start:d.pos]
+}
+
+// skipString advances past the string at the current position.
+func (d *directDecoder) skipString() {
+	d.pos++ // opening quote
+	for {
+		switch d.data[d.pos] {
+		case '\\':
+			d.pos += 2
+		case '"':
+			d.pos++
+			return
+		default:
+			d.pos++
+		}
+	}
+}
+
+// unquoteJSON returns the value of the valid JSON string literal, exactly like encoding/json.
+func unquoteJSON(literal []byte) string {
+	unquoted := literal[1 : len(literal)-1]
+	if bytes.IndexByte(unquoted, '\\') == -1 && utf8.Valid(unquoted) {
+		return string(unquoted)
+	}
+	s := ""
+	json.Unmarshal(literal, &s) // escapes and invalid UTF-8 are rare, so just let encoding/json handle them
+	return s
+}
+
+func isNumber(literal []byte) bool {
+	return literal[0] == '-' || (literal[0] >= '0' && literal[0] <= '9')
+}
+
+// literalKind returns the kind of JSON value, as in encoding/json UnmarshalTypeError values.
+func literalKind(literal []byte) string {
+	switch literal[0] {
+	case '"':
+		return "string"
+	case '{':
+		return "object"
+	case '[':
+		return "array"
+	case 't', 'f':
+		return "bool"
+	case 'n':
+		return "null"
+	default:
+		return "number"
+	}
+}
@@ -0,0 +1,156 @@
+package apiver
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type directInner struct {
+	Name  string  `json:"name"`
+	Count int     `json:"count" api:"1.2,str"`
+	Note  *string `json:"note" api:"1.3"`
+}
+
+type directObj struct {
+	ID       int                    `json:"id"`
+	Name     string                 `json:"name" api:"1.1"`
+	Flag     *bool                  `json:"flag" api:"1.2,str"`
+	Ratio    float64                `json:"ratio" api:"1.1,str"`
+	Size     uint16                 `json:"size"`
+	Tags     []string               `json:"tags"`
+	Nick     *string                `json:"nick"`
+	When     time.Time              `json:"when"`
+	Inner    directInner            `json:"inner"`
+	InnerPtr *directInner           `json:"innerPtr" api:"1.1"`
+	Inners   []directInner          `json:"inners"`
+	ByName   map[string]directInner `json:"byName"`
+	Skipped  string                 `json:"-"`
+	New      int                    `json:"new" api:"2.0"`
+	hidden   int
+}
+
+func TestUnmarshalJSONDirectMatchesUnmarshalJSON(t *testing.T) {
+	inputs := []struct {
+		json    string
+		version float64
+	}{
+		{`{"id": 1, "name": "a", "ratio": "1.5", "inner": {"name": "i", "count": "3"}}`, 1.2},
+		{`{"id": 1, "name": "a", "ratio": 1.5, "inner": {"name": "i", "count": 3}, "flag": "0"}`, 1.2},
+		{`{"id": 1, "name": "a", "ratio": 1.5, "inner": {"name": "i", "count": 3}, "flag": true, "size": 7, "tags": ["x", "y"]}`, 1.3},
+		{`{"ID": 1, "NAME": "a", "Ratio": 1.5, "inner": {"name": "i", "count": 3}}`, 1.2},
+		{`{"id": 1, "name": "a", "ratio": 1.5, "inner": {"name": "i"}}`, 1.2},
+		{`{"id": 1, "name": "a", "ratio": 1.5, "inner": {"name": "i", "count": 3}, "inners": [{"name": "j", "count": 4}, {"name": "k", "count": "5", "note": "n"}]}`, 1.3},
+		{`{"id": 1, "name": "a", "ratio": 1.5, "inner": {"name": "i", "count": 3}, "inners": [{"name": "j"}]}`, 1.3},
+		{`{"id": 1, "name": "a", "ratio": 1.5, "inner": {"name": "i", "count": 3}, "byName": {"b": {"name": "j", "count": 4}}}`, 1.3},
+		{`{"id": 1, "name": "a", "ratio": 1.5, "inner": {"name": "i", "count": 3}, "byName": {"b": {"name": "j"}}}`, 1.3},
+		{`{"id": 1, "name": "a", "ratio": 1.5, "inner": {"name": "i", "count": 3}, "innerPtr": {"name": "p", "count": 2}}`, 1.2},
+		{`{"id": 1, "name": "a", "ratio": 1.5, "inner": {"name": "i", "count": 3}, "innerPtr": null, "nick": "n", "when": "2020-01-02T03:04:05Z"}`, 1.2},
+		{`{"id": 1, "name": "a", "ratio": 1.5, "inner": {"name": "i", "count": 3}, "new": 5, "Skipped": "s", "hidden": 1, "unknown": {"a": [1, "]"]}}`, 1.2},
+		{`{"id": 1, "name": "a\"bé", "ratio": 1.5, "inner": {"name": "i", "count": 3}}`, 1.2},
+		{`{"id": 1, "name": null, "ratio": 1.5, "inner": {"name": "i", "count": 3}}`, 1.2},
+		{`{"id": 1, "ratio": 1.5, "inner": null}`, 1.0},
+		{`{"id": 1, "ratio": 1.5}`, 1.1},
+		{`{"id": 1, "name": "a", "ratio": "x", "inner": {"name": "i", "count": 3}}`, 1.2},
+		{`{"id": 1, "name": "a", "ratio": 1.5, "inner": {"name": "i", "count": "x"}}`, 1.2},
+		{`{"id": 1, "name": "a", "ratio": 1.5, "inner": {"name": "i", "count": 3}, "flag": 0}`, 1.2},
+		{`{"id": 1, "name": "a", "ratio": 1.5, "inner": {"name": "i", "count": 3}, "size": 70000}`, 1.2},
+		{`{"id": 1, "name": "a", "ratio": 1.5, "inner": {"name": "i", "count": 3}, "size": -1}`, 1.2},
+		{`{"id": 1, "name": "a", "ratio": 1.5, "inner": {"name": "i", "count": 3}`, 1.2},
+		{`null`, 1.0},
+	}
+
+	for _, input := range inputs {
+		expected := directObj{Nick: new(string), Inners: []directInner{{Name: "existing"}}}
+		expectedErr := UnmarshalJSON([]byte(input.json), &expected, input.version)
+
+		actual := directObj{Nick: new(string), Inners: []directInner{{Name: "existing"}}}
+		actualErr := UnmarshalJSONDirect([]byte(input.json), &actual, input.version)
+
+		if (expectedErr == nil) != (actualErr == nil) {
+			t.Errorf("UnmarshalJSONDirect %v version %v error expected: %+v, actual: %+v", input.json, input.version, expectedErr, actualErr)
+			continue
+		}
+		if expectedErr != nil {
+			if _, isTypeErr := expectedErr.(*json.UnmarshalTypeError); !isTypeErr && expectedErr.Error() != actualErr.Error() {
+				t.Errorf("UnmarshalJSONDirect %v version %v error expected: %+v, actual: %+v", input.json, input.version, expectedErr, actualErr)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(expected, actual) {
+			t.Errorf("UnmarshalJSONDirect %v version %v expected: %+v, actual: %+v", input.json, input.version, expected, actual)
+		}
+	}
+}
+
+func TestUnmarshalJSONDirectTypeError(t *testing.T) {
+	obj := directObj{}
+	err := UnmarshalJSONDirect([]byte(`{"id": "1"}`), &obj, 1.0)
+	if typeErr, ok := err.(*json.UnmarshalTypeError); !ok {
+		t.Errorf("UnmarshalJSONDirect error expected: %+v, actual: %+v", "*json.UnmarshalTypeError", err)
+	} else if typeErr.Value != "string" || typeErr.Type != reflect.TypeOf(0) {
+		t.Errorf("UnmarshalJSONDirect error expected: %+v, actual: %+v", "string into int", typeErr)
+	}
+}
+
+func TestUnmarshalJSONDirectSlice(t *testing.T) {
+	objs := []directInner{}
+	err := UnmarshalJSONDirect([]byte(`[{"name": "a", "count": 1}, {"name": "b", "count": "2"}]`), &objs, 1.2)
+	if err != nil {
+		t.Fatalf("UnmarshalJSONDirect error expected: nil, actual: %+v", err)
+	}
+	expected := []directInner{{Name: "a", Count: 1}, {Name: "b", Count: 2}}
+	if !reflect.DeepEqual(expected, objs) {
+		t.Errorf("UnmarshalJSONDirect expected: %+v, actual: %+v", expected, objs)
+	}
+
+	err = UnmarshalJSONDirect([]byte(`[{"name": "a"}]`), &objs, 1.2)
+	if err == nil || !strings.Contains(err.Error(), "missing required field: count") {
+		t.Errorf("UnmarshalJSONDirect error expected: %+v, actual: %+v", "missing required field: count", err)
+	}
+}
+
+func TestUnmarshalJSONDirectNonPointer(t *testing.T) {
+	obj := directObj{}
+	if err := UnmarshalJSONDirect([]byte(`{}`), obj, 1.0); err == nil {
+		t.Errorf("UnmarshalJSONDirect non-pointer error expected: %+v, actual: %+v", "object must be a pointer", err)
+	}
+}
+
+const benchmarkJSON = `{"id": 1, "name": "alpha", "flag": "true", "ratio": "1.5", "size": 7, "tags": ["x", "y", "z"], "nick": "a", "inner": {"name": "i", "count": "3", "note": "n"}, "inners": [{"name": "j", "count": 4}, {"name": "k", "count": 5}, {"name": "l", "count": 6}], "byName": {"b": {"name": "m", "count": 7}}}`
+
+func BenchmarkUnmarshalJSON(b *testing.B) {
+	bts := []byte(benchmarkJSON)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		obj := directObj{}
+		if err := UnmarshalJSON(bts, &obj, 1.3); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshalJSONDirect(b *testing.B) {
+	bts := []byte(benchmarkJSON)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		obj := directObj{}
+		if err := UnmarshalJSONDirect(bts, &obj, 1.3); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkEncodingJSONUnmarshal is the baseline of unversioned decoding. The str fields are sent as JSON numbers and bools, which encoding/json requires.
+func BenchmarkEncodingJSONUnmarshal(b *testing.B) {
+	bts := []byte(strings.NewReplacer(`"true"`, `true`, `"1.5"`, `1.5`, `"3"`, `3`).Replace(benchmarkJSON))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		obj := directObj{}
+		if err := json.Unmarshal(bts, &obj); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
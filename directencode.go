@@ -0,0 +1,553 @@
+package apiver
+
+import (
+	"encoding"
+	"encoding/json"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// MarshalJSONDirect serializes realObj to JSON, exactly like MarshalJSON, but without building and copying into an intermediate object.
+// It walks realObj and writes JSON directly, via a plan cached per type and version, so the output is byte-for-byte identical to MarshalJSON, with far fewer allocations. Values of types without any versioned fields, such as time.Time, are still encoded by encoding/json.
+func MarshalJSONDirect(realObj interface{}, version float64) ([]byte, error) {
+	return MarshalJSONDirectWithOptions(realObj, version, Options{})
+}
+
+// MarshalJSONDirectWithOptions is MarshalJSONDirect, with the tag names and behavior of opts, byte-for-byte identical to MarshalJSONWithOptions.
+func MarshalJSONDirectWithOptions(realObj interface{}, version float64, opts Options) ([]byte, error) {
+	return marshalPooled(func(dst []byte) ([]byte, error) { return AppendJSONDirectWithOptions(dst, realObj, version, opts) })
+}
+
+// marshalPooled returns a copy of the bytes appended by appendJSON to a pooled buffer, so growing the buffer doesn't allocate.
+func marshalPooled(appendJSON func(dst []byte) ([]byte, error)) ([]byte, error) {
+	buf := encodeBufPool.Get().(*[]byte)
+	defer encodeBufPool.Put(buf)
+
+	bts, err := appendJSON((*buf)[:0])
+	*buf = bts
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), bts...), nil
+}
+
+// WriteJSONDirect is MarshalJSONDirect, writing the JSON to w. Unlike JSONEncoder.Encode, no newline is written after the JSON.
+func WriteJSONDirect(w io.Writer, realObj interface{}, version float64) error {
+	return WriteJSONDirectWithOptions(w, realObj, version, Options{})
+}
+
+// WriteJSONDirectWithOptions is WriteJSONDirect, with the tag names and behavior of opts.
+func WriteJSONDirectWithOptions(w io.Writer, realObj interface{}, version float64, opts Options) error {
+	buf := encodeBufPool.Get().(*[]byte)
+	defer encodeBufPool.Put(buf)
+
+	bts, err := AppendJSONDirectWithOptions((*buf)[:0], realObj, version, opts)
+	*buf = bts
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(bts)
+	return err
+}
+
+// AppendJSONDirect is MarshalJSONDirect, appending the JSON to dst and returning the extended buffer. This allows callers to reuse their own buffers.
+func AppendJSONDirect(dst []byte, realObj interface{}, version float64) ([]byte, error) {
+	return AppendJSONDirectWithOptions(dst, realObj, version, Options{})
+}
+
+// AppendJSONDirectWithOptions is AppendJSONDirect, with the tag names and behavior of opts.
+func AppendJSONDirectWithOptions(dst []byte, realObj interface{}, version float64, opts Options) ([]byte, error) {
+	if m, ok := versionMarshaler(realObj); ok && opts.marshalHooks() {
+		bts, err := m.MarshalJSONVersion(version)
+		if err != nil {
+			return dst, err
+		}
+		return append(dst, bts...), nil
+	}
+
+	if realObj == nil {
+		return append(dst, "null"...), nil
+	}
+	obj := reflect.ValueOf(realObj)
+	if obj.Kind() == reflect.Ptr {
+		if obj.IsNil() {
+			return append(dst, "null"...), nil
+		}
+		obj = obj.Elem()
+	} else {
+		// MarshalJSON always encodes an addressable copy, which calls pointer-receiver MarshalJSON methods, so encode one too.
+		addressable := reflect.New(obj.Type()).Elem()
+		addressable.Set(obj)
+		obj = addressable
+	}
+	if err := opts.checkType(JSONCodec, obj.Type()); err != nil {
+		return dst, err
+	}
+	return appendEncodePlan(dst, getEncodePlan(obj.Type(), version, opts), obj, false)
+}
+
+var encodeBufPool = sync.Pool{New: func() interface{} { return new([]byte) }}
+
+// encodePlanKind is how an encodePlan encodes a value.
+type encodePlanKind int
+
+const (
+	// encodeSame encodes values of types without versioned fields with encoding/json.
+	encodeSame encodePlanKind = iota
+	// encodeBasic encodes strings, bools, and numbers directly.
+	encodeBasic
+	// encodePtr encodes the value pointed to, or null.
+	encodePtr
+	// encodeStruct encodes structs with versioned fields, field by field.
+	encodeStruct
+	// encodeSlice encodes slices of versioned structs, element by element.
+	encodeSlice
+	// encodeMap encodes maps with string keys of versioned structs, value by value.
+	encodeMap
+	// encodeCopy copies values into a new fake value, and encodes it with encoding/json, exactly like MarshalJSON.
+	encodeCopy
+)
+
+// encodePlan is the plan to encode a real type as JSON, built from the fake type BuildMarshalObj builds for it, so the output always matches MarshalJSON.
+type encodePlan struct {
+	kind encodePlanKind
+	fake reflect.Type
+	real reflect.Type
+	// fields are the fields of an encodeStruct, in order.
+	fields []encodeField
+	// elem is the plan of encodePtr, encodeSlice, and encodeMap elements.
+	elem *encodePlan
+}
+
+type encodeField struct {
+	// key is the JSON object key, quoted and escaped, with the colon.
+	key       []byte
+	realIndex []int
+	// pointerized is whether the field is versioned and not a pointer, which is a non-nil pointer in the fake object, and thus is never empty.
+	pointerized bool
+	// omit returns whether the field is omitted, per its omitempty or omitzero options, else nil.
+	omit   func(reflect.Value) bool
+	quoted bool
+	// plan is the plan of the field's value.
+	plan *encodePlan
+}
+
+// planKey is the key of cached decode and encode plans.
+type planKey struct {
+	typ     reflect.Type
+	version float64
+	// tags are the options with only the tag names set, which are all that plans are built from.
+	tags Options
+	// schemaGeneration is the schemaGeneration the plan was built after, so plans built before a RegisterSchema aren't used after it, even if they're stored after it.
+	schemaGeneration uint64
+}
+
+// newPlanKey returns the key of the plans of realType at version with the tag names of opts.
+func newPlanKey(realType reflect.Type, version float64, opts Options) planKey {
+	opts = opts.withDefaults()
+	tags := Options{TagName: opts.TagName, PropertyStr: opts.PropertyStr, PropertyRequired: opts.PropertyRequired, PropertyOptional: opts.PropertyOptional, PropertyDeprecated: opts.PropertyDeprecated}
+	return planKey{typ: realType, version: version, tags: tags, schemaGeneration: getSchemaGeneration()}
+}
+
+var encodePlans sync.Map // map[planKey]*encodePlan
+
+// getEncodePlan returns the cached plan to encode realType at version with the tag names of opts, building it if necessary.
+func getEncodePlan(realType reflect.Type, version float64, opts Options) *encodePlan {
+	key := newPlanKey(realType, version, opts)
+	if plan, ok := encodePlans.Load(key); ok {
+		return plan.(*encodePlan)
+	}
+	plan := buildEncodePlan(buildUnmarshalType(JSONCodec, realType, version, false, opts), realType)
+	encodePlans.Store(key, plan)
+	return plan
+}
+
+var jsonNumberType = reflect.TypeOf(json.Number(""))
+
+func buildEncodePlan(fake reflect.Type, real reflect.Type) *encodePlan {
+	plan := &encodePlan{kind: encodeSame, fake: fake, real: real}
+	if fake == real {
+		switch {
+		case hasMarshalMethod(real):
+		case real.Kind() == reflect.Ptr:
+			plan.kind = encodePtr
+			plan.elem = buildEncodePlan(real.Elem(), real.Elem())
+		case isBasicKind(real.Kind()) && real != jsonNumberType:
+			plan.kind = encodeBasic
+		}
+		return plan
+	}
+
+	plan.kind = encodeCopy
+	switch fake.Kind() {
+	case reflect.Slice:
+		plan.kind = encodeSlice
+		plan.elem = buildEncodePlan(fake.Elem(), real.Elem())
+	case reflect.Map:
+		if fake.Key() == real.Key() && fake.Key().Kind() == reflect.String {
+			plan.kind = encodeMap
+			plan.elem = buildEncodePlan(fake.Elem(), real.Elem())
+		}
+	case reflect.Struct:
+		plan.kind = encodeStruct
+		plan.fields = buildEncodeFields(fake, real)
+	}
+	return plan
+}
+
+// buildEncodeFields returns the fields encoding/json encodes for the fake struct. Fake structs never have embedded fields, so these are its exported fields without duplicate names.
+func buildEncodeFields(fake reflect.Type, real reflect.Type) []encodeField {
+	fields := []encodeField{}
+	names := []string{}
+	tagged := []bool{}
+	for i := 0; i < fake.NumField(); i++ {
+		fakeField := fake.Field(i)
+		if isExported := fakeField.PkgPath == ""; !isExported {
+			continue
+		}
+		key, quoted, ok := jsonFieldKey(fakeField)
+		if !ok {
+			continue
+		}
+		tag := fakeField.Tag.Get("json")
+		name := string(key)
+		isTagged := strings.Split(tag, ",")[0] != "" && name != `"`+fakeField.Name+`":`
+		opts := ""
+		if i := strings.Index(tag, ","); i != -1 {
+			opts = tag[i:] + ","
+		}
+
+		realField, _ := real.FieldByName(fakeField.Name)
+
+		field := encodeField{
+			key:         key,
+			realIndex:   realField.Index,
+			pointerized: fakeField.Type.Kind() == reflect.Ptr && realField.Type.Kind() != reflect.Ptr,
+		}
+
+		fakeValType := fakeField.Type
+		if field.pointerized {
+			fakeValType = fakeValType.Elem()
+		}
+		field.plan = buildEncodePlan(fakeValType, realField.Type)
+
+		quotedType := fakeField.Type
+		if quotedType.Name() == "" && quotedType.Kind() == reflect.Ptr {
+			quotedType = quotedType.Elem()
+		}
+		field.quoted = quoted && isBasicKind(quotedType.Kind())
+
+		field.omit = buildOmit(fakeField.Type, field.plan, field.pointerized, strings.Contains(opts, ",omitempty,"), strings.Contains(opts, ",omitzero,"))
+
+		fields = append(fields, field)
+		names = append(names, name)
+		tagged = append(tagged, isTagged)
+	}
+
+	// encoding/json omits fields with duplicate names, unless exactly one of them is tagged.
+	dominant := []encodeField{}
+	for i, field := range fields {
+		isDominant := true
+		for j := range fields {
+			if i != j && names[i] == names[j] && (tagged[j] || !tagged[i]) {
+				isDominant = false
+			}
+		}
+		if isDominant {
+			dominant = append(dominant, field)
+		}
+	}
+	return dominant
+}
+
+type isZeroer interface {
+	IsZero() bool
+}
+
+var isZeroerType = reflect.TypeOf((*isZeroer)(nil)).Elem()
+
+// buildOmit returns the function to determine whether a real field value is omitted, exactly like encoding/json omits the fake field of fakeType, or nil if it's never omitted.
+func buildOmit(fakeType reflect.Type, plan *encodePlan, pointerized bool, omitEmpty bool, omitZero bool) func(reflect.Value) bool {
+	if pointerized {
+		// The fake field is a non-nil pointer, which is never empty, and is only zero if its IsZero method says so.
+		if !omitZero || !fakeType.Implements(isZeroerType) {
+			return nil
+		}
+		return func(val reflect.Value) bool {
+			return addressable(val).Addr().Interface().(isZeroer).IsZero()
+		}
+	}
+
+	if plan.fake != plan.real {
+		// The fake field is a struct built with reflect.StructOf, which is never empty, and has no methods.
+		if !omitZero {
+			return nil
+		}
+		return func(val reflect.Value) bool {
+			fakeVal := reflect.New(plan.fake).Elem()
+			return doCopyIntoMarshalObj(fakeVal, val, plan.fake.String()) == nil && fakeVal.IsZero()
+		}
+	}
+
+	isZero := func(val reflect.Value) bool { return val.IsZero() }
+	if omitZero {
+		switch {
+		case fakeType.Kind() == reflect.Interface && fakeType.Implements(isZeroerType):
+			isZero = func(val reflect.Value) bool {
+				return val.IsNil() || (val.Elem().Kind() == reflect.Ptr && val.Elem().IsNil()) || val.Interface().(isZeroer).IsZero()
+			}
+		case fakeType.Kind() == reflect.Ptr && fakeType.Implements(isZeroerType):
+			isZero = func(val reflect.Value) bool { return val.IsNil() || val.Interface().(isZeroer).IsZero() }
+		case fakeType.Implements(isZeroerType):
+			isZero = func(val reflect.Value) bool { return val.Interface().(isZeroer).IsZero() }
+		case reflect.PtrTo(fakeType).Implements(isZeroerType):
+			isZero = func(val reflect.Value) bool { return addressable(val).Addr().Interface().(isZeroer).IsZero() }
+		}
+	}
+
+	switch {
+	case omitEmpty && omitZero:
+		return func(val reflect.Value) bool { return isEmptyJSONValue(val) || isZero(val) }
+	case omitEmpty:
+		return isEmptyJSONValue
+	case omitZero:
+		return isZero
+	default:
+		return nil
+	}
+}
+
+// addressable returns val if it's addressable, else an addressable copy of it.
+func addressable(val reflect.Value) reflect.Value {
+	if val.CanAddr() {
+		return val
+	}
+	valCopy := reflect.New(val.Type()).Elem()
+	valCopy.Set(val)
+	return valCopy
+}
+
+// isEmptyJSONValue returns whether encoding/json considers val empty, for omitempty.
+func isEmptyJSONValue(val reflect.Value) bool {
+	switch val.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return val.Len() == 0
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.Interface, reflect.Ptr:
+		return val.IsZero()
+	}
+	return false
+}
+
+// jsonFieldKey returns the JSON object key encoding/json writes for field, quoted and escaped, with the colon, and whether it has the ',string' option, or false if the field is never encoded.
+// The key is found by encoding a struct of only the field, so the tag is always parsed exactly like encoding/json, which isn't the same in all Go versions.
+func jsonFieldKey(field reflect.StructField) ([]byte, bool, bool) {
+	probe := reflect.New(reflect.StructOf([]reflect.StructField{{Name: field.Name, Type: reflect.TypeOf(0), Tag: field.Tag}})).Elem()
+	probe.Field(0).SetInt(1) // non-zero, so omitempty and omitzero don't omit it
+	bts, err := json.Marshal(probe.Interface())
+	if err != nil || len(bts) < 2 || bts[1] != '"' {
+		return nil, false, false
+	}
+	d := &directDecoder{data: bts, pos: 1}
+	d.skipString()
+	return append([]byte(nil), bts[1:d.pos+1]...), bts[d.pos+1] == '"', true
+}
+
+// hasMarshalMethod returns whether encoding/json would encode typ with a method, rather than by its kind.
+func hasMarshalMethod(typ reflect.Type) bool {
+	ptr := reflect.PtrTo(typ)
+	return ptr.Implements(reflect.TypeOf((*json.Marshaler)(nil)).Elem()) || ptr.Implements(reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem())
+}
+
+// appendEncodePlan appends the JSON of val, per plan. If quoted, basic values are encoded inside JSON strings, for the ',string' option.
+func appendEncodePlan(dst []byte, plan *encodePlan, val reflect.Value, quoted bool) ([]byte, error) {
+	switch plan.kind {
+	case encodeBasic:
+		return appendBasic(dst, val, quoted)
+	case encodePtr:
+		if val.IsNil() {
+			return append(dst, "null"...), nil
+		}
+		return appendEncodePlan(dst, plan.elem, val.Elem(), quoted)
+	case encodeStruct:
+		return appendStruct(dst, plan, val)
+	case encodeSlice:
+		if val.IsNil() {
+			return append(dst, "null"...), nil
+		}
+		dst = append(dst, '[')
+		for i := 0; i < val.Len(); i++ {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			var err error
+			if dst, err = appendEncodePlan(dst, plan.elem, val.Index(i), false); err != nil {
+				return dst, err
+			}
+		}
+		return append(dst, ']'), nil
+	case encodeMap:
+		return appendMap(dst, plan, val)
+	case encodeCopy:
+		fakeVal := reflect.New(plan.fake)
+		if err := CopyIntoMarshalObj(fakeVal, val); err != nil {
+			return dst, err
+		}
+		return appendMarshal(dst, fakeVal.Interface(), false)
+	default:
+		if val.CanAddr() {
+			return appendMarshal(dst, val.Addr().Interface(), quoted && val.Type() == jsonNumberType)
+		}
+		return appendMarshal(dst, val.Interface(), quoted && val.Type() == jsonNumberType)
+	}
+}
+
+func appendMarshal(dst []byte, obj interface{}, quoted bool) ([]byte, error) {
+	bts, err := json.Marshal(obj)
+	if err != nil {
+		return dst, err
+	}
+	if quoted {
+		dst = append(dst, '"')
+		dst = append(dst, bts...)
+		return append(dst, '"'), nil
+	}
+	return append(dst, bts...), nil
+}
+
+func appendStruct(dst []byte, plan *encodePlan, val reflect.Value) ([]byte, error) {
+	dst = append(dst, '{')
+	first := true
+	for i := range plan.fields {
+		field := &plan.fields[i]
+		fieldVal := val.FieldByIndex(field.realIndex)
+		if field.omit != nil && field.omit(fieldVal) {
+			continue
+		}
+		if !first {
+			dst = append(dst, ',')
+		}
+		first = false
+		dst = append(dst, field.key...)
+
+		if field.pointerized {
+			fieldVal = addressable(fieldVal) // the fake pointer is always addressable
+		}
+		var err error
+		if dst, err = appendEncodePlan(dst, field.plan, fieldVal, field.quoted); err != nil {
+			return dst, err
+		}
+	}
+	return append(dst, '}'), nil
+}
+
+func appendMap(dst []byte, plan *encodePlan, val reflect.Value) ([]byte, error) {
+	if val.IsNil() {
+		return append(dst, "null"...), nil
+	}
+	keys := val.MapKeys()
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+
+	dst = append(dst, '{')
+	for i, key := range keys {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = appendJSONString(dst, key.String())
+		dst = append(dst, ':')
+		var err error
+		if dst, err = appendEncodePlan(dst, plan.elem, val.MapIndex(key), false); err != nil {
+			return dst, err
+		}
+	}
+	return append(dst, '}'), nil
+}
+
+// appendBasic appends the JSON of a string, bool, or number, exactly like encoding/json.
+func appendBasic(dst []byte, val reflect.Value, quoted bool) ([]byte, error) {
+	if val.Kind() == reflect.String {
+		if !quoted {
+			return appendJSONString(dst, val.String()), nil
+		}
+		// encoding/json quotes the JSON string, without escaping HTML again.
+		inner := appendJSONString(nil, val.String())
+		dst = append(dst, '"')
+		for _, b := range inner {
+			if b == '"' || b == '\\' {
+				dst = append(dst, '\\')
+			}
+			dst = append(dst, b)
+		}
+		return append(dst, '"'), nil
+	}
+
+	if quoted {
+		dst = append(dst, '"')
+	}
+	switch val.Kind() {
+	case reflect.Bool:
+		dst = strconv.AppendBool(dst, val.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		dst = strconv.AppendInt(dst, val.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		dst = strconv.AppendUint(dst, val.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		var err error
+		if dst, err = appendJSONFloat(dst, val); err != nil {
+			return dst, err
+		}
+	}
+	if quoted {
+		dst = append(dst, '"')
+	}
+	return dst, nil
+}
+
+// appendJSONFloat appends the float, formatted exactly like encoding/json.
+func appendJSONFloat(dst []byte, val reflect.Value) ([]byte, error) {
+	f := val.Float()
+	bits := val.Type().Bits()
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return dst, &json.UnsupportedValueError{Value: val, Str: strconv.FormatFloat(f, 'g', -1, bits)}
+	}
+
+	abs := math.Abs(f)
+	format := byte('f')
+	if abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) || bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
+	dst = strconv.AppendFloat(dst, f, format, -1, bits)
+	if format == 'e' {
+		// clean up e-09 to e-9
+		if n := len(dst); n >= 4 && dst[n-4] == 'e' && dst[n-3] == '-' && dst[n-2] == '0' {
+			dst[n-2] = dst[n-1]
+			dst = dst[:n-1]
+		}
+	}
+	return dst, nil
+}
+
+// appendJSONString appends s as a JSON string, escaped exactly like encoding/json, including HTML characters.
+func appendJSONString(dst []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < 0x20 || c >= utf8.RuneSelf || c == '"' || c == '\\' || c == '<' || c == '>' || c == '&' {
+			// escapes are rare, so just let encoding/json handle them
+			bts, _ := json.Marshal(s)
+			return append(dst, bts...)
+		}
+	}
+	dst = append(dst, '"')
+	dst = append(dst, s...)
+	return append(dst, '"')
+}
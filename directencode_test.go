@@ -0,0 +1,178 @@
+package apiver
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+)
+
+type directEncodeInner struct {
+	Name  string  `json:"name"`
+	Count int     `json:"count,omitempty" api:"1.2"`
+	Note  *string `json:"note" api:"1.3"`
+}
+
+type directEncodeObj struct {
+	ID        int                          `json:"id"`
+	Name      string                       `json:"name" api:"1.1"`
+	Flag      *bool                        `json:"flag,omitempty" api:"1.2"`
+	Ratio     float64                      `json:"ratio,string" api:"1.1"`
+	Small     float32                      `json:"small"`
+	Size      uint16                       `json:"size,omitempty"`
+	Quoted    string                       `json:"quoted,string"`
+	Tags      []string                     `json:"tags"`
+	Nick      *string                      `json:"nick"`
+	When      time.Time                    `json:"when" api:"1.1"`
+	WhenZero  time.Time                    `json:"whenZero,omitzero" api:"1.1"`
+	Inner     directEncodeInner            `json:"inner"`
+	InnerZero directEncodeInner            `json:"innerZero,omitzero"`
+	InnerPtr  *directEncodeInner           `json:"innerPtr" api:"1.1"`
+	Inners    []directEncodeInner          `json:"inners"`
+	ByName    map[string]directEncodeInner `json:"byName"`
+	Any       interface{}                  `json:"any,omitempty"`
+	Raw       json.RawMessage              `json:"raw,omitempty"`
+	Skipped   string                       `json:"-"`
+	Untagged  int
+	Invalid   int `json:"a'b"`
+	New       int `json:"new" api:"2.0"`
+	hidden    int
+}
+
+func TestMarshalJSONDirectMatchesMarshalJSON(t *testing.T) {
+	note := "note"
+	flag := false
+	objs := []interface{}{
+		directEncodeObj{},
+		&directEncodeObj{
+			ID:       1,
+			Name:     "a<b>&\"c\" é",
+			Flag:     &flag,
+			Ratio:    1.5,
+			Small:    1e-7,
+			Size:     7,
+			Quoted:   "q\"<",
+			Tags:     []string{"x", "y"},
+			Nick:     &note,
+			When:     time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+			Inner:    directEncodeInner{Name: "i", Count: 3, Note: &note},
+			InnerPtr: &directEncodeInner{Name: "p"},
+			Inners:   []directEncodeInner{{Name: "j", Count: 4}, {Name: "k"}},
+			ByName:   map[string]directEncodeInner{"b": {Name: "m"}, "a": {Name: "n", Count: 1}},
+			Any:      map[string]int{"z": 1},
+			Raw:      json.RawMessage(`{"a": 1}`),
+			Skipped:  "s",
+			Untagged: 2,
+			Invalid:  3,
+			New:      5,
+			hidden:   6,
+		},
+		&directEncodeObj{Ratio: 1e21, Small: -0.5, Inners: []directEncodeInner{}, ByName: map[string]directEncodeInner{}},
+		[]directEncodeInner{{Name: "a"}, {Name: "b", Count: 2}},
+		[]directEncodeInner(nil),
+		map[string]directEncodeInner{"x": {Name: "a"}},
+		map[int]directEncodeInner{2: {Name: "a"}, 1: {Name: "b"}},
+		time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		"str",
+		42,
+		(*directEncodeObj)(nil),
+		nil,
+	}
+
+	for _, version := range []float64{1.0, 1.1, 1.2, 1.3, 2.0} {
+		for _, obj := range objs {
+			expected, expectedErr := MarshalJSON(obj, version)
+			actual, actualErr := MarshalJSONDirect(obj, version)
+			if expectedErr != nil || actualErr != nil {
+				t.Errorf("MarshalJSONDirect %T version %v error expected: %+v, actual: %+v", obj, version, expectedErr, actualErr)
+				continue
+			}
+			if !bytes.Equal(expected, actual) {
+				t.Errorf("MarshalJSONDirect %T version %v expected: %s, actual: %s", obj, version, expected, actual)
+			}
+		}
+	}
+}
+
+func TestMarshalJSONDirectUnsupportedValue(t *testing.T) {
+	obj := directEncodeObj{Small: float32(math.Inf(1))}
+	if _, err := MarshalJSONDirect(obj, 1.0); err == nil {
+		t.Errorf("MarshalJSONDirect Inf error expected: %+v, actual: %+v", "json: unsupported value: +Inf", err)
+	} else if _, ok := err.(*json.UnsupportedValueError); !ok {
+		t.Errorf("MarshalJSONDirect Inf error expected: %+v, actual: %+v", "*json.UnsupportedValueError", err)
+	}
+}
+
+func TestWriteJSONDirect(t *testing.T) {
+	obj := directEncodeInner{Name: "a", Count: 2}
+	expected, err := MarshalJSON(obj, 1.2)
+	if err != nil {
+		t.Fatalf("MarshalJSON error expected: nil, actual: %+v", err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := WriteJSONDirect(buf, obj, 1.2); err != nil {
+		t.Fatalf("WriteJSONDirect error expected: nil, actual: %+v", err)
+	}
+	if buf.String() != string(expected) {
+		t.Errorf("WriteJSONDirect expected: %s, actual: %s", expected, buf.String())
+	}
+
+	appended, err := AppendJSONDirect([]byte("prefix"), obj, 1.2)
+	if err != nil {
+		t.Fatalf("AppendJSONDirect error expected: nil, actual: %+v", err)
+	}
+	if string(appended) != "prefix"+string(expected) {
+		t.Errorf("AppendJSONDirect expected: %s, actual: %s", "prefix"+string(expected), appended)
+	}
+}
+
+func benchmarkEncodeObj() *directEncodeObj {
+	note := "note"
+	flag := true
+	return &directEncodeObj{
+		ID:       1,
+		Name:     "alpha",
+		Flag:     &flag,
+		Ratio:    1.5,
+		Size:     7,
+		Tags:     []string{"x", "y", "z"},
+		Nick:     &note,
+		Inner:    directEncodeInner{Name: "i", Count: 3, Note: &note},
+		Inners:   []directEncodeInner{{Name: "j", Count: 4}, {Name: "k", Count: 5}, {Name: "l", Count: 6}},
+		ByName:   map[string]directEncodeInner{"b": {Name: "m", Count: 7}},
+		Untagged: 2,
+	}
+}
+
+func BenchmarkMarshalJSON(b *testing.B) {
+	obj := benchmarkEncodeObj()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := MarshalJSON(obj, 1.3); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMarshalJSONDirect(b *testing.B) {
+	obj := benchmarkEncodeObj()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := MarshalJSONDirect(obj, 1.3); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkEncodingJSONMarshal is the baseline of unversioned encoding.
+func BenchmarkEncodingJSONMarshal(b *testing.B) {
+	obj := benchmarkEncodeObj()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := json.Marshal(obj); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
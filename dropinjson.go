@@ -3,7 +3,6 @@ package apiver
 import (
 	"encoding/json"
 	"io"
-	"reflect"
 )
 
 // NewJSON returns a encoding/json compatible object for marshalling and unmarshalling.
@@ -19,29 +18,40 @@ func NewJSON(version float64) EncodingJSONDropIn {
 	return EncodingJSONDropIn{Version: version}
 }
 
+// NewJSONWithOptions is NewJSON, with the tag names and behavior of opts, for every call of the returned object and its decoders and encoders.
+func NewJSONWithOptions(version float64, opts Options) EncodingJSONDropIn {
+	return EncodingJSONDropIn{Version: version, Options: opts}
+}
+
 type EncodingJSONDropIn struct {
 	Version float64
+	Options Options
 }
 
 func (j EncodingJSONDropIn) Marshal(v interface{}) ([]byte, error) {
-	return MarshalJSON(v, j.Version)
+	return MarshalJSONWithOptions(v, j.Version, j.Options)
 }
 
 func (j EncodingJSONDropIn) MarshalIndent(v interface{}, prefix, indent string) ([]byte, error) {
-	return MarshalJSONIndent(v, prefix, indent, j.Version)
+	return MarshalJSONIndentWithOptions(v, prefix, indent, j.Version, j.Options)
 }
 
 func (j EncodingJSONDropIn) Unmarshal(data []byte, v interface{}) error {
-	return UnmarshalJSON(data, v, j.Version)
+	return UnmarshalJSONWithOptions(data, v, j.Version, j.Options)
 }
 
 type JSONDecoder struct {
 	Version float64
 	D       *json.Decoder
+	Options Options
 }
 
 func (j EncodingJSONDropIn) NewDecoder(r io.Reader) *JSONDecoder {
-	return &JSONDecoder{Version: j.Version, D: json.NewDecoder(r)}
+	d := &JSONDecoder{Version: j.Version, D: json.NewDecoder(r), Options: j.Options}
+	if j.Options.DisallowUnknownFields {
+		d.D.DisallowUnknownFields()
+	}
+	return d
 }
 func (d *JSONDecoder) Buffered() io.Reader        { return d.D.Buffered() }
 func (d *JSONDecoder) DisallowUnknownFields()     { d.D.DisallowUnknownFields() }
@@ -49,46 +59,41 @@ func (d *JSONDecoder) More() bool                 { return d.D.More() }
 func (d *JSONDecoder) Token() (json.Token, error) { return d.D.Token() }
 func (d *JSONDecoder) UseNumber()                 { d.D.UseNumber() }
 func (d *JSONDecoder) Decode(realObj interface{}) error {
-	// TODO reduce duplication with UnmarshalJSON
-	obj := reflect.ValueOf(realObj)
-	if obj.Kind() != reflect.Ptr {
-		return InternalError{"object must be a pointer"}
+	if u, ok := versionUnmarshaler(realObj); ok && d.Options.unmarshalHooks() {
+		raw := json.RawMessage{}
+		if err := d.D.Decode(&raw); err != nil {
+			return err
+		}
+		return u.UnmarshalJSONVersion(raw, d.Version)
 	}
-	if obj.IsNil() {
-		return InternalError{"object must not be nil"}
-	}
-
-	obj = reflect.Indirect(obj)
-
-	if obj.Type().Kind() != reflect.Struct {
-		return InternalError{"object must be a pointer to a struct"}
-	}
-
-	newVal := BuildUnmarshalObj(obj, d.Version, true)
-	newValI := newVal.Addr().Interface()
-
-	if err := d.D.Decode(newValI); err != nil {
-		return err
-	}
-
-	if err := FromUnmarshalObj(newVal, realObj); err != nil {
-		return err
-	}
-	return nil
+	return unmarshalObj(JSONCodec, realObj, d.Version, d.Options, d.D.Decode)
 }
 
 type JSONEncoder struct {
 	Version float64
 	E       *json.Encoder
+	Options Options
+
+	// w, prefix, indent, and escapeHTML are kept to stream arrays, which write to w directly. See Array.
+	w          io.Writer
+	prefix     string
+	indent     string
+	escapeHTML bool
 }
 
 func (j EncodingJSONDropIn) NewEncoder(w io.Writer) *JSONEncoder {
-	return &JSONEncoder{Version: j.Version, E: json.NewEncoder(w)}
+	return &JSONEncoder{Version: j.Version, E: json.NewEncoder(w), Options: j.Options, w: w, escapeHTML: true}
+}
+func (e *JSONEncoder) SetEscapeHTML(on bool) {
+	e.escapeHTML = on
+	e.E.SetEscapeHTML(on)
+}
+func (e *JSONEncoder) SetIndent(prefix, indent string) {
+	e.prefix, e.indent = prefix, indent
+	e.E.SetIndent(prefix, indent)
 }
-func (e *JSONEncoder) SetEscapeHTML(on bool)           { e.E.SetEscapeHTML(on) }
-func (e *JSONEncoder) SetIndent(prefix, indent string) { e.E.SetIndent(prefix, indent) }
 func (e *JSONEncoder) Encode(v interface{}) error {
-	obj, err := BuildMarshalObj(v, e.Version)
+	obj, err := buildMarshalJSONObj(v, e.Version, e.Options)
 	if err != nil {
 		return err
 	}
@@ -274,7 +274,71 @@ func TestNewJSONDecoderBadInputNonStruct(t *testing.T) {
 	obj := &i
 
 	if err := decoder.Decode(obj); err == nil {
-		t.Errorf("json.Decoder error expected: 'must be struct', actual: %+v", err)
+		t.Errorf("json.Decoder error expected: 'cannot unmarshal object into int', actual: %+v", err)
+	}
+}
+
+func TestNewJSONDecoderSlice(t *testing.T) {
+	type Obj struct {
+		Foo int  `json:"foo" api:"1.1,str"`
+		A   *int `json:"a" api:"1.4,str"`
+	}
+
+	jObj := `[{"foo":42,"a":24},{"foo":"43"}]`
+
+	json := NewJSON(1.3)
+
+	decoder := json.NewDecoder(bytes.NewBuffer([]byte(jObj)))
+
+	objs := []Obj{}
+
+	if err := decoder.Decode(&objs); err != nil {
+		t.Fatalf("json.Decoder error expected: nil, actual: %+v", err)
+	}
+
+	if len(objs) != 2 || objs[0].Foo != 42 || objs[0].A != nil || objs[1].Foo != 43 {
+		t.Errorf("json.Decoder expected: %+v, actual: %+v", "[{Foo:42} {Foo:43}]", objs)
+	}
+}
+
+func TestNewJSONDecoderMap(t *testing.T) {
+	type Obj struct {
+		Foo int  `json:"foo" api:"1.1,str"`
+		A   *int `json:"a" api:"1.4,str"`
+	}
+
+	jObj := `{"x":{"foo":42,"a":24},"y":{"foo":"43"}}`
+
+	json := NewJSON(1.3)
+
+	decoder := json.NewDecoder(bytes.NewBuffer([]byte(jObj)))
+
+	objs := map[string]Obj{}
+
+	if err := decoder.Decode(&objs); err != nil {
+		t.Fatalf("json.Decoder error expected: nil, actual: %+v", err)
+	}
+
+	if len(objs) != 2 || objs["x"].Foo != 42 || objs["x"].A != nil || objs["y"].Foo != 43 {
+		t.Errorf("json.Decoder expected: %+v, actual: %+v", "map[x:{Foo:42} y:{Foo:43}]", objs)
+	}
+}
+
+func TestNewJSONDecoderSliceMissingRequiredField(t *testing.T) {
+	type Obj struct {
+		Foo int `json:"foo" api:"1.1,str"`
+	}
+
+	jObj := `[{"foo":42},{}]`
+
+	json := NewJSON(1.3)
+
+	decoder := json.NewDecoder(bytes.NewBuffer([]byte(jObj)))
+
+	objs := []Obj{}
+
+	if err := decoder.Decode(&objs); err == nil {
+		t.Errorf("json.Decoder error expected: 'missing required field', actual: %+v", err)
 	}
 }
 
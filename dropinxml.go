@@ -0,0 +1,85 @@
+package apiver
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+// NewXML returns a encoding/xml compatible object for marshalling and unmarshalling.
+// This is designed to simplify dropping in an apiver in place of encoding/xml calls.
+// Example:
+//
+//  xml := apiver.NewXML(1.4)
+//  if err := xml.Unmarshal(bts, &obj); err != nil {
+//    return err
+//  }
+//
+func NewXML(version float64) EncodingXMLDropIn {
+	return EncodingXMLDropIn{Version: version}
+}
+
+// NewXMLWithOptions is NewXML, with the tag names and behavior of opts, for every call of the returned object and its decoders and encoders.
+// encoding/xml can't reject unknown elements, so unmarshalling with opts.DisallowUnknownFields returns an InternalError.
+func NewXMLWithOptions(version float64, opts Options) EncodingXMLDropIn {
+	return EncodingXMLDropIn{Version: version, Options: opts}
+}
+
+type EncodingXMLDropIn struct {
+	Version float64
+	Options Options
+}
+
+func (x EncodingXMLDropIn) Marshal(v interface{}) ([]byte, error) {
+	return MarshalXMLWithOptions(v, x.Version, x.Options)
+}
+
+func (x EncodingXMLDropIn) MarshalIndent(v interface{}, prefix, indent string) ([]byte, error) {
+	return marshalXML(v, x.Version, prefix, indent, x.Options)
+}
+
+func (x EncodingXMLDropIn) Unmarshal(data []byte, v interface{}) error {
+	return UnmarshalXMLWithOptions(data, v, x.Version, x.Options)
+}
+
+type XMLDecoder struct {
+	Version float64
+	D       *xml.Decoder
+	Options Options
+}
+
+func (x EncodingXMLDropIn) NewDecoder(r io.Reader) *XMLDecoder {
+	return &XMLDecoder{Version: x.Version, D: xml.NewDecoder(r), Options: x.Options}
+}
+func (d *XMLDecoder) InputOffset() int64           { return d.D.InputOffset() }
+func (d *XMLDecoder) RawToken() (xml.Token, error) { return d.D.RawToken() }
+func (d *XMLDecoder) Skip() error                  { return d.D.Skip() }
+func (d *XMLDecoder) Token() (xml.Token, error)    { return d.D.Token() }
+func (d *XMLDecoder) Decode(realObj interface{}) error {
+	return d.DecodeElement(realObj, nil)
+}
+func (d *XMLDecoder) DecodeElement(realObj interface{}, start *xml.StartElement) error {
+	if d.Options.DisallowUnknownFields {
+		return InternalError{"codec 'xml' can't disallow unknown fields"}
+	}
+	return UnmarshalCodecFuncWithOptions(XMLCodec, realObj, d.Version, d.Options, func(fakeObj interface{}) error {
+		return d.D.DecodeElement(fakeObj, start)
+	})
+}
+
+type XMLEncoder struct {
+	Version float64
+	E       *xml.Encoder
+	Options Options
+}
+
+func (x EncodingXMLDropIn) NewEncoder(w io.Writer) *XMLEncoder {
+	return &XMLEncoder{Version: x.Version, E: xml.NewEncoder(w), Options: x.Options}
+}
+func (e *XMLEncoder) Close() error                  { return e.E.Close() }
+func (e *XMLEncoder) EncodeToken(t xml.Token) error { return e.E.EncodeToken(t) }
+func (e *XMLEncoder) Flush() error                  { return e.E.Flush() }
+func (e *XMLEncoder) Indent(prefix, indent string)  { e.E.Indent(prefix, indent) }
+func (e *XMLEncoder) Encode(v interface{}) error    { return encodeXML(e.E, v, e.Version, nil, e.Options) }
+func (e *XMLEncoder) EncodeElement(v interface{}, start xml.StartElement) error {
+	return encodeXML(e.E, v, e.Version, &start, e.Options)
+}
@@ -0,0 +1,75 @@
+package apiver
+
+import (
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// NewYAML returns a gopkg.in/yaml.v3 compatible object for marshalling and unmarshalling.
+// This is designed to simplify dropping in an apiver in place of yaml calls.
+// Example:
+//
+//  yaml := apiver.NewYAML(1.4)
+//  if err := yaml.Unmarshal(bts, &obj); err != nil {
+//    return err
+//  }
+//
+func NewYAML(version float64) EncodingYAMLDropIn {
+	return EncodingYAMLDropIn{Version: version}
+}
+
+// NewYAMLWithOptions is NewYAML, with the tag names and behavior of opts, for every call of the returned object and its decoders and encoders.
+func NewYAMLWithOptions(version float64, opts Options) EncodingYAMLDropIn {
+	return EncodingYAMLDropIn{Version: version, Options: opts}
+}
+
+type EncodingYAMLDropIn struct {
+	Version float64
+	Options Options
+}
+
+func (y EncodingYAMLDropIn) Marshal(v interface{}) ([]byte, error) {
+	return MarshalYAMLWithOptions(v, y.Version, y.Options)
+}
+
+func (y EncodingYAMLDropIn) Unmarshal(data []byte, v interface{}) error {
+	return UnmarshalYAMLWithOptions(data, v, y.Version, y.Options)
+}
+
+type YAMLDecoder struct {
+	Version float64
+	D       *yaml.Decoder
+	Options Options
+}
+
+func (y EncodingYAMLDropIn) NewDecoder(r io.Reader) *YAMLDecoder {
+	d := &YAMLDecoder{Version: y.Version, D: yaml.NewDecoder(r), Options: y.Options}
+	if y.Options.DisallowUnknownFields {
+		d.D.KnownFields(true)
+	}
+	return d
+}
+func (d *YAMLDecoder) KnownFields(enable bool) { d.D.KnownFields(enable) }
+func (d *YAMLDecoder) Decode(realObj interface{}) error {
+	return UnmarshalCodecFuncWithOptions(YAMLCodec, realObj, d.Version, d.Options, d.D.Decode)
+}
+
+type YAMLEncoder struct {
+	Version float64
+	E       *yaml.Encoder
+	Options Options
+}
+
+func (y EncodingYAMLDropIn) NewEncoder(w io.Writer) *YAMLEncoder {
+	return &YAMLEncoder{Version: y.Version, E: yaml.NewEncoder(w), Options: y.Options}
+}
+func (e *YAMLEncoder) SetIndent(spaces int) { e.E.SetIndent(spaces) }
+func (e *YAMLEncoder) Close() error         { return e.E.Close() }
+func (e *YAMLEncoder) Encode(v interface{}) error {
+	obj, err := BuildMarshalCodecObjWithOptions(YAMLCodec, v, e.Version, e.Options)
+	if err != nil {
+		return err
+	}
+	return e.E.Encode(obj)
+}
@@ -0,0 +1,53 @@
+package apiver
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// VersionMarshaler is implemented by types with generated versioned JSON marshalling, such as by cmd/apivergen.
+// MarshalJSON, MarshalJSONIndent, BuildMarshalObj, and JSONEncoder call MarshalJSONVersion instead of building the object with reflection. It must return the same JSON as MarshalCodec(JSONCodec, obj, version), which never calls it.
+type VersionMarshaler interface {
+	MarshalJSONVersion(version float64) ([]byte, error)
+}
+
+// VersionUnmarshaler is implemented by types with generated versioned JSON unmarshalling, such as by cmd/apivergen.
+// UnmarshalJSON and JSONDecoder call UnmarshalJSONVersion instead of building the object with reflection. It must have the same semantics and UserErrors as UnmarshalCodec(JSONCodec, bts, obj, version), which never calls it.
+type VersionUnmarshaler interface {
+	UnmarshalJSONVersion(bts []byte, version float64) error
+}
+
+// versionMarshaler returns realObj as a VersionMarshaler, if it is one and isn't a nil pointer. Nil pointers are marshalled by reflection, as null.
+func versionMarshaler(realObj interface{}) (VersionMarshaler, bool) {
+	m, ok := realObj.(VersionMarshaler)
+	if !ok || isNilPtr(realObj) {
+		return nil, false
+	}
+	return m, true
+}
+
+// versionUnmarshaler returns realObj as a VersionUnmarshaler, if it is one and isn't a nil pointer. Nil pointers are rejected by the reflection path, with an InternalError.
+func versionUnmarshaler(realObj interface{}) (VersionUnmarshaler, bool) {
+	u, ok := realObj.(VersionUnmarshaler)
+	if !ok || isNilPtr(realObj) {
+		return nil, false
+	}
+	return u, true
+}
+
+func isNilPtr(obj interface{}) bool {
+	val := reflect.ValueOf(obj)
+	return val.Kind() == reflect.Ptr && val.IsNil()
+}
+
+// buildMarshalJSONObj is BuildMarshalObj, with the tag names of opts. If realObj is a VersionMarshaler and opts has the default tag names, the object is its json.RawMessage.
+func buildMarshalJSONObj(realObj interface{}, version float64, opts Options) (interface{}, error) {
+	if m, ok := versionMarshaler(realObj); ok && opts.marshalHooks() {
+		bts, err := m.MarshalJSONVersion(version)
+		if err != nil {
+			return nil, err
+		}
+		return json.RawMessage(bts), nil
+	}
+	return buildMarshalObj(JSONCodec, realObj, version, opts)
+}
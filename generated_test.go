@@ -0,0 +1,115 @@
+package apiver
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+)
+
+// handVersioned is a hand-written VersionMarshaler and VersionUnmarshaler, which marks its output, to verify the JSON functions call its methods.
+type handVersioned struct {
+	Foo     int     `json:"foo" api:"1.1"`
+	Version float64 `json:"-"`
+}
+
+func (o handVersioned) MarshalJSONVersion(version float64) ([]byte, error) {
+	return []byte(`{"generated":true,"foo":` + strconv.Itoa(o.Foo) + `}`), nil
+}
+
+func (o *handVersioned) UnmarshalJSONVersion(bts []byte, version float64) error {
+	o.Foo = len(bts)
+	o.Version = version
+	return nil
+}
+
+func TestMarshalJSONVersionMarshaler(t *testing.T) {
+	actual, err := MarshalJSON(handVersioned{Foo: 42}, 1.1)
+	if err != nil {
+		t.Fatalf("MarshalJSON error expected: nil, actual: %+v", err)
+	}
+	expected := `{"generated":true,"foo":42}`
+	if string(actual) != expected {
+		t.Errorf("MarshalJSON expected ''%+v'', actual ''%+v''", expected, string(actual))
+	}
+
+	actual, err = MarshalJSON(&handVersioned{Foo: 42}, 1.1)
+	if err != nil {
+		t.Fatalf("MarshalJSON pointer error expected: nil, actual: %+v", err)
+	}
+	if string(actual) != expected {
+		t.Errorf("MarshalJSON pointer expected ''%+v'', actual ''%+v''", expected, string(actual))
+	}
+
+	actual, err = MarshalCodec(JSONCodec, handVersioned{Foo: 42}, 1.1)
+	if err != nil {
+		t.Fatalf("MarshalCodec error expected: nil, actual: %+v", err)
+	}
+	if expected := `{"foo":42}`; string(actual) != expected {
+		t.Errorf("MarshalCodec expected reflection ''%+v'', actual ''%+v''", expected, string(actual))
+	}
+}
+
+func TestMarshalJSONVersionMarshalerNil(t *testing.T) {
+	actual, err := MarshalJSON((*handVersioned)(nil), 1.1)
+	if err != nil {
+		t.Fatalf("MarshalJSON error expected: nil, actual: %+v", err)
+	}
+	if string(actual) != "null" {
+		t.Errorf("MarshalJSON expected ''null'', actual ''%+v''", string(actual))
+	}
+}
+
+func TestMarshalJSONIndentVersionMarshaler(t *testing.T) {
+	actual, err := MarshalJSONIndent(handVersioned{Foo: 42}, "", "\t", 1.1)
+	if err != nil {
+		t.Fatalf("MarshalJSONIndent error expected: nil, actual: %+v", err)
+	}
+	expected, err := MarshalJSONIndent(struct {
+		Generated bool `json:"generated"`
+		Foo       int  `json:"foo"`
+	}{true, 42}, "", "\t", 1.1)
+	if err != nil {
+		t.Fatalf("MarshalJSONIndent reflection error expected: nil, actual: %+v", err)
+	}
+	if string(actual) != string(expected) {
+		t.Errorf("MarshalJSONIndent expected ''%+v'', actual ''%+v''", string(expected), string(actual))
+	}
+}
+
+func TestUnmarshalJSONVersionUnmarshaler(t *testing.T) {
+	obj := handVersioned{}
+	if err := UnmarshalJSON([]byte(`{"foo":1}`), &obj, 1.3); err != nil {
+		t.Fatalf("UnmarshalJSON error expected: nil, actual: %+v", err)
+	}
+	if obj.Foo != 9 || obj.Version != 1.3 {
+		t.Errorf("UnmarshalJSON expected: %+v, actual: %+v", handVersioned{Foo: 9, Version: 1.3}, obj)
+	}
+
+	if err := UnmarshalJSON([]byte(`{"foo":1}`), (*handVersioned)(nil), 1.3); err == nil {
+		t.Errorf("UnmarshalJSON nil error expected: non-nil, actual: nil")
+	}
+}
+
+func TestJSONDecoderEncoderVersionMarshaler(t *testing.T) {
+	json := NewJSON(1.2)
+
+	obj := handVersioned{}
+	decoder := json.NewDecoder(bytes.NewBufferString(`{"foo": 1} {"foo":2}`))
+	if err := decoder.Decode(&obj); err != nil {
+		t.Fatalf("JSONDecoder.Decode error expected: nil, actual: %+v", err)
+	}
+	if obj.Foo != 10 || obj.Version != 1.2 {
+		t.Errorf("JSONDecoder.Decode expected: %+v, actual: %+v", handVersioned{Foo: 10, Version: 1.2}, obj)
+	}
+
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	encoder.SetIndent("", " ")
+	if err := encoder.Encode(handVersioned{Foo: 42}); err != nil {
+		t.Fatalf("JSONEncoder.Encode error expected: nil, actual: %+v", err)
+	}
+	expected := "{\n \"generated\": true,\n \"foo\": 42\n}\n"
+	if buf.String() != expected {
+		t.Errorf("JSONEncoder.Encode expected ''%+v'', actual ''%+v''", expected, buf.String())
+	}
+}
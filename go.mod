@@ -0,0 +1,17 @@
+module github.com/rob05c/apiver
+
+go 1.22.0
+
+require (
+	github.com/fxamacker/cbor/v2 v2.9.1
+	github.com/vmihailenco/msgpack/v5 v5.4.1
+	golang.org/x/tools v0.28.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+	github.com/x448/float16 v0.8.4 // indirect
+	golang.org/x/mod v0.22.0 // indirect
+	golang.org/x/sync v0.10.0 // indirect
+)
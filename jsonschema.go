@@ -0,0 +1,304 @@
+package apiver
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// JSONSchemaDraft is the $schema of the schemas JSONSchema returns.
+const JSONSchemaDraft = `https://json-schema.org/draft/2020-12/schema`
+
+// JSONSchemaObject is a JSON Schema, or a subschema of one. It has only the keywords JSONSchema uses.
+type JSONSchemaObject struct {
+	Schema               string                       `json:"$schema,omitempty"`
+	Ref                  string                       `json:"$ref,omitempty"`
+	Title                string                       `json:"title,omitempty"`
+	Type                 interface{}                  `json:"type,omitempty"` // a string, or a []string of types, such as ["integer", "null"] for pointers
+	Format               string                       `json:"format,omitempty"`
+	ContentEncoding      string                       `json:"contentEncoding,omitempty"`
+	Minimum              *float64                     `json:"minimum,omitempty"`
+	Deprecated           bool                         `json:"deprecated,omitempty"`
+	OneOf                []*JSONSchemaObject          `json:"oneOf,omitempty"`
+	Items                *JSONSchemaObject            `json:"items,omitempty"`
+	Properties           map[string]*JSONSchemaObject `json:"properties,omitempty"`
+	Required             []string                     `json:"required,omitempty"`
+	AdditionalProperties *JSONSchemaObject            `json:"additionalProperties,omitempty"`
+	Defs                 map[string]*JSONSchemaObject `json:"$defs,omitempty"`
+}
+
+// JSONSchema returns the draft 2020-12 JSON Schema of the JSON of typ at version, as UnmarshalJSON accepts it and MarshalJSON writes it. Use json.Marshal to serialize it.
+// Only fields which exist at version are properties. Fields UnmarshalJSON requires, which are versioned fields which aren't pointers, fields with the required property, and structs which aren't pointers with a required field, are required. Pointers, slices, and maps may be null. The schemas of str fields are a oneOf of their number or boolean type, and a string. Fields with the deprecated property are deprecated.
+// Named struct types are in $defs, and referenced with $ref, so recursive types terminate. Types with their own MarshalJSON methods are any value, and types with MarshalText methods are strings, except time.Time, which is a date-time string.
+// Returns an InternalError for types encoding/json can't encode, such as channels and funcs.
+func JSONSchema(typ reflect.Type, version float64) (*JSONSchemaObject, error) {
+	b := newJSONSchemaBuilder(version, Options{}, "#/$defs/")
+	schema, err := b.buildRoot(typ)
+	if err != nil {
+		return nil, err
+	}
+	schema.Schema = JSONSchemaDraft
+	if len(b.defs) > 0 {
+		schema.Defs = b.defs
+	}
+	return schema, nil
+}
+
+// jsonSchemaBuilder builds the schemas of types, with named structs in defs, referenced by refPrefix and their name. This lets OpenAPI put them in its components instead of $defs.
+type jsonSchemaBuilder struct {
+	version   float64
+	opts      Options
+	refPrefix string
+	defs      map[string]*JSONSchemaObject
+	// names is the name in defs of each named struct type, or "#" for the root. Types are named before they're built, so recursive references terminate.
+	names map[reflect.Type]string
+}
+
+func newJSONSchemaBuilder(version float64, opts Options, refPrefix string) *jsonSchemaBuilder {
+	return &jsonSchemaBuilder{version: version, opts: opts, refPrefix: refPrefix, defs: map[string]*JSONSchemaObject{}, names: map[reflect.Type]string{}}
+}
+
+// buildRoot returns the schema of typ as the root of a document. If typ is a named struct, or a pointer to one, its properties are the root, which recursive references refer to as "#".
+func (b *jsonSchemaBuilder) buildRoot(typ reflect.Type) (*JSONSchemaObject, error) {
+	structType := typ
+	for structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	if structType.Kind() != reflect.Struct || structType.Name() == "" || structType == reflect.TypeOf(time.Time{}) || hasMarshalMethod(structType) {
+		return b.build(typ)
+	}
+	b.names[structType] = "#"
+	return b.buildStructProperties(structType)
+}
+
+// build returns the schema of typ, which may be a $ref to defs.
+func (b *jsonSchemaBuilder) build(typ reflect.Type) (*JSONSchemaObject, error) {
+	if typ == reflect.TypeOf(time.Time{}) {
+		return &JSONSchemaObject{Type: "string", Format: "date-time"}, nil
+	}
+	ptr := reflect.PtrTo(typ)
+	if ptr.Implements(reflect.TypeOf((*json.Marshaler)(nil)).Elem()) {
+		return &JSONSchemaObject{}, nil
+	}
+	if ptr.Implements(reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()) {
+		return &JSONSchemaObject{Type: "string"}, nil
+	}
+
+	switch typ.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr, reflect.Float32, reflect.Float64, reflect.String:
+		return jsonSchemaBasic(typ.Kind()), nil
+	case reflect.Interface:
+		return &JSONSchemaObject{}, nil
+	case reflect.Ptr:
+		elem, err := b.build(typ.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return jsonSchemaNullable(elem), nil
+	case reflect.Slice:
+		if typ.Elem().Kind() == reflect.Uint8 && !reflect.PtrTo(typ.Elem()).Implements(reflect.TypeOf((*json.Marshaler)(nil)).Elem()) {
+			return &JSONSchemaObject{Type: []string{"string", "null"}, ContentEncoding: "base64"}, nil // encoding/json encodes []byte as base64
+		}
+		items, err := b.build(typ.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &JSONSchemaObject{Type: []string{"array", "null"}, Items: items}, nil
+	case reflect.Array:
+		items, err := b.build(typ.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &JSONSchemaObject{Type: "array", Items: items}, nil
+	case reflect.Map:
+		elem, err := b.build(typ.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &JSONSchemaObject{Type: []string{"object", "null"}, AdditionalProperties: elem}, nil
+	case reflect.Struct:
+		return b.buildStruct(typ)
+	default:
+		return nil, InternalError{"type '" + typ.String() + "' kind " + typ.Kind().String() + " can't be encoded as JSON"}
+	}
+}
+
+// buildStruct returns the schema of the struct typ. Named structs are added to defs, and a $ref to them is returned.
+func (b *jsonSchemaBuilder) buildStruct(typ reflect.Type) (*JSONSchemaObject, error) {
+	if typ.Name() == "" {
+		return b.buildStructProperties(typ)
+	}
+	if name, ok := b.names[typ]; ok {
+		if name == "#" {
+			return &JSONSchemaObject{Ref: "#"}, nil
+		}
+		return &JSONSchemaObject{Ref: b.refPrefix + name}, nil
+	}
+
+	name := typ.Name()
+	for i := 2; b.defs[name] != nil; i++ {
+		name = typ.Name() + strconv.Itoa(i) // a type of the same name in another package
+	}
+	b.names[typ] = name
+	b.defs[name] = &JSONSchemaObject{} // reserve the name, before building any recursive references
+	schema, err := b.buildStructProperties(typ)
+	if err != nil {
+		return nil, err
+	}
+	b.defs[name] = schema
+	return &JSONSchemaObject{Ref: b.refPrefix + name}, nil
+}
+
+// buildStructProperties returns the object schema of the fields of typ which exist at the builder's version.
+func (b *jsonSchemaBuilder) buildStructProperties(typ reflect.Type) (*JSONSchemaObject, error) {
+	schema := &JSONSchemaObject{Type: "object", Properties: map[string]*JSONSchemaObject{}}
+	if typ.Name() != "" {
+		schema.Title = typ.Name()
+	}
+	if err := b.addStructProperties(schema, typ); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+// addStructProperties adds the properties of the fields of typ which exist at the builder's version to schema, as UnmarshalJSON decodes them.
+// Embedded structs are properties named by their type, like any other field, because the type BuildUnmarshalType builds doesn't embed them. Only if UnmarshalJSON uses typ verbatim, because no fields changed, are embedded structs without json names flattened, like encoding/json.
+func (b *jsonSchemaBuilder) addStructProperties(schema *JSONSchemaObject, typ reflect.Type) error {
+	verbatim := buildUnmarshalType(JSONCodec, typ, b.version, true, b.opts) == typ // as UnmarshalJSON builds it
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		props := b.opts.GetFieldProperties(typ, field)
+		if props.Version > b.version {
+			continue
+		}
+		field.Tag = schemaFieldTag(JSONCodec, typ, field)
+
+		if verbatim && field.Anonymous && strings.Split(field.Tag.Get("json"), ",")[0] == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				if err := b.addStructProperties(schema, embedded); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if isExported := field.PkgPath == ""; !isExported {
+			continue
+		}
+
+		key, quoted, ok := jsonFieldKey(field)
+		if !ok {
+			continue
+		}
+		name := ""
+		if err := json.Unmarshal(key[:len(key)-1], &name); err != nil {
+			return InternalError{"type '" + typ.String() + "' field '" + field.Name + "' json key: " + err.Error()} // should never happen
+		}
+
+		fieldSchema, err := b.buildField(field.Type, props, quoted)
+		if err != nil {
+			return err
+		}
+		fieldSchema.Deprecated = props.Deprecated
+		schema.Properties[name] = fieldSchema
+
+		if b.requiredField(field, props) {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return nil
+}
+
+// requiredField returns whether UnmarshalJSON requires field, with the properties props: if it's a versioned field which isn't a pointer, has the required property, or is a struct which isn't a pointer with a required field of its own, which is missing if the struct is.
+func (b *jsonSchemaBuilder) requiredField(field reflect.StructField, props TagProperties) bool {
+	if field.Type.Kind() == reflect.Ptr {
+		return false
+	}
+	if props.Required || (props.Version != 0 && !props.Optional) {
+		return true
+	}
+	if field.Type.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < field.Type.NumField(); i++ {
+		subField := field.Type.Field(i)
+		subProps := b.opts.GetFieldProperties(field.Type, subField)
+		if isExported := subField.PkgPath == ""; !isExported || subProps.Version > b.version {
+			continue
+		}
+		if b.requiredField(subField, subProps) {
+			return true
+		}
+	}
+	return false
+}
+
+// buildField returns the schema of a field of type typ, with the given properties, and whether it has the json ',string' option.
+func (b *jsonSchemaBuilder) buildField(typ reflect.Type, props TagProperties, quoted bool) (*JSONSchemaObject, error) {
+	elem := typ
+	if elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	nullable := func(schema *JSONSchemaObject) *JSONSchemaObject {
+		if typ.Kind() == reflect.Ptr {
+			return jsonSchemaNullable(schema)
+		}
+		return schema
+	}
+
+	if props.Str && DefaultStrType(elem.Kind()) != nil && !hasMarshalMethod(elem) {
+		return nullable(&JSONSchemaObject{OneOf: []*JSONSchemaObject{jsonSchemaBasic(elem.Kind()), {Type: "string"}}}), nil
+	}
+	if quoted && isBasicKind(elem.Kind()) && !hasMarshalMethod(elem) {
+		return nullable(&JSONSchemaObject{Type: "string"}), nil
+	}
+	return b.build(typ)
+}
+
+// jsonSchemaBasic returns the schema of the basic kind.
+func jsonSchemaBasic(kind reflect.Kind) *JSONSchemaObject {
+	switch kind {
+	case reflect.Bool:
+		return &JSONSchemaObject{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &JSONSchemaObject{Type: "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		minimum := 0.0
+		return &JSONSchemaObject{Type: "integer", Minimum: &minimum}
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchemaObject{Type: "number"}
+	default:
+		return &JSONSchemaObject{Type: "string"}
+	}
+}
+
+// jsonSchemaNullable returns schema, also allowing null.
+func jsonSchemaNullable(schema *JSONSchemaObject) *JSONSchemaObject {
+	switch typ := schema.Type.(type) {
+	case string:
+		schema.Type = []string{typ, "null"}
+		return schema
+	case []string:
+		for _, t := range typ {
+			if t == "null" {
+				return schema
+			}
+		}
+		schema.Type = append(typ, "null")
+		return schema
+	}
+	if schema.OneOf != nil {
+		schema.OneOf = append(schema.OneOf, &JSONSchemaObject{Type: "null"})
+		return schema
+	}
+	if schema.Ref != "" {
+		return &JSONSchemaObject{OneOf: []*JSONSchemaObject{schema, {Type: "null"}}}
+	}
+	return schema // any value, including null
+}
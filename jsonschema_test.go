@@ -0,0 +1,210 @@
+package apiver
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type schemaServer struct {
+	Name     string            `json:"name" api:"required"`
+	Port     int               `json:"port" api:"1.1,str"`
+	Weight   *float64          `json:"weight" api:"1.2,str"`
+	Cache    *schemaCache      `json:"cache" api:"1.2"`
+	Caches   []schemaCache     `json:"caches" api:"1.3"`
+	Labels   map[string]string `json:"labels"`
+	Next     *schemaServer     `json:"next"`
+	Updated  time.Time         `json:"updated" api:"1.1,optional"`
+	Raw      json.RawMessage   `json:"raw"`
+	ID       uint32            `json:"id,string"`
+	Data     []byte            `json:"data"`
+	Internal string            `json:"-"`
+	Any      interface{}       `json:"any,omitempty"`
+	Inline   struct{ A bool }  `json:"inline"`
+	private  string
+}
+
+type schemaCache struct {
+	Host string `json:"host" api:"1.2"`
+}
+
+func TestJSONSchema(t *testing.T) {
+	tests := map[float64]string{
+		1.0: `{"$schema":"https://json-schema.org/draft/2020-12/schema","title":"schemaServer","type":"object",` +
+			`"properties":{"any":{},"data":{"type":["string","null"],"contentEncoding":"base64"},"id":{"type":"string"},` +
+			`"inline":{"type":"object","properties":{"A":{"type":"boolean"}}},"labels":{"type":["object","null"],"additionalProperties":{"type":"string"}},` +
+			`"name":{"type":"string"},"next":{"oneOf":[{"$ref":"#"},{"type":"null"}]},"raw":{}},"required":["name"]}`,
+		1.2: `{"$schema":"https://json-schema.org/draft/2020-12/schema","title":"schemaServer","type":"object",` +
+			`"properties":{"any":{},"cache":{"oneOf":[{"$ref":"#/$defs/schemaCache"},{"type":"null"}]},"data":{"type":["string","null"],"contentEncoding":"base64"},"id":{"type":"string"},` +
+			`"inline":{"type":"object","properties":{"A":{"type":"boolean"}}},"labels":{"type":["object","null"],"additionalProperties":{"type":"string"}},` +
+			`"name":{"type":"string"},"next":{"oneOf":[{"$ref":"#"},{"type":"null"}]},"port":{"oneOf":[{"type":"integer"},{"type":"string"}]},"raw":{},` +
+			`"updated":{"type":"string","format":"date-time"},"weight":{"oneOf":[{"type":"number"},{"type":"string"},{"type":"null"}]}},"required":["name","port"],` +
+			`"$defs":{"schemaCache":{"title":"schemaCache","type":"object","properties":{"host":{"type":"string"}},"required":["host"]}}}`,
+	}
+	for version, expected := range tests {
+		schema, err := JSONSchema(reflect.TypeOf(schemaServer{}), version)
+		if err != nil {
+			t.Fatalf("JSONSchema version %v error expected: nil, actual: %+v", version, err)
+		}
+		actual, err := json.Marshal(schema)
+		if err != nil || string(actual) != expected {
+			t.Errorf("JSONSchema version %v expected: %v, actual: %s %+v", version, expected, actual, err)
+		}
+	}
+
+	schema, err := JSONSchema(reflect.TypeOf([]*schemaServer{}), 1.3)
+	if err != nil {
+		t.Fatalf("JSONSchema slice error expected: nil, actual: %+v", err)
+	}
+	if schema.Items == nil || len(schema.Items.OneOf) != 2 || schema.Items.OneOf[0].Ref != "#/$defs/schemaServer" {
+		t.Errorf("JSONSchema slice items expected: %+v, actual: %+v", "#/$defs/schemaServer", schema.Items)
+	}
+	if server := schema.Defs["schemaServer"]; server == nil || server.Properties["caches"] == nil || server.Properties["caches"].Items.Ref != "#/$defs/schemaCache" {
+		t.Errorf("JSONSchema slice defs expected: schemaServer with caches, actual: %+v", schema.Defs)
+	}
+}
+
+func TestJSONSchemaForeign(t *testing.T) {
+	schema, err := JSONSchema(reflect.TypeOf(foreignServer{}), 1.2)
+	if err != nil {
+		t.Fatalf("JSONSchema error expected: nil, actual: %+v", err)
+	}
+	if schema.Properties["cacheGroup"] == nil || schema.Properties["timeoutSeconds"] == nil || schema.Properties["port"].OneOf == nil {
+		t.Errorf("JSONSchema registered schema expected: renamed and str properties, actual: %+v", schema.Properties)
+	}
+	if expected := []string{"name", "port", "cacheGroup"}; !reflect.DeepEqual(schema.Required, expected) {
+		t.Errorf("JSONSchema registered required expected: %+v, actual: %+v", expected, schema.Required)
+	}
+}
+
+func TestJSONSchemaErrors(t *testing.T) {
+	type chanObj struct {
+		C chan int `json:"c"`
+	}
+	if _, err := JSONSchema(reflect.TypeOf(chanObj{}), 1.0); err == nil || !strings.Contains(err.Error(), "can't be encoded as JSON") {
+		t.Errorf("JSONSchema chan error expected: %+v, actual: %+v", "can't be encoded as JSON", err)
+	}
+}
+
+type SchemaBase struct {
+	Created int `json:"created" api:"1.1"`
+}
+
+type SchemaPlain struct {
+	Created int `json:"created"`
+}
+
+// schemaEmbedded embeds a versioned struct, which MarshalJSON nests under the type name, like any other field.
+type schemaEmbedded struct {
+	SchemaBase
+	Name string `json:"name" api:"1.0"`
+}
+
+// schemaEmbeddedPlain has no versioned fields, so it's encoded verbatim, and encoding/json flattens the embedded struct.
+type schemaEmbeddedPlain struct {
+	SchemaPlain
+	Name string `json:"name"`
+}
+
+func TestJSONSchemaEmbedded(t *testing.T) {
+	tests := []struct {
+		obj      interface{}
+		expected string
+	}{
+		{schemaEmbedded{SchemaBase{Created: 3}, "n"}, `{"title":"schemaEmbedded","type":"object","properties":{"SchemaBase":{"$ref":"#/$defs/SchemaBase"},"name":{"type":"string"}},"required":["SchemaBase","name"],` +
+			`"$defs":{"SchemaBase":{"title":"SchemaBase","type":"object","properties":{"created":{"type":"integer"}},"required":["created"]}}}`},
+		{schemaEmbeddedPlain{SchemaPlain{Created: 3}, "n"}, `{"title":"schemaEmbeddedPlain","type":"object","properties":{"created":{"type":"integer"},"name":{"type":"string"}}}`},
+	}
+	for _, test := range tests {
+		schema, err := JSONSchema(reflect.TypeOf(test.obj), 1.2)
+		if err != nil {
+			t.Fatalf("JSONSchema %T error expected: nil, actual: %+v", test.obj, err)
+		}
+		schema.Schema = ""
+		if actual, err := json.Marshal(schema); err != nil || string(actual) != test.expected {
+			t.Errorf("JSONSchema %T expected: %v, actual: %s %+v", test.obj, test.expected, actual, err)
+		}
+
+		// the properties must be exactly the keys MarshalJSON writes.
+		bts, err := MarshalJSON(test.obj, 1.2)
+		if err != nil {
+			t.Fatalf("MarshalJSON %T error expected: nil, actual: %+v", test.obj, err)
+		}
+		marshalled := map[string]interface{}{}
+		if err := json.Unmarshal(bts, &marshalled); err != nil {
+			t.Fatalf("json.Unmarshal %T error expected: nil, actual: %+v", test.obj, err)
+		}
+		if len(marshalled) != len(schema.Properties) {
+			t.Errorf("JSONSchema %T properties expected: %s, actual: %+v", test.obj, bts, schema.Properties)
+		}
+		for key := range marshalled {
+			if _, ok := schema.Properties[key]; !ok {
+				t.Errorf("JSONSchema %T property expected: %v, actual: %+v", test.obj, key, schema.Properties)
+			}
+		}
+	}
+}
+
+type schemaInner struct {
+	X int `json:"x" api:"1.0"`
+}
+
+type schemaMiddle struct {
+	Inner schemaInner `json:"inner"`
+}
+
+type schemaOuter struct {
+	In     schemaInner  `json:"in"`
+	Mid    schemaMiddle `json:"mid"`
+	InPtr  *schemaInner `json:"inPtr"`
+	Plain  SchemaPlain  `json:"plain"`
+	Name   string       `json:"name" api:"1.0"`
+	Future schemaInner  `json:"future" api:"2.0,optional"`
+}
+
+func TestJSONSchemaRequiredNested(t *testing.T) {
+	schema, err := JSONSchema(reflect.TypeOf(schemaOuter{}), 1.2)
+	if err != nil {
+		t.Fatalf("JSONSchema error expected: nil, actual: %+v", err)
+	}
+	if expected := []string{"in", "mid", "name"}; !reflect.DeepEqual(schema.Required, expected) {
+		t.Errorf("JSONSchema required expected: %+v, actual: %+v", expected, schema.Required)
+	}
+
+	// every required property must be rejected by UnmarshalJSON when it's missing.
+	for _, name := range schema.Required {
+		obj := map[string]interface{}{"in": map[string]interface{}{"x": 1}, "mid": map[string]interface{}{"inner": map[string]interface{}{"x": 1}}, "name": "n"}
+		delete(obj, name)
+		bts, _ := json.Marshal(obj)
+		if err := UnmarshalJSON(bts, &schemaOuter{}, 1.2); err == nil {
+			t.Errorf("UnmarshalJSON without required %v error expected: missing required field, actual: nil", name)
+		}
+	}
+}
+
+// schemaEmbeddedStr has only a str field, which UnmarshalJSON changes, so it nests the embedded struct under the type name, though MarshalJSON uses the type verbatim.
+type schemaEmbeddedStr struct {
+	SchemaPlain
+	Count int `json:"count" api:"str"`
+}
+
+func TestJSONSchemaEmbeddedStr(t *testing.T) {
+	schema, err := JSONSchema(reflect.TypeOf(schemaEmbeddedStr{}), 1.2)
+	if err != nil {
+		t.Fatalf("JSONSchema error expected: nil, actual: %+v", err)
+	}
+	if _, ok := schema.Properties["SchemaPlain"]; !ok {
+		t.Errorf("JSONSchema property expected: %v, actual: %+v", "SchemaPlain", schema.Properties)
+	}
+
+	obj := schemaEmbeddedStr{}
+	objJ := `{"SchemaPlain":{"created":3},"count":"4"}`
+	if err := UnmarshalJSON([]byte(objJ), &obj, 1.2); err != nil {
+		t.Fatalf("UnmarshalJSON %v error expected: nil, actual: %+v", objJ, err)
+	}
+	if obj.Created != 3 || obj.Count != 4 {
+		t.Errorf("UnmarshalJSON %v expected: %+v, actual: %+v", objJ, schemaEmbeddedStr{SchemaPlain{3}, 4}, obj)
+	}
+}
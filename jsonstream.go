@@ -0,0 +1,307 @@
+package apiver
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strconv"
+)
+
+// JSONArrayIterator decodes the elements of a JSON array one at a time, so arrays of any size may be decoded in bounded memory.
+// It's created by JSONDecoder.Array or JSONDecoder.ArrayAt. Example:
+//
+//  it, err := decoder.Array()
+//  if err != nil {
+//    return err
+//  }
+//  for it.More() {
+//    obj := Obj{}
+//    if err := it.Decode(&obj); err != nil {
+//      return err // e.g. "element 3: missing required field: foo"
+//    }
+//  }
+//  return it.Close()
+//
+type JSONArrayIterator struct {
+	d     *JSONDecoder
+	index int
+	// inObject is whether the array is the value of a key in an object, whose remaining keys must be read by Close.
+	inObject bool
+}
+
+// Array reads the start of a top-level JSON array, and returns an iterator over its elements.
+func (d *JSONDecoder) Array() (*JSONArrayIterator, error) {
+	if err := d.readDelim('['); err != nil {
+		return nil, err
+	}
+	return &JSONArrayIterator{d: d}, nil
+}
+
+// ArrayAt reads a top-level JSON object up to the array at key, and returns an iterator over its elements. This is designed for envelopes, such as {"response": [...]}.
+// Values of other keys before key are skipped. Returns a UserError if the object doesn't have key, or its value isn't an array.
+func (d *JSONDecoder) ArrayAt(key string) (*JSONArrayIterator, error) {
+	if err := d.readDelim('{'); err != nil {
+		return nil, err
+	}
+	for d.D.More() {
+		tok, err := d.D.Token()
+		if err != nil {
+			return nil, err
+		}
+		if tok != key {
+			if err := d.skipValue(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err := d.readDelim('['); err != nil {
+			return nil, prefixError("field '"+key+"': ", err)
+		}
+		return &JSONArrayIterator{d: d, inObject: true}, nil
+	}
+	return nil, UserError{"missing required field: " + key}
+}
+
+// More returns whether the array has another element.
+func (it *JSONArrayIterator) More() bool { return it.d.D.More() }
+
+// Index returns the index of the next element to be decoded.
+func (it *JSONArrayIterator) Index() int { return it.index }
+
+// Decode decodes the next element into realObj, exactly like JSONDecoder.Decode. The object is zeroed first, so the same object may be reused for every element.
+// Errors are prefixed with the element index, and UserErrors remain UserErrors.
+func (it *JSONArrayIterator) Decode(realObj interface{}) error {
+	index := it.index
+	it.index++
+	if obj := reflect.ValueOf(realObj); obj.Kind() == reflect.Ptr && !obj.IsNil() {
+		obj.Elem().Set(reflect.Zero(obj.Elem().Type()))
+	}
+	if err := it.d.Decode(realObj); err != nil {
+		return prefixError("element "+strconv.Itoa(index)+": ", err)
+	}
+	return nil
+}
+
+// Close skips any remaining elements, and reads the end of the array. For ArrayAt, it also skips the remaining keys, and reads the end of the object.
+func (it *JSONArrayIterator) Close() error {
+	for it.d.D.More() {
+		if err := it.d.skipValue(); err != nil {
+			return err
+		}
+	}
+	if err := it.d.readDelim(']'); err != nil {
+		return err
+	}
+	if !it.inObject {
+		return nil
+	}
+	for it.d.D.More() {
+		if _, err := it.d.D.Token(); err != nil {
+			return err
+		}
+		if err := it.d.skipValue(); err != nil {
+			return err
+		}
+	}
+	return it.d.readDelim('}')
+}
+
+// readDelim reads the next token, and returns a UserError if it isn't delim.
+func (d *JSONDecoder) readDelim(delim json.Delim) error {
+	tok, err := d.D.Token()
+	if err != nil {
+		return err
+	}
+	if tok != delim {
+		switch delim {
+		case '[':
+			return UserError{"expected array"}
+		case '{':
+			return UserError{"expected object"}
+		default:
+			return UserError{"expected '" + delim.String() + "'"}
+		}
+	}
+	return nil
+}
+
+// skipValue reads and discards the next value.
+func (d *JSONDecoder) skipValue() error {
+	raw := json.RawMessage{}
+	return d.D.Decode(&raw)
+}
+
+// JSONArrayEncoder writes a JSON array one element at a time, so arrays of any size may be encoded without building the whole versioned array in memory.
+// It's created by JSONEncoder.Array or JSONEncoder.ArrayAt, and writes exactly what JSONEncoder.Encode would write for the whole array, including indentation and HTML escaping. Example:
+//
+//  arr, err := encoder.ArrayAt("response")
+//  if err != nil {
+//    return err
+//  }
+//  for rows.Next() {
+//    obj := Obj{}
+//    // ... scan obj
+//    if err := arr.Encode(obj); err != nil {
+//      return err
+//    }
+//  }
+//  return arr.Close()
+//
+type JSONArrayEncoder struct {
+	e   *JSONEncoder
+	len int
+	// inObject is whether the array is the value of a key in an envelope object, which must be closed by Close.
+	inObject bool
+	err      error
+}
+
+// Array writes the start of a JSON array, and returns an encoder for its elements.
+func (e *JSONEncoder) Array() (*JSONArrayEncoder, error) {
+	if e.w == nil {
+		return nil, InternalError{"encoder has no writer, it must be created with NewEncoder"}
+	}
+	a := &JSONArrayEncoder{e: e}
+	a.write([]byte("["))
+	return a, a.err
+}
+
+// ArrayAt writes the start of a JSON object with key, whose value is an array, and returns an encoder for the array's elements. This is designed for envelopes, such as {"response": [...]}.
+func (e *JSONEncoder) ArrayAt(key string) (*JSONArrayEncoder, error) {
+	if e.w == nil {
+		return nil, InternalError{"encoder has no writer, it must be created with NewEncoder"}
+	}
+	a := &JSONArrayEncoder{e: e, inObject: true}
+	keyBts, err := a.marshal(key, "")
+	if err != nil {
+		return nil, err
+	}
+	a.write([]byte("{"))
+	if a.indenting() {
+		a.write([]byte("\n" + e.prefix + e.indent))
+		a.write(keyBts)
+		a.write([]byte(": ["))
+	} else {
+		a.write(keyBts)
+		a.write([]byte(":["))
+	}
+	return a, a.err
+}
+
+// Encode writes v as the next element of the array, omitting fields newer than the encoder's version, exactly like JSONEncoder.Encode.
+func (a *JSONArrayEncoder) Encode(v interface{}) error {
+	if a.err != nil {
+		return a.err
+	}
+	obj, err := buildMarshalJSONObj(v, a.e.Version, a.e.Options)
+	if err != nil {
+		return err
+	}
+	elemPrefix := a.closePrefix() + a.e.indent
+	bts, err := a.marshal(obj, elemPrefix)
+	if err != nil {
+		return err
+	}
+	if a.len > 0 {
+		a.write([]byte(","))
+	}
+	if a.indenting() {
+		a.write([]byte("\n" + elemPrefix))
+	}
+	a.write(bts)
+	a.len++
+	return a.err
+}
+
+// EncodeAll encodes every element of objs, which must be a slice, an array, or a channel. Channels are received from until they're closed.
+func (a *JSONArrayEncoder) EncodeAll(objs interface{}) error {
+	val := reflect.ValueOf(objs)
+	switch val.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < val.Len(); i++ {
+			if err := a.Encode(val.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Chan:
+		for {
+			elem, ok := val.Recv()
+			if !ok {
+				return nil
+			}
+			if err := a.Encode(elem.Interface()); err != nil {
+				return err
+			}
+		}
+	default:
+		return InternalError{"objects must be a slice, array, or channel"}
+	}
+}
+
+// EncodeFunc encodes the objects returned by next, until next returns false or an error. This is designed for iterators, such as database rows.
+func (a *JSONArrayEncoder) EncodeFunc(next func() (obj interface{}, ok bool, err error)) error {
+	for {
+		obj, ok, err := next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+		if err := a.Encode(obj); err != nil {
+			return err
+		}
+	}
+}
+
+// Close writes the end of the array, and for ArrayAt the end of the object, followed by a newline like JSONEncoder.Encode.
+func (a *JSONArrayEncoder) Close() error {
+	if a.err != nil {
+		return a.err
+	}
+	if a.len > 0 && a.indenting() {
+		a.write([]byte("\n" + a.closePrefix()))
+	}
+	a.write([]byte("]"))
+	if a.inObject {
+		if a.indenting() {
+			a.write([]byte("\n" + a.e.prefix))
+		}
+		a.write([]byte("}"))
+	}
+	a.write([]byte("\n"))
+	return a.err
+}
+
+// indenting returns whether the encoder indents, which like encoding/json is whether either the prefix or indent is set.
+func (a *JSONArrayEncoder) indenting() bool { return a.e.prefix != "" || a.e.indent != "" }
+
+// closePrefix returns the prefix of the array's closing bracket.
+func (a *JSONArrayEncoder) closePrefix() string {
+	if a.inObject {
+		return a.e.prefix + a.e.indent
+	}
+	return a.e.prefix
+}
+
+// marshal returns the JSON of v with the encoder's HTML escaping, indented with prefix if the encoder indents.
+func (a *JSONArrayEncoder) marshal(v interface{}, prefix string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(a.e.escapeHTML)
+	if a.indenting() {
+		encoder.SetIndent(prefix, a.e.indent)
+	}
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// write writes bts to the encoder's writer, unless a previous write failed.
+func (a *JSONArrayEncoder) write(bts []byte) {
+	if a.err != nil {
+		return
+	}
+	_, a.err = a.e.w.Write(bts)
+}
@@ -0,0 +1,257 @@
+package apiver
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestJSONDecoderArray(t *testing.T) {
+	type Obj struct {
+		Foo int  `json:"foo" api:"1.1,str"`
+		A   *int `json:"a" api:"1.4,str"`
+		B   *int `json:"b" api:"1.1"`
+	}
+
+	jObj := `[{"foo":42,"a":24,"b":1},{"foo":"43"}] {"foo":44}`
+
+	decoder := NewJSON(1.3).NewDecoder(bytes.NewBuffer([]byte(jObj)))
+	it, err := decoder.Array()
+	if err != nil {
+		t.Fatalf("JSONDecoder.Array error expected: nil, actual: %+v", err)
+	}
+
+	objs := []Obj{}
+	obj := Obj{}
+	for it.More() {
+		if err := it.Decode(&obj); err != nil {
+			t.Fatalf("JSONArrayIterator.Decode error expected: nil, actual: %+v", err)
+		}
+		objs = append(objs, obj)
+	}
+	if err := it.Close(); err != nil {
+		t.Fatalf("JSONArrayIterator.Close error expected: nil, actual: %+v", err)
+	}
+
+	if len(objs) != 2 || objs[0].Foo != 42 || objs[0].A != nil || objs[0].B == nil || objs[1].Foo != 43 || objs[1].B != nil {
+		t.Errorf("JSONArrayIterator expected: %+v, actual: %+v", "[{Foo:42 B:1} {Foo:43}]", objs)
+	}
+
+	if err := decoder.Decode(&obj); err != nil || obj.Foo != 44 {
+		t.Errorf("JSONDecoder.Decode after Close expected: 44 nil, actual: %+v %+v", obj.Foo, err)
+	}
+}
+
+func TestJSONDecoderArrayAt(t *testing.T) {
+	type Obj struct {
+		Foo int `json:"foo" api:"1.1"`
+	}
+
+	jObj := `{"alerts":[{"text":"x"}],"response":[{"foo":42},{"foo":43},{"foo":44}],"summary":{"count":3}}`
+
+	decoder := NewJSON(1.3).NewDecoder(bytes.NewBuffer([]byte(jObj)))
+	it, err := decoder.ArrayAt("response")
+	if err != nil {
+		t.Fatalf("JSONDecoder.ArrayAt error expected: nil, actual: %+v", err)
+	}
+
+	obj := Obj{}
+	if !it.More() {
+		t.Fatalf("JSONArrayIterator.More expected: true, actual: false")
+	}
+	if err := it.Decode(&obj); err != nil || obj.Foo != 42 {
+		t.Errorf("JSONArrayIterator.Decode expected: 42 nil, actual: %+v %+v", obj.Foo, err)
+	}
+	if it.Index() != 1 {
+		t.Errorf("JSONArrayIterator.Index expected: 1, actual: %+v", it.Index())
+	}
+	if err := it.Close(); err != nil {
+		t.Fatalf("JSONArrayIterator.Close error expected: nil, actual: %+v", err)
+	}
+	if decoder.More() {
+		t.Errorf("JSONDecoder.More after Close expected: false, actual: true")
+	}
+}
+
+func TestJSONDecoderArrayAtMissing(t *testing.T) {
+	decoder := NewJSON(1.3).NewDecoder(bytes.NewBuffer([]byte(`{"alerts":[]}`)))
+	_, err := decoder.ArrayAt("response")
+	if err == nil || err.Error() != "missing required field: response" {
+		t.Errorf("JSONDecoder.ArrayAt error expected: 'missing required field: response', actual: %+v", err)
+	}
+	if _, ok := err.(UserError); !ok {
+		t.Errorf("JSONDecoder.ArrayAt error expected UserError, actual %T", err)
+	}
+}
+
+func TestJSONDecoderArrayNotArray(t *testing.T) {
+	decoder := NewJSON(1.3).NewDecoder(bytes.NewBuffer([]byte(`{"foo":42}`)))
+	if _, err := decoder.Array(); err == nil || err.Error() != "expected array" {
+		t.Errorf("JSONDecoder.Array error expected: 'expected array', actual: %+v", err)
+	}
+
+	decoder = NewJSON(1.3).NewDecoder(bytes.NewBuffer([]byte(`{"response":{"foo":42}}`)))
+	if _, err := decoder.ArrayAt("response"); err == nil || err.Error() != "field 'response': expected array" {
+		t.Errorf("JSONDecoder.ArrayAt error expected: 'field 'response': expected array', actual: %+v", err)
+	}
+}
+
+func TestJSONArrayIteratorElementError(t *testing.T) {
+	type Obj struct {
+		Foo int `json:"foo" api:"1.1"`
+	}
+
+	jObj := `[{"foo":42},{},{"foo":44}]`
+
+	decoder := NewJSON(1.3).NewDecoder(bytes.NewBuffer([]byte(jObj)))
+	it, err := decoder.Array()
+	if err != nil {
+		t.Fatalf("JSONDecoder.Array error expected: nil, actual: %+v", err)
+	}
+
+	errs := []error{}
+	for it.More() {
+		obj := Obj{}
+		errs = append(errs, it.Decode(&obj))
+	}
+	if len(errs) != 3 || errs[0] != nil || errs[2] != nil {
+		t.Fatalf("JSONArrayIterator.Decode errors expected: [nil err nil], actual: %+v", errs)
+	}
+	if errs[1].Error() != "element 1: missing required field: foo" {
+		t.Errorf("JSONArrayIterator.Decode error expected: 'element 1: missing required field: foo', actual: %+v", errs[1])
+	}
+	if _, ok := errs[1].(UserError); !ok {
+		t.Errorf("JSONArrayIterator.Decode error expected UserError, actual %T", errs[1])
+	}
+}
+
+type jsonStreamObj struct {
+	Foo  int    `json:"foo" api:"1.1,str"`
+	Text string `json:"text" api:"1.1"`
+	A    *int   `json:"a" api:"1.4"`
+}
+
+func jsonStreamObjs() []jsonStreamObj {
+	a := 24
+	return []jsonStreamObj{{Foo: 42, Text: "<b>", A: &a}, {Foo: 43, A: &a}, {Foo: 44}}
+}
+
+func TestJSONEncoderArray(t *testing.T) {
+	settings := []struct {
+		Prefix     string
+		Indent     string
+		EscapeHTML bool
+	}{{"", "", true}, {"", "", false}, {"", "  ", true}, {">", "\t", false}}
+
+	for _, setting := range settings {
+		for _, objs := range [][]jsonStreamObj{jsonStreamObjs(), {}} {
+			expected := &bytes.Buffer{}
+			encoder := NewJSON(1.3).NewEncoder(expected)
+			encoder.SetIndent(setting.Prefix, setting.Indent)
+			encoder.SetEscapeHTML(setting.EscapeHTML)
+			if err := encoder.Encode(objs); err != nil {
+				t.Fatalf("JSONEncoder.Encode error expected: nil, actual: %+v", err)
+			}
+
+			actual := &bytes.Buffer{}
+			encoder = NewJSON(1.3).NewEncoder(actual)
+			encoder.SetIndent(setting.Prefix, setting.Indent)
+			encoder.SetEscapeHTML(setting.EscapeHTML)
+			arr, err := encoder.Array()
+			if err != nil {
+				t.Fatalf("JSONEncoder.Array error expected: nil, actual: %+v", err)
+			}
+			if err := arr.EncodeAll(objs); err != nil {
+				t.Fatalf("JSONArrayEncoder.EncodeAll error expected: nil, actual: %+v", err)
+			}
+			if err := arr.Close(); err != nil {
+				t.Fatalf("JSONArrayEncoder.Close error expected: nil, actual: %+v", err)
+			}
+
+			if actual.String() != expected.String() {
+				t.Errorf("JSONArrayEncoder %+v expected ''%+v'', actual ''%+v''", setting, expected.String(), actual.String())
+			}
+		}
+	}
+}
+
+func TestJSONEncoderArrayAt(t *testing.T) {
+	for _, indent := range []string{"", "  "} {
+		for _, objs := range [][]jsonStreamObj{jsonStreamObjs(), {}} {
+			expected := &bytes.Buffer{}
+			encoder := NewJSON(1.3).NewEncoder(expected)
+			encoder.SetIndent("", indent)
+			if err := encoder.Encode(map[string][]jsonStreamObj{"response": objs}); err != nil {
+				t.Fatalf("JSONEncoder.Encode error expected: nil, actual: %+v", err)
+			}
+
+			actual := &bytes.Buffer{}
+			encoder = NewJSON(1.3).NewEncoder(actual)
+			encoder.SetIndent("", indent)
+			arr, err := encoder.ArrayAt("response")
+			if err != nil {
+				t.Fatalf("JSONEncoder.ArrayAt error expected: nil, actual: %+v", err)
+			}
+			ch := make(chan jsonStreamObj)
+			go func() {
+				for _, obj := range objs {
+					ch <- obj
+				}
+				close(ch)
+			}()
+			if err := arr.EncodeAll(ch); err != nil {
+				t.Fatalf("JSONArrayEncoder.EncodeAll error expected: nil, actual: %+v", err)
+			}
+			if err := arr.Close(); err != nil {
+				t.Fatalf("JSONArrayEncoder.Close error expected: nil, actual: %+v", err)
+			}
+
+			if actual.String() != expected.String() {
+				t.Errorf("JSONArrayEncoder indent '%+v' expected ''%+v'', actual ''%+v''", indent, expected.String(), actual.String())
+			}
+		}
+	}
+}
+
+func TestJSONArrayEncoderEncodeFunc(t *testing.T) {
+	objs := jsonStreamObjs()
+
+	buf := &bytes.Buffer{}
+	arr, err := NewJSON(1.3).NewEncoder(buf).Array()
+	if err != nil {
+		t.Fatalf("JSONEncoder.Array error expected: nil, actual: %+v", err)
+	}
+	i := 0
+	err = arr.EncodeFunc(func() (interface{}, bool, error) {
+		if i >= len(objs) {
+			return nil, false, nil
+		}
+		i++
+		return objs[i-1], true, nil
+	})
+	if err != nil {
+		t.Fatalf("JSONArrayEncoder.EncodeFunc error expected: nil, actual: %+v", err)
+	}
+	if err := arr.Close(); err != nil {
+		t.Fatalf("JSONArrayEncoder.Close error expected: nil, actual: %+v", err)
+	}
+
+	expected := `[{"foo":42,"text":"\u003cb\u003e"},{"foo":43,"text":""},{"foo":44,"text":""}]` + "\n"
+	if buf.String() != expected {
+		t.Errorf("JSONArrayEncoder.EncodeFunc expected ''%+v'', actual ''%+v''", expected, buf.String())
+	}
+}
+
+func TestJSONArrayEncoderBadInput(t *testing.T) {
+	arr, err := NewJSON(1.3).NewEncoder(&bytes.Buffer{}).Array()
+	if err != nil {
+		t.Fatalf("JSONEncoder.Array error expected: nil, actual: %+v", err)
+	}
+	if err := arr.EncodeAll(42); err == nil {
+		t.Errorf("JSONArrayEncoder.EncodeAll non-slice error expected: non-nil, actual: nil")
+	}
+
+	encoder := &JSONEncoder{Version: 1.3}
+	if _, err := encoder.Array(); err == nil {
+		t.Errorf("JSONEncoder.Array without NewEncoder error expected: non-nil, actual: nil")
+	}
+}
@@ -0,0 +1,113 @@
+package apiver
+
+import (
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Converter upgrades an object to the version it was registered with. The obj is always a pointer to the registered type.
+// Converters are used by Migrate to populate fields which are new in a version, or to move data from old fields into new ones.
+type Converter func(obj interface{}) error
+
+// Defaulter may be implemented by objects passed to Migrate, to set default values for fields introduced after the version the object was decoded at.
+// Defaults are applied after decoding, and before any registered converters.
+type Defaulter interface {
+	APIDefaults(fromVersion, toVersion float64)
+}
+
+type converterVersion struct {
+	Version   float64
+	Converter Converter
+}
+
+var convertersMutex sync.RWMutex
+var converters = map[reflect.Type][]converterVersion{}
+
+// RegisterConverter registers conv to upgrade objects of type typ to version.
+// Migrate calls every converter whose version is newer than fromVersion and no newer than toVersion, in version order.
+// Registering a second converter for the same type and version replaces the first.
+func RegisterConverter(typ reflect.Type, version float64, conv Converter) {
+	convertersMutex.Lock()
+	defer convertersMutex.Unlock()
+	convs := converters[typ]
+	for i, cv := range convs {
+		if cv.Version == version {
+			convs[i].Converter = conv
+			return
+		}
+	}
+	convs = append(convs, converterVersion{Version: version, Converter: conv})
+	sort.Slice(convs, func(i, j int) bool { return convs[i].Version < convs[j].Version })
+	converters[typ] = convs
+}
+
+// getConverters returns the converters registered for typ, which are newer than fromVersion and no newer than toVersion, in version order.
+func getConverters(typ reflect.Type, fromVersion, toVersion float64) []converterVersion {
+	convertersMutex.RLock()
+	defer convertersMutex.RUnlock()
+	convs := []converterVersion{}
+	for _, cv := range converters[typ] {
+		if cv.Version > fromVersion && cv.Version <= toVersion {
+			convs = append(convs, cv)
+		}
+	}
+	return convs
+}
+
+// Migrate converts the JSON document bts of type typ from fromVersion to toVersion.
+// The document is decoded with UnmarshalJSON at fromVersion into a new object of type typ; defaults are applied, if the object implements Defaulter; registered converters are applied; and the object is encoded with MarshalJSON at toVersion.
+// Migrating to an older version has no converters, and simply omits fields newer than toVersion.
+func Migrate(bts []byte, typ reflect.Type, fromVersion, toVersion float64) ([]byte, error) {
+	obj, err := migrateObj(typ, fromVersion, toVersion, func(realObj interface{}) error {
+		return UnmarshalJSON(bts, realObj, fromVersion)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return MarshalJSON(obj, toVersion)
+}
+
+// MigrateJSONLines migrates a stream of JSON documents of type typ, such as a JSON-lines file, from fromVersion to toVersion.
+// Each document is read from r, migrated as Migrate does, and written to w followed by a newline.
+// Errors include the zero-based index of the document which failed.
+func MigrateJSONLines(r io.Reader, w io.Writer, typ reflect.Type, fromVersion, toVersion float64) error {
+	decoder := NewJSON(fromVersion).NewDecoder(r)
+	encoder := NewJSON(toVersion).NewEncoder(w)
+	for i := 0; decoder.More(); i++ {
+		obj, err := migrateObj(typ, fromVersion, toVersion, decoder.Decode)
+		if err != nil {
+			return prefixError("document "+strconv.Itoa(i)+": ", err)
+		}
+		if err := encoder.Encode(obj); err != nil {
+			return prefixError("document "+strconv.Itoa(i)+": ", err)
+		}
+	}
+	return nil
+}
+
+// migrateObj creates a new object of type typ, decodes into it with decode, and applies defaults and converters to bring it from fromVersion to toVersion.
+// Returns a pointer to the new object.
+func migrateObj(typ reflect.Type, fromVersion, toVersion float64, decode func(realObj interface{}) error) (interface{}, error) {
+	if typ == nil {
+		return nil, InternalError{"type must not be nil"}
+	}
+	obj := reflect.New(typ).Interface()
+	if err := decode(obj); err != nil {
+		return nil, err
+	}
+	if toVersion <= fromVersion {
+		return obj, nil
+	}
+	if defaulter, ok := obj.(Defaulter); ok {
+		defaulter.APIDefaults(fromVersion, toVersion)
+	}
+	for _, cv := range getConverters(typ, fromVersion, toVersion) {
+		if err := cv.Converter(obj); err != nil {
+			return nil, prefixError("converting to version "+strconv.FormatFloat(cv.Version, 'f', -1, 64)+": ", err)
+		}
+	}
+	return obj, nil
+}
@@ -0,0 +1,154 @@
+package apiver
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type migrateObj1 struct {
+	Name     string  `json:"name" api:"1.1"`
+	Port     *int    `json:"port" api:"1.2"`
+	FullName *string `json:"full_name" api:"1.3"`
+}
+
+func (o *migrateObj1) APIDefaults(fromVersion, toVersion float64) {
+	if fromVersion < 1.2 && toVersion >= 1.2 && o.Port == nil {
+		port := 80
+		o.Port = &port
+	}
+}
+
+func TestMigrateDefaults(t *testing.T) {
+	objJ := `{"name":"foo"}`
+	actual, err := Migrate([]byte(objJ), reflect.TypeOf(migrateObj1{}), 1.1, 1.2)
+	if err != nil {
+		t.Fatalf("Migrate error expected: nil, actual: %+v", err)
+	}
+
+	expected := `{"name":"foo","port":80}`
+	if string(actual) != expected {
+		t.Errorf("Migrate expected ''%+v'', actual ''%+v''", expected, string(actual))
+	}
+}
+
+func TestMigrateConverter(t *testing.T) {
+	type Obj struct {
+		Name     string  `json:"name" api:"1.1"`
+		Domain   string  `json:"domain" api:"1.1"`
+		FullName *string `json:"full_name" api:"1.3"`
+	}
+	RegisterConverter(reflect.TypeOf(Obj{}), 1.3, func(obj interface{}) error {
+		o := obj.(*Obj)
+		fullName := o.Name + "." + o.Domain
+		o.FullName = &fullName
+		return nil
+	})
+
+	objJ := `{"name":"foo","domain":"example.net"}`
+	actual, err := Migrate([]byte(objJ), reflect.TypeOf(Obj{}), 1.1, 1.4)
+	if err != nil {
+		t.Fatalf("Migrate error expected: nil, actual: %+v", err)
+	}
+
+	expected := `{"name":"foo","domain":"example.net","full_name":"foo.example.net"}`
+	if string(actual) != expected {
+		t.Errorf("Migrate expected ''%+v'', actual ''%+v''", expected, string(actual))
+	}
+}
+
+func TestMigrateConverterOrder(t *testing.T) {
+	type Obj struct {
+		Steps []string `json:"steps" api:"1.1"`
+	}
+	for _, v := range []float64{1.4, 1.2, 1.3, 1.5} {
+		v := v
+		RegisterConverter(reflect.TypeOf(Obj{}), v, func(obj interface{}) error {
+			o := obj.(*Obj)
+			o.Steps = append(o.Steps, strings.TrimPrefix(strconv.FormatFloat(v, 'f', -1, 64), "1."))
+			return nil
+		})
+	}
+
+	objJ := `{"steps":[]}`
+	actual, err := Migrate([]byte(objJ), reflect.TypeOf(Obj{}), 1.2, 1.4)
+	if err != nil {
+		t.Fatalf("Migrate error expected: nil, actual: %+v", err)
+	}
+
+	expected := `{"steps":["3","4"]}`
+	if string(actual) != expected {
+		t.Errorf("Migrate expected ''%+v'', actual ''%+v''", expected, string(actual))
+	}
+}
+
+func TestMigrateConverterError(t *testing.T) {
+	type Obj struct {
+		Name string `json:"name" api:"1.1"`
+	}
+	RegisterConverter(reflect.TypeOf(Obj{}), 1.2, func(obj interface{}) error {
+		return errors.New("bad name")
+	})
+
+	_, err := Migrate([]byte(`{"name":"foo"}`), reflect.TypeOf(Obj{}), 1.1, 1.2)
+	if err == nil || !strings.Contains(err.Error(), "bad name") {
+		t.Errorf("Migrate error expected: 'bad name', actual: %+v", err)
+	}
+}
+
+func TestMigrateDowngrade(t *testing.T) {
+	objJ := `{"name":"foo","port":8080,"full_name":"foo.example.net"}`
+	actual, err := Migrate([]byte(objJ), reflect.TypeOf(migrateObj1{}), 1.3, 1.1)
+	if err != nil {
+		t.Fatalf("Migrate error expected: nil, actual: %+v", err)
+	}
+
+	expected := `{"name":"foo"}`
+	if string(actual) != expected {
+		t.Errorf("Migrate expected ''%+v'', actual ''%+v''", expected, string(actual))
+	}
+}
+
+func TestMigrateMissingRequiredField(t *testing.T) {
+	_, err := Migrate([]byte(`{"port":8080}`), reflect.TypeOf(migrateObj1{}), 1.2, 1.3)
+	if _, ok := err.(UserError); !ok {
+		t.Errorf("Migrate error expected: UserError, actual: %T %+v", err, err)
+	}
+}
+
+func TestMigrateJSONLines(t *testing.T) {
+	in := `{"name":"foo"}
+{"name":"bar","port":8080}
+`
+	out := &bytes.Buffer{}
+	if err := MigrateJSONLines(strings.NewReader(in), out, reflect.TypeOf(migrateObj1{}), 1.1, 1.2); err != nil {
+		t.Fatalf("MigrateJSONLines error expected: nil, actual: %+v", err)
+	}
+
+	expected := `{"name":"foo","port":80}
+{"name":"bar","port":80}
+`
+	if out.String() != expected {
+		t.Errorf("MigrateJSONLines expected ''%+v'', actual ''%+v''", expected, out.String())
+	}
+}
+
+func TestMigrateJSONLinesErrIndex(t *testing.T) {
+	in := `{"name":"foo"}
+{"port":8080}
+`
+	out := &bytes.Buffer{}
+	err := MigrateJSONLines(strings.NewReader(in), out, reflect.TypeOf(migrateObj1{}), 1.1, 1.2)
+	if err == nil {
+		t.Fatalf("MigrateJSONLines error expected: document 1, actual: nil")
+	}
+	if _, ok := err.(UserError); !ok {
+		t.Errorf("MigrateJSONLines error expected: UserError, actual: %T %+v", err, err)
+	}
+	if !strings.HasPrefix(err.Error(), "document 1: ") {
+		t.Errorf("MigrateJSONLines error expected: prefix 'document 1: ', actual: %+v", err)
+	}
+}
@@ -0,0 +1,75 @@
+// Package msgpack provides versioned MessagePack encoding and decoding, with the same semantics as the apiver JSON functions.
+// It's implemented as an apiver.Codec, using github.com/vmihailenco/msgpack.
+package msgpack
+
+import (
+	"bytes"
+	"reflect"
+
+	"github.com/rob05c/apiver"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec is the apiver.Codec for MessagePack. It's registered with apiver as "msgpack".
+// Built types have msgpack tags derived from json tags, for fields without msgpack tags, so the same struct definitions and names may be used for both encodings.
+var Codec apiver.Codec = codec{apiver.BaseCodec{Tag: "msgpack"}}
+
+func init() {
+	apiver.RegisterCodec("msgpack", Codec)
+}
+
+// Unmarshal parses MessagePack for the given object.
+// This behaves exactly like apiver.UnmarshalJSON: fields newer than version are not deserialized into, 'str' fields accept strings, and missing required fields return the same apiver.UserError.
+func Unmarshal(bts []byte, realObj interface{}, version float64) error {
+	return apiver.UnmarshalCodec(Codec, bts, realObj, version)
+}
+
+// UnmarshalWithOptions is Unmarshal, with the tag names and behavior of opts, like apiver.UnmarshalJSONWithOptions.
+func UnmarshalWithOptions(bts []byte, realObj interface{}, version float64, opts apiver.Options) error {
+	return apiver.UnmarshalCodecWithOptions(Codec, bts, realObj, version, opts)
+}
+
+// Marshal serializes the given object as MessagePack, omitting fields newer than version.
+func Marshal(realObj interface{}, version float64) ([]byte, error) {
+	return apiver.MarshalCodec(Codec, realObj, version)
+}
+
+// MarshalWithOptions is Marshal, with the tag names and behavior of opts.
+func MarshalWithOptions(realObj interface{}, version float64, opts apiver.Options) ([]byte, error) {
+	return apiver.MarshalCodecWithOptions(Codec, realObj, version, opts)
+}
+
+type codec struct{ apiver.BaseCodec }
+
+func (c codec) FieldTag(field reflect.StructField) reflect.StructTag {
+	return apiver.DeriveTagFromJSON(field, c.Tag)
+}
+
+func (c codec) StrType(kind reflect.Kind) reflect.Type {
+	switch apiver.DefaultStrType(kind) {
+	case reflect.TypeOf(apiver.IntS(0)):
+		return reflect.TypeOf(IntS(0))
+	case reflect.TypeOf(apiver.UIntS(0)):
+		return reflect.TypeOf(UIntS(0))
+	case reflect.TypeOf(apiver.FloatS(0)):
+		return reflect.TypeOf(FloatS(0))
+	case reflect.TypeOf(apiver.BoolS(false)):
+		return reflect.TypeOf(BoolS(false))
+	default:
+		return nil
+	}
+}
+
+func (c codec) Marshal(fakeObj interface{}, realType reflect.Type) ([]byte, error) {
+	return msgpack.Marshal(fakeObj)
+}
+
+func (c codec) Unmarshal(data []byte, fakeObj interface{}) error {
+	return msgpack.Unmarshal(data, fakeObj)
+}
+
+func (c codec) UnmarshalDisallowUnknownFields(data []byte, fakeObj interface{}) error {
+	decoder := msgpack.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields(true)
+	return decoder.Decode(fakeObj)
+}
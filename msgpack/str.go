@@ -0,0 +1,51 @@
+package msgpack
+
+import (
+	"github.com/rob05c/apiver"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// IntS decodes a MessagePack integer or string as an integer. It's the msgpack equivalent of apiver.IntS.
+type IntS int64
+
+func (i *IntS) DecodeMsgpack(dec *msgpack.Decoder) error {
+	v, err := dec.DecodeInterfaceLoose()
+	if err != nil {
+		return err
+	}
+	return apiver.UnmarshalStrValue(v, (*apiver.IntS)(i))
+}
+
+// UIntS decodes a MessagePack unsigned integer or string as an unsigned integer. It's the msgpack equivalent of apiver.UIntS.
+type UIntS uint64
+
+func (i *UIntS) DecodeMsgpack(dec *msgpack.Decoder) error {
+	v, err := dec.DecodeInterfaceLoose()
+	if err != nil {
+		return err
+	}
+	return apiver.UnmarshalStrValue(v, (*apiver.UIntS)(i))
+}
+
+// FloatS decodes a MessagePack number or string as a number. It's the msgpack equivalent of apiver.FloatS.
+type FloatS float64
+
+func (i *FloatS) DecodeMsgpack(dec *msgpack.Decoder) error {
+	v, err := dec.DecodeInterfaceLoose()
+	if err != nil {
+		return err
+	}
+	return apiver.UnmarshalStrValue(v, (*apiver.FloatS)(i))
+}
+
+// BoolS decodes a MessagePack bool, number, or string as a boolean. It's the msgpack equivalent of apiver.BoolS.
+// Numbers are false if they're zero, and strings are decoded like Perl: "" and "0" are false; all other values are true.
+type BoolS bool
+
+func (i *BoolS) DecodeMsgpack(dec *msgpack.Decoder) error {
+	v, err := dec.DecodeInterfaceLoose()
+	if err != nil {
+		return err
+	}
+	return apiver.UnmarshalStrValue(v, (*apiver.BoolS)(i))
+}
@@ -0,0 +1,233 @@
+package apiver
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// OpenAPIVersion is the openapi version of the documents OpenAPI generates.
+const OpenAPIVersion = `3.1.0`
+
+// OpenAPI generates an OpenAPI 3.1 document per API version, from the request and response types of routes, so documentation can't drift from the Go structs.
+// Component schemas are built like JSONSchema: only fields which exist at the version, with required fields, str fields, and deprecated fields from api tags and registered schemas.
+//
+// Example:
+//
+//	api := apiver.NewOpenAPI("Traffic Ops")
+//	api.ServerURL = "https://example.com/api/{version}"
+//	api.AddRoute(apiver.OpenAPIRoute{Method: http.MethodGet, Path: "/servers", Response: reflect.TypeOf([]Server{})})
+//	api.AddRoute(apiver.OpenAPIRoute{Method: http.MethodPost, Path: "/servers", Since: 1.2, Request: reflect.TypeOf(Server{}), Response: reflect.TypeOf(Server{})})
+//	docs, err := api.Documents(1.1, 1.2, 1.3)
+type OpenAPI struct {
+	// Title is the title of every document.
+	Title string
+	// Description is the description of every document.
+	Description string
+	// ServerURL is the URL of the API, if any. Every "{version}" is replaced with the document's version, for APIs with the version in their paths.
+	ServerURL string
+	// Options are the tag names used to build component schemas.
+	Options Options
+
+	routes  []OpenAPIRoute
+	schemas []reflect.Type
+}
+
+// OpenAPIRoute is an operation of an API, and the version it was added in.
+type OpenAPIRoute struct {
+	// Method is the HTTP method, such as http.MethodGet.
+	Method string
+	// Path is the path of the route, relative to the ServerURL, such as "/servers/{id}".
+	Path string
+	// Since is the version the route was added in. The route isn't in the documents of older versions. Routes with the same Method and Path, but different Since, are successive versions of the route, and each document has the newest one which exists at its version.
+	Since float64
+	// Deprecated is whether the route is deprecated.
+	Deprecated  bool
+	OperationID string
+	Summary     string
+	Tags        []string
+	// Request is the type of the JSON request body, or nil if there is no body.
+	Request reflect.Type
+	// Response is the type of the JSON response body, or nil if there is no body.
+	Response reflect.Type
+	// Status is the status code of a successful response. The default is 200, or 204 if there is no Response.
+	Status int
+}
+
+// NewOpenAPI returns an OpenAPI generator with the given document title.
+func NewOpenAPI(title string) *OpenAPI {
+	return &OpenAPI{Title: title}
+}
+
+// AddRoute adds the route to the documents of its Since version and newer.
+// Returns an InternalError if the route has no method or path, or another route has the same method, path, and Since.
+func (o *OpenAPI) AddRoute(route OpenAPIRoute) error {
+	if route.Method == "" || route.Path == "" {
+		return InternalError{"OpenAPI route must have a method and path"}
+	}
+	route.Method = strings.ToUpper(route.Method)
+	for _, other := range o.routes {
+		if other.Method == route.Method && other.Path == route.Path && other.Since == route.Since {
+			return InternalError{"OpenAPI route '" + route.Method + " " + route.Path + "' since " + formatVersion(route.Since) + " added twice"}
+		}
+	}
+	o.routes = append(o.routes, route)
+	return nil
+}
+
+// AddSchema adds the schema of typ to the components of every document, even if no route uses it. Named struct types are named by their type name.
+func (o *OpenAPI) AddSchema(typ reflect.Type) {
+	o.schemas = append(o.schemas, typ)
+}
+
+// Documents returns the document of each of the given versions. See Document.
+func (o *OpenAPI) Documents(versions ...float64) (map[float64]*OpenAPIDocument, error) {
+	docs := map[float64]*OpenAPIDocument{}
+	for _, version := range versions {
+		doc, err := o.Document(version)
+		if err != nil {
+			return nil, err
+		}
+		docs[version] = doc
+	}
+	return docs, nil
+}
+
+// Document returns the OpenAPI document of version, with the routes which exist at version, and the component schemas of their request and response types at version. Use json.Marshal to serialize it.
+// Returns an InternalError if a type can't be encoded as JSON, as JSONSchema.
+func (o *OpenAPI) Document(version float64) (*OpenAPIDocument, error) {
+	b := newJSONSchemaBuilder(version, o.Options, "#/components/schemas/")
+	doc := &OpenAPIDocument{
+		OpenAPI: OpenAPIVersion,
+		Info:    OpenAPIInfo{Title: o.Title, Description: o.Description, Version: formatVersion(version)},
+		Paths:   map[string]OpenAPIPathItem{},
+	}
+	if o.ServerURL != "" {
+		doc.Servers = []OpenAPIServer{{URL: strings.Replace(o.ServerURL, "{version}", formatVersion(version), -1)}}
+	}
+
+	for _, route := range o.currentRoutes(version) {
+		op := &OpenAPIOperation{OperationID: route.OperationID, Summary: route.Summary, Tags: route.Tags, Deprecated: route.Deprecated, Responses: map[string]*OpenAPIResponse{}}
+		if route.Request != nil {
+			schema, err := b.build(route.Request)
+			if err != nil {
+				return nil, InternalError{"OpenAPI route '" + route.Method + " " + route.Path + "' request: " + err.Error()}
+			}
+			op.RequestBody = &OpenAPIRequestBody{Required: true, Content: openAPIJSONContent(schema)}
+		}
+
+		status := route.Status
+		if status == 0 && route.Response == nil {
+			status = http.StatusNoContent
+		} else if status == 0 {
+			status = http.StatusOK
+		}
+		resp := &OpenAPIResponse{Description: http.StatusText(status)}
+		if route.Response != nil {
+			schema, err := b.build(route.Response)
+			if err != nil {
+				return nil, InternalError{"OpenAPI route '" + route.Method + " " + route.Path + "' response: " + err.Error()}
+			}
+			resp.Content = openAPIJSONContent(schema)
+		}
+		op.Responses[strconv.Itoa(status)] = resp
+
+		if doc.Paths[route.Path] == nil {
+			doc.Paths[route.Path] = OpenAPIPathItem{}
+		}
+		doc.Paths[route.Path][strings.ToLower(route.Method)] = op
+	}
+
+	for _, typ := range o.schemas {
+		if _, err := b.build(typ); err != nil {
+			return nil, err
+		}
+	}
+	if len(b.defs) > 0 {
+		doc.Components = &OpenAPIComponents{Schemas: b.defs}
+	}
+	return doc, nil
+}
+
+// currentRoutes returns the routes which exist at version: for each method and path, the route with the newest Since which isn't newer than version. Routes are sorted by path, then method.
+func (o *OpenAPI) currentRoutes(version float64) []OpenAPIRoute {
+	current := map[string]OpenAPIRoute{}
+	for _, route := range o.routes {
+		if route.Since > version {
+			continue
+		}
+		key := route.Path + " " + route.Method
+		if other, ok := current[key]; !ok || route.Since > other.Since {
+			current[key] = route
+		}
+	}
+	keys := []string{}
+	for key := range current {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	routes := []OpenAPIRoute{}
+	for _, key := range keys {
+		routes = append(routes, current[key])
+	}
+	return routes
+}
+
+func openAPIJSONContent(schema *JSONSchemaObject) map[string]OpenAPIMediaType {
+	return map[string]OpenAPIMediaType{"application/json": {Schema: schema}}
+}
+
+func formatVersion(version float64) string {
+	return strconv.FormatFloat(version, 'f', -1, 64)
+}
+
+// OpenAPIDocument is an OpenAPI 3.1 document. It has only the fields OpenAPI generates.
+type OpenAPIDocument struct {
+	OpenAPI    string                     `json:"openapi"`
+	Info       OpenAPIInfo                `json:"info"`
+	Servers    []OpenAPIServer            `json:"servers,omitempty"`
+	Paths      map[string]OpenAPIPathItem `json:"paths"`
+	Components *OpenAPIComponents         `json:"components,omitempty"`
+}
+
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type OpenAPIServer struct {
+	URL string `json:"url"`
+}
+
+// OpenAPIPathItem is the operations of a path, by lower-case method, such as "get".
+type OpenAPIPathItem map[string]*OpenAPIOperation
+
+type OpenAPIOperation struct {
+	OperationID string                      `json:"operationId,omitempty"`
+	Summary     string                      `json:"summary,omitempty"`
+	Tags        []string                    `json:"tags,omitempty"`
+	Deprecated  bool                        `json:"deprecated,omitempty"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+}
+
+type OpenAPIRequestBody struct {
+	Required bool                        `json:"required,omitempty"`
+	Content  map[string]OpenAPIMediaType `json:"content"`
+}
+
+type OpenAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]OpenAPIMediaType `json:"content,omitempty"`
+}
+
+type OpenAPIMediaType struct {
+	Schema *JSONSchemaObject `json:"schema"`
+}
+
+type OpenAPIComponents struct {
+	Schemas map[string]*JSONSchemaObject `json:"schemas,omitempty"`
+}
@@ -0,0 +1,136 @@
+package apiver
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type openAPIServer struct {
+	Name   string  `json:"name" api:"1.1"`
+	Port   int     `json:"port" api:"1.1,str,deprecated"`
+	Weight *int    `json:"weight" api:"1.2"`
+	Cache  *string `json:"cache" api:"1.3"`
+}
+
+type openAPIError struct {
+	Message string `json:"message"`
+}
+
+func newTestOpenAPI(t *testing.T) *OpenAPI {
+	api := NewOpenAPI("Servers")
+	api.ServerURL = "https://example.com/api/{version}"
+	routes := []OpenAPIRoute{
+		{Method: http.MethodGet, Path: "/servers", Since: 1.1, OperationID: "getServers", Response: reflect.TypeOf([]openAPIServer{})},
+		{Method: "post", Path: "/servers", Since: 1.2, OperationID: "createServer", Request: reflect.TypeOf(openAPIServer{}), Response: reflect.TypeOf(openAPIServer{}), Status: http.StatusCreated},
+		{Method: http.MethodDelete, Path: "/servers/{name}", Since: 1.1, Deprecated: true},
+		{Method: http.MethodDelete, Path: "/servers/{name}", Since: 1.3, OperationID: "deleteServer"},
+	}
+	for _, route := range routes {
+		if err := api.AddRoute(route); err != nil {
+			t.Fatalf("AddRoute error expected: nil, actual: %+v", err)
+		}
+	}
+	api.AddSchema(reflect.TypeOf(openAPIError{}))
+	return api
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	api := newTestOpenAPI(t)
+	doc, err := api.Document(1.2)
+	if err != nil {
+		t.Fatalf("Document error expected: nil, actual: %+v", err)
+	}
+	actual, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("json.Marshal error expected: nil, actual: %+v", err)
+	}
+	expected := `{"openapi":"3.1.0","info":{"title":"Servers","version":"1.2"},"servers":[{"url":"https://example.com/api/1.2"}],` +
+		`"paths":{"/servers":{"get":{"operationId":"getServers","responses":{"200":{"description":"OK","content":{"application/json":{"schema":{"type":["array","null"],"items":{"$ref":"#/components/schemas/openAPIServer"}}}}}}},` +
+		`"post":{"operationId":"createServer","requestBody":{"required":true,"content":{"application/json":{"schema":{"$ref":"#/components/schemas/openAPIServer"}}}},` +
+		`"responses":{"201":{"description":"Created","content":{"application/json":{"schema":{"$ref":"#/components/schemas/openAPIServer"}}}}}}},` +
+		`"/servers/{name}":{"delete":{"deprecated":true,"responses":{"204":{"description":"No Content"}}}}},` +
+		`"components":{"schemas":{"openAPIError":{"title":"openAPIError","type":"object","properties":{"message":{"type":"string"}}},` +
+		`"openAPIServer":{"title":"openAPIServer","type":"object","properties":{"name":{"type":"string"},"port":{"deprecated":true,"oneOf":[{"type":"integer"},{"type":"string"}]},"weight":{"type":["integer","null"]}},"required":["name","port"]}}}}`
+	if string(actual) != expected {
+		t.Errorf("Document expected: %v, actual: %s", expected, actual)
+	}
+}
+
+func TestOpenAPIDocuments(t *testing.T) {
+	api := newTestOpenAPI(t)
+	docs, err := api.Documents(1.0, 1.1, 1.3)
+	if err != nil {
+		t.Fatalf("Documents error expected: nil, actual: %+v", err)
+	}
+	if len(docs[1.0].Paths) != 0 {
+		t.Errorf("Documents 1.0 paths expected: none, actual: %+v", docs[1.0].Paths)
+	}
+	if _, ok := docs[1.1].Paths["/servers"]["post"]; ok {
+		t.Errorf("Documents 1.1 expected: no post, actual: %+v", docs[1.1].Paths["/servers"])
+	}
+	if del := docs[1.3].Paths["/servers/{name}"]["delete"]; del == nil || del.OperationID != "deleteServer" || del.Deprecated {
+		t.Errorf("Documents 1.3 delete expected: %+v, actual: %+v", "deleteServer", del)
+	}
+	if server := docs[1.3].Components.Schemas["openAPIServer"]; server == nil || server.Properties["cache"] == nil {
+		t.Errorf("Documents 1.3 server expected: cache property, actual: %+v", server)
+	}
+	if server := docs[1.1].Components.Schemas["openAPIServer"]; server == nil || server.Properties["weight"] != nil {
+		t.Errorf("Documents 1.1 server expected: no weight property, actual: %+v", server)
+	}
+}
+
+func TestOpenAPIErrors(t *testing.T) {
+	api := newTestOpenAPI(t)
+	if err := api.AddRoute(OpenAPIRoute{Method: http.MethodGet, Path: "/servers", Since: 1.1}); err == nil || !strings.Contains(err.Error(), "added twice") {
+		t.Errorf("AddRoute duplicate error expected: %+v, actual: %+v", "added twice", err)
+	}
+	if err := api.AddRoute(OpenAPIRoute{Path: "/servers"}); err == nil {
+		t.Errorf("AddRoute no method error expected: %+v, actual: %+v", "must have a method and path", err)
+	}
+
+	type chanObj struct {
+		C chan int `json:"c"`
+	}
+	api.AddRoute(OpenAPIRoute{Method: http.MethodPut, Path: "/chan", Request: reflect.TypeOf(chanObj{})})
+	if _, err := api.Document(1.1); err == nil || !strings.Contains(err.Error(), "'PUT /chan' request") {
+		t.Errorf("Document unencodable error expected: %+v, actual: %+v", "'PUT /chan' request", err)
+	}
+}
+
+type OpenAPIMeta struct {
+	Created int `json:"created" api:"1.1"`
+}
+
+// openAPICache embeds a versioned struct, which MarshalJSON nests under the type name, so the document must too.
+type openAPICache struct {
+	OpenAPIMeta
+	Host string `json:"host" api:"1.1"`
+}
+
+func TestOpenAPIEmbedded(t *testing.T) {
+	api := NewOpenAPI("Caches")
+	if err := api.AddRoute(OpenAPIRoute{Method: http.MethodGet, Path: "/caches", Since: 1.1, Response: reflect.TypeOf(openAPICache{})}); err != nil {
+		t.Fatalf("AddRoute error expected: nil, actual: %+v", err)
+	}
+	doc, err := api.Document(1.1)
+	if err != nil {
+		t.Fatalf("Document error expected: nil, actual: %+v", err)
+	}
+	actual, err := json.Marshal(doc.Components)
+	if err != nil {
+		t.Fatalf("json.Marshal error expected: nil, actual: %+v", err)
+	}
+	expected := `{"schemas":{"OpenAPIMeta":{"title":"OpenAPIMeta","type":"object","properties":{"created":{"type":"integer"}},"required":["created"]},` +
+		`"openAPICache":{"title":"openAPICache","type":"object","properties":{"OpenAPIMeta":{"$ref":"#/components/schemas/OpenAPIMeta"},"host":{"type":"string"}},"required":["OpenAPIMeta","host"]}}}`
+	if string(actual) != expected {
+		t.Errorf("Document components expected: %v, actual: %s", expected, actual)
+	}
+
+	bts, err := MarshalJSON(openAPICache{OpenAPIMeta{Created: 3}, "h"}, 1.1)
+	if expected := `{"OpenAPIMeta":{"created":3},"host":"h"}`; err != nil || string(bts) != expected {
+		t.Errorf("MarshalJSON expected: %v, actual: %s %+v", expected, bts, err)
+	}
+}
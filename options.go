@@ -0,0 +1,168 @@
+package apiver
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Options configures the tag name, tag property names, and behavior of apiver per call site, so libraries using apiver with different tags can coexist, and existing version tags can be reused.
+// The zero value is the default: versions and properties in the `api` tag, with the TagPropertyStr, TagPropertyRequired, and TagPropertyOptional property names.
+// VersionMarshaler and VersionUnmarshaler methods, such as those generated by cmd/apivergen, parse the default tags, so they are only called with default tag names.
+type Options struct {
+	// TagName is the name of the tag with versions and properties. The default is TagName.
+	TagName string
+	// PropertyStr is the name of the tag property to parse numbers and booleans from strings. The default is TagPropertyStr.
+	PropertyStr string
+	// PropertyRequired is the name of the tag property to require an unversioned field. The default is TagPropertyRequired.
+	PropertyRequired string
+	// PropertyOptional is the name of the tag property to allow a versioned non-pointer field to be missing. The default is TagPropertyOptional.
+	PropertyOptional string
+	// PropertyDeprecated is the name of the tag property to mark a field deprecated in generated documentation. The default is TagPropertyDeprecated.
+	PropertyDeprecated string
+
+	// DisallowUnknownFields is whether unmarshalling returns an error for fields which don't exist in the object, including fields newer than the version, like json.Decoder.DisallowUnknownFields.
+	// It applies to JSON, CSV columns, query parameters, and every UnknownFieldsCodec, such as YAML. Unmarshalling with a codec which can't reject unknown fields, such as XML, returns an InternalError.
+	DisallowUnknownFields bool
+	// Strict is whether marshalling and unmarshalling, in every format, validate the object's type with ValidateType first, returning its InternalError instead of silently ignoring malformed tags. Strict calls don't call VersionMarshaler and VersionUnmarshaler methods.
+	Strict bool
+	// RequireVersions is whether marshalling and unmarshalling, in every format, check the object's type with CheckVersionTags first, returning its InternalError if any exported field has no version. RequireVersions calls don't call VersionMarshaler and VersionUnmarshaler methods.
+	RequireVersions bool
+}
+
+// withDefaults returns the options with the default of every unset name.
+func (o Options) withDefaults() Options {
+	if o.TagName == "" {
+		o.TagName = TagName
+	}
+	if o.PropertyStr == "" {
+		o.PropertyStr = TagPropertyStr
+	}
+	if o.PropertyRequired == "" {
+		o.PropertyRequired = TagPropertyRequired
+	}
+	if o.PropertyOptional == "" {
+		o.PropertyOptional = TagPropertyOptional
+	}
+	if o.PropertyDeprecated == "" {
+		o.PropertyDeprecated = TagPropertyDeprecated
+	}
+	return o
+}
+
+// defaultTags returns whether the options have the default tag name and property names, which VersionMarshaler and VersionUnmarshaler methods parse.
+func (o Options) defaultTags() bool {
+	o = o.withDefaults()
+	return o.TagName == TagName && o.PropertyStr == TagPropertyStr && o.PropertyRequired == TagPropertyRequired && o.PropertyOptional == TagPropertyOptional
+}
+
+// marshalHooks returns whether marshalling with the options calls VersionMarshaler methods.
+func (o Options) marshalHooks() bool {
+	return o.defaultTags() && !o.Strict && !o.RequireVersions
+}
+
+// unmarshalHooks returns whether unmarshalling with the options calls VersionUnmarshaler methods, which don't reject unknown fields.
+func (o Options) unmarshalHooks() bool {
+	return o.marshalHooks() && !o.DisallowUnknownFields
+}
+
+// checkType returns the InternalError of ValidateType if the options are Strict, else of CheckVersionTags if they RequireVersions.
+func (o Options) checkType(c Codec, typ reflect.Type) error {
+	if o.Strict {
+		if err := validateType(c, typ, o); err != nil {
+			return err
+		}
+	}
+	if o.RequireVersions {
+		if err := o.CheckVersionTags(typ); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetTagProperties returns the properties from the given tag, with the options' property names. An empty string may be passed, which will indicate no version (therefore, all versions), and that the field should not accept a string for a number or boolean.
+// Malformed properties are ignored. See ParseTagProperties.
+func (o Options) GetTagProperties(tag string) TagProperties {
+	props, _ := o.ParseTagProperties(tag)
+	return props
+}
+
+// ParseTagProperties returns the properties from the given tag, with the options' property names, and an InternalError if the tag is malformed: if it has an empty or unknown property, more than one version, an invalid version, or both the required and optional properties.
+// The properties are those GetTagProperties returns, even if the tag is malformed.
+func (o Options) ParseTagProperties(tag string) (TagProperties, error) {
+	o = o.withDefaults()
+	props := TagProperties{}
+	if tag == "" {
+		return props, nil
+	}
+	errMsg := ""
+	setErr := func(msg string) {
+		if errMsg == "" {
+			errMsg = "malformed " + o.TagName + " tag '" + tag + "': " + msg
+		}
+	}
+	versionProp := ""
+	for _, prop := range strings.Split(tag, ",") {
+		switch prop {
+		case o.PropertyStr:
+			props.Str = true
+		case o.PropertyRequired:
+			props.Required = true
+		case o.PropertyOptional:
+			props.Optional = true
+		case o.PropertyDeprecated:
+			props.Deprecated = true
+		case "":
+			setErr("empty property")
+		default:
+			f, err := strconv.ParseFloat(prop, 64)
+			if err != nil {
+				setErr("unknown property '" + prop + "'")
+				continue
+			}
+			if math.IsNaN(f) || math.IsInf(f, 0) || f < 0 {
+				setErr("invalid version '" + prop + "'")
+			}
+			if versionProp != "" {
+				setErr("multiple versions '" + versionProp + "' and '" + prop + "'")
+			}
+			versionProp = prop
+			props.Version = f
+		}
+	}
+	if props.Required && props.Optional {
+		setErr("both " + o.PropertyRequired + " and " + o.PropertyOptional)
+	}
+	if errMsg != "" {
+		return props, InternalError{errMsg}
+	}
+	return props, nil
+}
+
+// GetFieldProperties returns the properties of field of the struct type typ: the properties of its tag with the options' tag name, merged with any schema registered for it with RegisterSchema.
+func (o Options) GetFieldProperties(typ reflect.Type, field reflect.StructField) TagProperties {
+	props, _ := o.parseFieldProperties(typ, field)
+	return props
+}
+
+// parseFieldProperties is GetFieldProperties, returning the InternalError of ParseTagProperties if the field's tag is malformed.
+func (o Options) parseFieldProperties(typ reflect.Type, field reflect.StructField) (TagProperties, error) {
+	props, err := o.ParseTagProperties(field.Tag.Get(o.withDefaults().TagName))
+	schema, ok := getFieldSchema(typ, field.Name)
+	if !ok {
+		return props, err
+	}
+	if schema.Version != 0 {
+		props.Version = schema.Version
+	}
+	props.Str = props.Str || schema.Str
+	props.Required = props.Required || schema.Required
+	props.Optional = props.Optional || schema.Optional
+	props.Deprecated = props.Deprecated || schema.Deprecated
+	if schema.Rename != "" {
+		props.Rename = schema.Rename
+	}
+	return props, err
+}
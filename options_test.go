@@ -0,0 +1,239 @@
+package apiver
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// optionsObj has versions in both the default api tag, and another library's 'since' tag, with different property names.
+type optionsObj struct {
+	Name  string `json:"name"`
+	Port  int    `json:"port" api:"1.1" since:"2.0,lenient"`
+	Cache string `json:"cache" api:"1.2" since:"2.1,nullable"`
+}
+
+var sinceOptions = Options{TagName: "since", PropertyStr: "lenient", PropertyOptional: "nullable"}
+
+func TestOptionsTagName(t *testing.T) {
+	obj := optionsObj{}
+	if err := UnmarshalJSONWithOptions([]byte(`{"name": "a", "port": "80"}`), &obj, 2.0, sinceOptions); err != nil {
+		t.Fatalf("UnmarshalJSONWithOptions error expected: nil, actual: %+v", err)
+	}
+	if expected := (optionsObj{Name: "a", Port: 80}); obj != expected {
+		t.Errorf("UnmarshalJSONWithOptions expected: %+v, actual: %+v", expected, obj)
+	}
+
+	// the api tag is ignored, so port isn't a str field, and cache is optional.
+	if err := UnmarshalJSON([]byte(`{"name": "a", "port": "80", "cache": "c"}`), &obj, 2.0); err == nil {
+		t.Errorf("UnmarshalJSON default options str error expected: %+v, actual: %+v", "cannot unmarshal string", err)
+	}
+
+	if err := UnmarshalJSONWithOptions([]byte(`{"name": "a"}`), &obj, 2.0, sinceOptions); err == nil || !strings.Contains(err.Error(), "missing required field: port") {
+		t.Errorf("UnmarshalJSONWithOptions error expected: %+v, actual: %+v", "missing required field: port", err)
+	}
+
+	tests := map[float64]string{
+		1.5: `{"name":"a"}`,
+		2.0: `{"name":"a","port":80}`,
+		2.1: `{"name":"a","port":80,"cache":"c"}`,
+	}
+	obj = optionsObj{Name: "a", Port: 80, Cache: "c"}
+	for version, expected := range tests {
+		actual, err := MarshalJSONWithOptions(obj, version, sinceOptions)
+		if err != nil || string(actual) != expected {
+			t.Errorf("MarshalJSONWithOptions version %v expected: %v, actual: %s %+v", version, expected, actual, err)
+		}
+	}
+	if actual, err := MarshalJSON(obj, 1.5); err != nil || string(actual) != `{"name":"a","port":80,"cache":"c"}` {
+		t.Errorf("MarshalJSON default options expected: %v, actual: %s %+v", `{"name":"a","port":80,"cache":"c"}`, actual, err)
+	}
+}
+
+func TestMarshalJSONIndentWithOptions(t *testing.T) {
+	obj := optionsObj{Name: "a", Port: 80, Cache: "c"}
+	actual, err := MarshalJSONIndentWithOptions(obj, "", "\t", 2.0, sinceOptions)
+	if err != nil {
+		t.Fatalf("MarshalJSONIndentWithOptions error expected: nil, actual: %+v", err)
+	}
+	compact := &bytes.Buffer{}
+	if err := json.Compact(compact, actual); err != nil {
+		t.Fatalf("MarshalJSONIndentWithOptions compact error expected: nil, actual: %+v", err)
+	}
+	if expected := `{"name":"a","port":80}`; compact.String() != expected || !strings.Contains(string(actual), "\n") {
+		t.Errorf("MarshalJSONIndentWithOptions expected: %v indented, actual: %s", expected, actual)
+	}
+}
+
+func TestBuildUnmarshalTypeWithOptions(t *testing.T) {
+	typ := BuildUnmarshalTypeWithOptions(reflect.TypeOf(optionsObj{}), 2.0, false, sinceOptions)
+	if typ.NumField() != 2 {
+		t.Fatalf("BuildUnmarshalTypeWithOptions fields expected: %+v, actual: %+v", 2, typ.NumField())
+	}
+	if typ.Field(1).Type != reflect.TypeOf((*int)(nil)) {
+		t.Errorf("BuildUnmarshalTypeWithOptions port type expected: %+v, actual: %+v", "*int", typ.Field(1).Type)
+	}
+	typ = BuildUnmarshalTypeWithOptions(reflect.TypeOf(optionsObj{}), 2.1, false, sinceOptions)
+	if typ.Field(2).Type != reflect.TypeOf("") {
+		t.Errorf("BuildUnmarshalTypeWithOptions optional cache type expected: %+v, actual: %+v", "string", typ.Field(2).Type)
+	}
+}
+
+func TestOptionsDisallowUnknownFields(t *testing.T) {
+	opts := Options{DisallowUnknownFields: true}
+	obj := optionsObj{}
+	if err := UnmarshalJSONWithOptions([]byte(`{"name": "a", "port": 80}`), &obj, 1.1, opts); err != nil {
+		t.Errorf("UnmarshalJSONWithOptions known fields error expected: nil, actual: %+v", err)
+	}
+	for _, input := range []string{`{"name": "a", "port": 80, "cache": "c"}`, `{"name": "a", "port": 80, "unknown": 1}`} {
+		if err := UnmarshalJSONWithOptions([]byte(input), &obj, 1.1, opts); err == nil || !strings.Contains(err.Error(), "unknown field") {
+			t.Errorf("UnmarshalJSONWithOptions %v error expected: %+v, actual: %+v", input, "unknown field", err)
+		}
+	}
+	if err := UnmarshalJSONWithOptions([]byte(`{"name": "a", "port": 80} x`), &obj, 1.1, opts); err == nil {
+		t.Errorf("UnmarshalJSONWithOptions trailing data error expected: %+v, actual: %+v", "invalid character", err)
+	}
+}
+
+func TestNewJSONWithOptions(t *testing.T) {
+	json := NewJSONWithOptions(2.0, Options{TagName: "since", PropertyStr: "lenient", DisallowUnknownFields: true})
+	obj := optionsObj{}
+	if err := json.Unmarshal([]byte(`{"name": "a", "port": "80"}`), &obj); err != nil {
+		t.Errorf("Unmarshal error expected: nil, actual: %+v", err)
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(`{"name": "a", "port": 80, "cache": "c"}`))
+	if err := decoder.Decode(&obj); err == nil || !strings.Contains(err.Error(), "unknown field") {
+		t.Errorf("Decode error expected: %+v, actual: %+v", "unknown field", err)
+	}
+
+	buf := &bytes.Buffer{}
+	obj = optionsObj{Name: "a", Port: 80, Cache: "c"}
+	if err := json.NewEncoder(buf).Encode(obj); err != nil {
+		t.Fatalf("Encode error expected: nil, actual: %+v", err)
+	}
+	if expected := "{\"name\":\"a\",\"port\":80}\n"; buf.String() != expected {
+		t.Errorf("Encode expected: %v, actual: %v", expected, buf.String())
+	}
+}
+
+func TestOptionsVersionHooks(t *testing.T) {
+	actual, err := MarshalJSONWithOptions(handVersioned{Foo: 42}, 1.1, Options{TagName: "since"})
+	if err != nil {
+		t.Fatalf("MarshalJSONWithOptions error expected: nil, actual: %+v", err)
+	}
+	if strings.Contains(string(actual), "generated") {
+		t.Errorf("MarshalJSONWithOptions custom tag expected reflection, actual: %s", actual)
+	}
+
+	actual, err = MarshalJSONWithOptions(handVersioned{Foo: 42}, 1.1, Options{TagName: TagName})
+	if err != nil || !strings.Contains(string(actual), "generated") {
+		t.Errorf("MarshalJSONWithOptions default tag expected VersionMarshaler, actual: %s %+v", actual, err)
+	}
+}
+
+func TestOptionsYAMLAndXML(t *testing.T) {
+	opts := sinceOptions
+	opts.DisallowUnknownFields = true
+	obj := optionsObj{}
+	if err := UnmarshalYAMLWithOptions([]byte("name: a\nport: \"80\"\n"), &obj, 2.0, opts); err != nil {
+		t.Errorf("UnmarshalYAMLWithOptions error expected: nil, actual: %+v", err)
+	} else if expected := (optionsObj{Name: "a", Port: 80}); obj != expected {
+		t.Errorf("UnmarshalYAMLWithOptions expected: %+v, actual: %+v", expected, obj)
+	}
+	if err := UnmarshalYAMLWithOptions([]byte("name: a\nport: 80\ncache: c\n"), &obj, 2.0, opts); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("UnmarshalYAMLWithOptions newer field error expected: %+v, actual: %+v", "field cache not found", err)
+	}
+	if err := NewYAMLWithOptions(2.0, opts).NewDecoder(strings.NewReader("name: a\nport: 80\nunknown: 1\n")).Decode(&obj); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("YAMLDecoder.Decode unknown field error expected: %+v, actual: %+v", "field unknown not found", err)
+	}
+	if actual, err := MarshalYAMLWithOptions(optionsObj{Name: "a", Port: 80, Cache: "c"}, 2.0, sinceOptions); err != nil || string(actual) != "name: a\nport: 80\n" {
+		t.Errorf("MarshalYAMLWithOptions expected: %v, actual: %s %+v", "name: a\nport: 80\n", actual, err)
+	}
+
+	if actual, err := MarshalXMLWithOptions(optionsObj{Name: "a", Port: 80, Cache: "c"}, 2.0, sinceOptions); err != nil || string(actual) != `<optionsObj><Name>a</Name><Port>80</Port></optionsObj>` {
+		t.Errorf("MarshalXMLWithOptions expected: %v, actual: %s %+v", `<optionsObj><Name>a</Name><Port>80</Port></optionsObj>`, actual, err)
+	}
+	if err := UnmarshalXMLWithOptions([]byte(`<optionsObj><Name>a</Name></optionsObj>`), &obj, 2.0, sinceOptions); err == nil || !strings.Contains(err.Error(), "missing required field: Port") {
+		t.Errorf("UnmarshalXMLWithOptions error expected: %+v, actual: %+v", "missing required field: Port", err)
+	}
+	if err := UnmarshalXMLWithOptions([]byte(`<optionsObj><Name>a</Name><Port>80</Port></optionsObj>`), &obj, 2.0, opts); err == nil || !strings.Contains(err.Error(), "can't disallow unknown fields") {
+		t.Errorf("UnmarshalXMLWithOptions DisallowUnknownFields error expected: %+v, actual: %+v", "can't disallow unknown fields", err)
+	}
+}
+
+func TestOptionsCSVAndQuery(t *testing.T) {
+	opts := sinceOptions
+	opts.DisallowUnknownFields = true
+	objs := []optionsObj{}
+	if err := UnmarshalCSVWithOptions([]byte("name,port\na,80\n"), &objs, 2.0, opts); err != nil {
+		t.Errorf("UnmarshalCSVWithOptions error expected: nil, actual: %+v", err)
+	} else if len(objs) != 1 || objs[0] != (optionsObj{Name: "a", Port: 80}) {
+		t.Errorf("UnmarshalCSVWithOptions expected: %+v, actual: %+v", []optionsObj{{Name: "a", Port: 80}}, objs)
+	}
+	if err := UnmarshalCSVWithOptions([]byte("name,port,cache\na,80,c\n"), &objs, 2.0, opts); err == nil || err.Error() != "csv column 'cache' does not exist in version 2" {
+		t.Errorf("UnmarshalCSVWithOptions newer column error expected: %+v, actual: %+v", "csv column 'cache' does not exist in version 2", err)
+	}
+	if err := UnmarshalCSVWithOptions([]byte("name,port,secret\na,80,s\n"), &objs, 2.0, opts); err == nil || err.Error() != "unknown csv column" {
+		t.Errorf("UnmarshalCSVWithOptions unknown column error expected: %+v, actual: %+v", "unknown csv column", err)
+	}
+	if err := UnmarshalCSVWithOptions([]byte("name,port,secret\na,80,s\n"), &objs, 2.0, sinceOptions); err != nil {
+		t.Errorf("UnmarshalCSVWithOptions unknown column allowed error expected: nil, actual: %+v", err)
+	}
+	if actual, err := MarshalCSVWithOptions([]optionsObj{{Name: "a", Port: 80, Cache: "c"}}, 2.0, sinceOptions); err != nil || string(actual) != "name,port\na,80\n" {
+		t.Errorf("MarshalCSVWithOptions expected: %v, actual: %s %+v", "name,port\na,80\n", actual, err)
+	}
+
+	obj := optionsObj{}
+	if err := UnmarshalQueryWithOptions(url.Values{"name": {"a"}, "port": {"80"}}, &obj, 2.0, opts); err != nil {
+		t.Errorf("UnmarshalQueryWithOptions error expected: nil, actual: %+v", err)
+	} else if expected := (optionsObj{Name: "a", Port: 80}); obj != expected {
+		t.Errorf("UnmarshalQueryWithOptions expected: %+v, actual: %+v", expected, obj)
+	}
+	if err := UnmarshalQueryWithOptions(url.Values{"name": {"a"}, "port": {"80"}, "cache": {"c"}}, &obj, 2.0, opts); err == nil || err.Error() != "query parameter 'cache' does not exist in version 2" {
+		t.Errorf("UnmarshalQueryWithOptions error expected: %+v, actual: %+v", "query parameter 'cache' does not exist in version 2", err)
+	}
+}
+
+func TestOptionsDirectAndTyped(t *testing.T) {
+	opts := sinceOptions
+	opts.DisallowUnknownFields = true
+	obj := optionsObj{}
+	if err := UnmarshalJSONDirectWithOptions([]byte(`{"name": "a", "port": "80"}`), &obj, 2.0, opts); err != nil {
+		t.Errorf("UnmarshalJSONDirectWithOptions error expected: nil, actual: %+v", err)
+	} else if expected := (optionsObj{Name: "a", Port: 80}); obj != expected {
+		t.Errorf("UnmarshalJSONDirectWithOptions expected: %+v, actual: %+v", expected, obj)
+	}
+	for _, input := range []string{`{"name": "a", "port": 80, "cache": "c"}`, `{"name": "a", "port": 80, "unknown": 1}`} {
+		expected := UnmarshalJSONWithOptions([]byte(input), &optionsObj{}, 2.0, opts)
+		actual := UnmarshalJSONDirectWithOptions([]byte(input), &optionsObj{}, 2.0, opts)
+		if expected == nil || actual == nil || actual.Error() != expected.Error() {
+			t.Errorf("UnmarshalJSONDirectWithOptions %v error expected: %+v, actual: %+v", input, expected, actual)
+		}
+	}
+	if err := UnmarshalJSONDirectWithOptions([]byte(`{"name": "a", "port": 80, "unknown": 1}`), &obj, 2.0, sinceOptions); err != nil {
+		t.Errorf("UnmarshalJSONDirectWithOptions unknown field allowed error expected: nil, actual: %+v", err)
+	}
+
+	expected := `{"name":"a","port":80}`
+	if actual, err := MarshalJSONDirectWithOptions(optionsObj{Name: "a", Port: 80, Cache: "c"}, 2.0, sinceOptions); err != nil || string(actual) != expected {
+		t.Errorf("MarshalJSONDirectWithOptions expected: %v, actual: %s %+v", expected, actual, err)
+	}
+	if actual, err := MarshalJSONDirect(optionsObj{Name: "a", Port: 80, Cache: "c"}, 2.0); err != nil || string(actual) != `{"name":"a","port":80,"cache":"c"}` {
+		t.Errorf("MarshalJSONDirect default options expected: %v, actual: %s %+v", `{"name":"a","port":80,"cache":"c"}`, actual, err)
+	}
+
+	codec := NewTypedCodecWithOptions[optionsObj](2.0, opts)
+	if obj, err := codec.Unmarshal([]byte(`{"name": "a", "port": "80"}`)); err != nil || obj != (optionsObj{Name: "a", Port: 80}) {
+		t.Errorf("TypedCodec.Unmarshal expected: %+v, actual: %+v %+v", optionsObj{Name: "a", Port: 80}, obj, err)
+	}
+	if _, err := codec.Unmarshal([]byte(`{"name": "a", "port": 80, "unknown": 1}`)); err == nil || !strings.Contains(err.Error(), "unknown field") {
+		t.Errorf("TypedCodec.Unmarshal error expected: %+v, actual: %+v", "unknown field", err)
+	}
+	if actual, err := codec.Marshal(optionsObj{Name: "a", Port: 80, Cache: "c"}); err != nil || string(actual) != expected {
+		t.Errorf("TypedCodec.Marshal expected: %v, actual: %s %+v", expected, actual, err)
+	}
+}
@@ -0,0 +1,446 @@
+package apiver
+
+import (
+	"bufio"
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Proto generates proto3 .proto files from versioned structs, so gRPC consumers can use the same types.
+// Field numbers are stable across versions: every field of a message is numbered in order of its version, then its order in the struct, and each version's file has only the fields which exist at that version, with the same numbers. Fields are optional where UnmarshalJSON treats them as optional, which is every field except versioned non-pointer fields and fields with the required property. Fields with the deprecated property are deprecated. Embedded structs are message fields named after their type, as in the types BuildUnmarshalType builds.
+// Numbering is only stable if fields are only added with versions newer than every existing field. To catch mistakes, set Previous to the last exported file; File then returns an error if any field would change its number, or reuse the name of a removed field. Fields which were removed since the previous file are reserved, and their numbers are skipped, so they're never reused.
+// Always set Previous after the first release. Without it, nothing protects the numbering: a field added with an older version, or without a version, silently renumbers every field after it.
+//
+// Example:
+//
+//	p := apiver.NewProto("servers.v1")
+//	p.Previous, err = ioutil.ReadFile("servers.proto") // always set after the first release
+//	p.AddMessage(reflect.TypeOf(Server{}))
+//	bts, err := p.File(1.3)
+type Proto struct {
+	// Package is the proto package of the file, such as "servers.v1".
+	Package string
+	// GoPackage is the go_package option of the file, if any.
+	GoPackage string
+	// Previous is a previously exported file, if any, whose field numbers must not change. It should always be set after the first release. Only the messages, fields, and reserved statements Proto itself writes are read.
+	Previous []byte
+	// Options are the tag names used to build messages.
+	Options Options
+
+	types []reflect.Type
+}
+
+// NewProto returns a Proto generator for the given proto package.
+func NewProto(pkg string) *Proto {
+	return &Proto{Package: pkg}
+}
+
+// AddMessage adds the message of the struct type typ to the file, and the messages of the structs its fields use. Messages are named by their type name.
+func (p *Proto) AddMessage(typ reflect.Type) {
+	p.types = append(p.types, typ)
+}
+
+// File returns the .proto file of the messages at version.
+// Returns an InternalError if a type can't be represented in proto3, such as a channel or a slice of slices, or if a field number differs from Previous.
+func (p *Proto) File(version float64) ([]byte, error) {
+	previous, err := parseProtoFile(p.Previous)
+	if err != nil {
+		return nil, err
+	}
+	b := &protoBuilder{version: version, opts: p.Options, previous: previous, names: map[reflect.Type]string{}, used: map[string]bool{}, imports: map[string]bool{}}
+	for _, typ := range p.types {
+		for typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		if typ.Kind() != reflect.Struct || typ.Name() == "" {
+			return nil, InternalError{"proto message type '" + typ.String() + "' must be a named struct"}
+		}
+		b.messageName(typ)
+	}
+	for i := 0; i < len(b.queue); i++ {
+		if err := b.buildMessage(b.queue[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	w := &bytes.Buffer{}
+	w.WriteString("// Code generated by apiver at version " + formatVersion(version) + "; DO NOT EDIT.\n\n")
+	w.WriteString("syntax = \"proto3\";\n")
+	if p.Package != "" {
+		w.WriteString("\npackage " + p.Package + ";\n")
+	}
+	if len(b.imports) > 0 {
+		w.WriteString("\n")
+		imports := []string{}
+		for imp := range b.imports {
+			imports = append(imports, imp)
+		}
+		sort.Strings(imports)
+		for _, imp := range imports {
+			w.WriteString("import \"" + imp + "\";\n")
+		}
+	}
+	if p.GoPackage != "" {
+		w.WriteString("\noption go_package = " + strconv.Quote(p.GoPackage) + ";\n")
+	}
+	for _, msg := range b.messages {
+		w.WriteString("\n")
+		w.Write(msg)
+	}
+	return w.Bytes(), nil
+}
+
+// protoField is a field of a message, at any version.
+type protoField struct {
+	name     string
+	jsonName string
+	typ      reflect.Type
+	props    TagProperties
+	number   int
+}
+
+// protoMessage is a message of a previous file: the number of each field by name, and its reserved numbers and names.
+type protoMessage struct {
+	numbers       map[string]int
+	reservedNums  map[int]bool
+	reservedNames map[string]bool
+}
+
+type protoBuilder struct {
+	version  float64
+	opts     Options
+	previous map[string]*protoMessage
+	// names is the message name of each struct type, and used is the set of names, so types of the same name in different packages get different names.
+	names    map[reflect.Type]string
+	used     map[string]bool
+	queue    []reflect.Type
+	messages [][]byte
+	imports  map[string]bool
+}
+
+// messageName returns the message name of the struct typ, queueing it to be built if it's new.
+func (b *protoBuilder) messageName(typ reflect.Type) string {
+	if name, ok := b.names[typ]; ok {
+		return name
+	}
+	name := typ.Name()
+	for i := 2; b.used[name]; i++ {
+		name = typ.Name() + strconv.Itoa(i) // a type of the same name in another package
+	}
+	b.names[typ] = name
+	b.used[name] = true
+	b.queue = append(b.queue, typ)
+	return name
+}
+
+// buildMessage appends the message of typ at the builder's version to the builder's messages.
+func (b *protoBuilder) buildMessage(typ reflect.Type) error {
+	name := b.names[typ]
+	fields := []protoField{}
+	if err := b.collectFields(typ, &fields); err != nil {
+		return err
+	}
+	sort.SliceStable(fields, func(i, j int) bool { return fields[i].props.Version < fields[j].props.Version })
+
+	previous := b.previous[name]
+	if previous == nil {
+		previous = &protoMessage{numbers: map[string]int{}, reservedNums: map[int]bool{}, reservedNames: map[string]bool{}}
+	}
+	reservedNums := map[int]bool{}
+	reservedNames := map[string]bool{}
+	for num := range previous.reservedNums {
+		reservedNums[num] = true
+	}
+	for fieldName := range previous.reservedNames {
+		reservedNames[fieldName] = true
+	}
+	exists := map[string]bool{}
+	for _, field := range fields {
+		exists[field.name] = true
+	}
+	for fieldName, num := range previous.numbers {
+		if !exists[fieldName] {
+			reservedNums[num] = true // removed since the previous file
+			reservedNames[fieldName] = true
+		}
+	}
+
+	num := 1
+	for i := range fields {
+		for reservedNums[num] || (num >= 19000 && num <= 19999) { // 19000-19999 are reserved by protobuf
+			num++
+		}
+		fields[i].number = num
+		num++
+	}
+
+	for _, field := range fields {
+		if reservedNames[field.name] {
+			return InternalError{"proto message '" + name + "' field '" + field.name + "' reuses a reserved name"}
+		}
+		if prevNum, ok := previous.numbers[field.name]; ok && prevNum != field.number {
+			return InternalError{"proto message '" + name + "' field '" + field.name + "' would change number from " + strconv.Itoa(prevNum) + " to " + strconv.Itoa(field.number) + "; fields may only be added with versions newer than every existing field"}
+		}
+	}
+
+	w := &bytes.Buffer{}
+	w.WriteString("message " + name + " {\n")
+	if len(reservedNums) > 0 {
+		nums := []int{}
+		for num := range reservedNums {
+			nums = append(nums, num)
+		}
+		sort.Ints(nums)
+		strs := []string{}
+		for _, num := range nums {
+			strs = append(strs, strconv.Itoa(num))
+		}
+		w.WriteString("  reserved " + strings.Join(strs, ", ") + ";\n")
+	}
+	if len(reservedNames) > 0 {
+		names := []string{}
+		for fieldName := range reservedNames {
+			names = append(names, strconv.Quote(fieldName))
+		}
+		sort.Strings(names)
+		w.WriteString("  reserved " + strings.Join(names, ", ") + ";\n")
+	}
+	for _, field := range fields {
+		if field.props.Version > b.version {
+			continue
+		}
+		fieldType, repeated, err := b.fieldType(field.typ)
+		if err != nil {
+			return InternalError{"proto message '" + name + "' field '" + field.name + "': " + err.Error()}
+		}
+		required := field.typ.Kind() != reflect.Ptr && (field.props.Required || (field.props.Version != 0 && !field.props.Optional))
+		w.WriteString("  ")
+		if !repeated && !required {
+			w.WriteString("optional ")
+		}
+		w.WriteString(fieldType + " " + field.name + " = " + strconv.Itoa(field.number))
+		opts := []string{}
+		if field.jsonName != protoJSONName(field.name) {
+			opts = append(opts, "json_name = "+strconv.Quote(field.jsonName))
+		}
+		if field.props.Deprecated {
+			opts = append(opts, "deprecated = true")
+		}
+		if len(opts) > 0 {
+			w.WriteString(" [" + strings.Join(opts, ", ") + "]")
+		}
+		w.WriteString(";\n")
+	}
+	w.WriteString("}\n")
+	b.messages = append(b.messages, w.Bytes())
+	return nil
+}
+
+// collectFields appends the fields of typ at every version to fields, in struct order. Embedded structs aren't flattened: like BuildUnmarshalType, which builds them as fields named after their type, they're message fields, so their numbers are stable whether or not a version encodes the type verbatim.
+func (b *protoBuilder) collectFields(typ reflect.Type, fields *[]protoField) error {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		props := b.opts.GetFieldProperties(typ, field)
+		field.Tag = schemaFieldTag(JSONCodec, typ, field)
+		if isExported := field.PkgPath == ""; !isExported {
+			continue
+		}
+
+		key, _, ok := jsonFieldKey(field)
+		if !ok {
+			continue
+		}
+		jsonName := ""
+		if err := json.Unmarshal(key[:len(key)-1], &jsonName); err != nil {
+			return InternalError{"type '" + typ.String() + "' field '" + field.Name + "' json key: " + err.Error()} // should never happen
+		}
+		*fields = append(*fields, protoField{name: protoFieldName(jsonName), jsonName: jsonName, typ: field.Type, props: props})
+	}
+	return nil
+}
+
+// protoInvalidChars matches characters which can't be in proto field names.
+var protoInvalidChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// protoFieldName returns the proto field name of the json name, which is the json name with any characters which can't be in proto names replaced by underscores.
+func protoFieldName(jsonName string) string {
+	name := protoInvalidChars.ReplaceAllString(jsonName, "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	return name
+}
+
+// protoJSONName returns the default JSON name protobuf gives the field name, which is lowerCamelCase.
+func protoJSONName(name string) string {
+	jsonName := []byte{}
+	upper := false
+	for i := 0; i < len(name); i++ {
+		switch {
+		case name[i] == '_':
+			upper = true
+		case upper && name[i] >= 'a' && name[i] <= 'z':
+			jsonName = append(jsonName, name[i]-'a'+'A')
+			upper = false
+		default:
+			jsonName = append(jsonName, name[i])
+			upper = false
+		}
+	}
+	return string(jsonName)
+}
+
+// fieldType returns the proto type of a field of type typ, and whether it's repeated or a map, which can't be optional.
+func (b *protoBuilder) fieldType(typ reflect.Type) (string, bool, error) {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	switch typ.Kind() {
+	case reflect.Slice, reflect.Array:
+		if typ.Elem().Kind() == reflect.Uint8 && !hasMarshalMethod(typ.Elem()) && typ.Kind() == reflect.Slice {
+			return "bytes", false, nil
+		}
+		elem, err := b.elemType(typ.Elem())
+		if err != nil {
+			return "", false, err
+		}
+		return "repeated " + elem, true, nil
+	case reflect.Map:
+		key := typ.Key()
+		if !isBasicKind(key.Kind()) || key.Kind() == reflect.Float32 || key.Kind() == reflect.Float64 || hasMarshalMethod(key) {
+			return "", false, InternalError{"map key type '" + key.String() + "' can't be a proto map key"}
+		}
+		keyType, err := b.elemType(key)
+		if err != nil {
+			return "", false, err
+		}
+		elem, err := b.elemType(typ.Elem())
+		if err != nil {
+			return "", false, err
+		}
+		return "map<" + keyType + ", " + elem + ">", true, nil
+	}
+	elem, err := b.elemType(typ)
+	return elem, false, err
+}
+
+// elemType returns the proto type of typ, which can't be repeated or a map.
+func (b *protoBuilder) elemType(typ reflect.Type) (string, error) {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == reflect.TypeOf(time.Time{}) {
+		b.imports["google/protobuf/timestamp.proto"] = true
+		return "google.protobuf.Timestamp", nil
+	}
+	ptr := reflect.PtrTo(typ)
+	if ptr.Implements(reflect.TypeOf((*json.Marshaler)(nil)).Elem()) {
+		b.imports["google/protobuf/struct.proto"] = true
+		return "google.protobuf.Value", nil
+	}
+	if ptr.Implements(reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()) {
+		return "string", nil
+	}
+
+	switch typ.Kind() {
+	case reflect.Bool:
+		return "bool", nil
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return "int32", nil
+	case reflect.Int, reflect.Int64:
+		return "int64", nil
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return "uint32", nil
+	case reflect.Uint, reflect.Uint64, reflect.Uintptr:
+		return "uint64", nil
+	case reflect.Float32:
+		return "float", nil
+	case reflect.Float64:
+		return "double", nil
+	case reflect.String:
+		return "string", nil
+	case reflect.Interface:
+		b.imports["google/protobuf/struct.proto"] = true
+		return "google.protobuf.Value", nil
+	case reflect.Struct:
+		if typ.Name() == "" {
+			return "", InternalError{"anonymous struct type '" + typ.String() + "' can't be a proto message"}
+		}
+		return b.messageName(typ), nil
+	case reflect.Slice:
+		if typ.Elem().Kind() == reflect.Uint8 && !hasMarshalMethod(typ.Elem()) {
+			return "bytes", nil
+		}
+	}
+	return "", InternalError{"type '" + typ.String() + "' can't be represented in proto3"}
+}
+
+var (
+	protoMessageRegexp  = regexp.MustCompile(`^message\s+(\w+)\s*\{`)
+	protoFieldRegexp    = regexp.MustCompile(`^(?:optional\s+|repeated\s+)?(?:map\s*<[^>]*>|[\w.]+)\s+(\w+)\s*=\s*(\d+)`)
+	protoReservedRegexp = regexp.MustCompile(`^reserved\s+(.*);`)
+)
+
+// parseProtoFile returns the messages of a file written by Proto, by name. Nested messages, enums, and oneofs aren't supported, because Proto doesn't write them.
+func parseProtoFile(file []byte) (map[string]*protoMessage, error) {
+	messages := map[string]*protoMessage{}
+	var msg *protoMessage
+	scanner := bufio.NewScanner(bytes.NewReader(file))
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		if msg == nil {
+			if match := protoMessageRegexp.FindStringSubmatch(line); match != nil {
+				msg = &protoMessage{numbers: map[string]int{}, reservedNums: map[int]bool{}, reservedNames: map[string]bool{}}
+				messages[match[1]] = msg
+			}
+			continue
+		}
+		if line == "}" {
+			msg = nil
+			continue
+		}
+		if match := protoReservedRegexp.FindStringSubmatch(line); match != nil {
+			for _, reserved := range strings.Split(match[1], ",") {
+				reserved = strings.TrimSpace(reserved)
+				if name, err := strconv.Unquote(reserved); err == nil {
+					msg.reservedNames[name] = true
+					continue
+				}
+				bounds := strings.SplitN(reserved, " to ", 2)
+				lo, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+				hi := lo
+				if err == nil && len(bounds) == 2 {
+					hi, err = strconv.Atoi(strings.TrimSpace(bounds[1]))
+				}
+				if err != nil || hi < lo {
+					return nil, InternalError{"previous proto file line " + strconv.Itoa(lineNum) + ": malformed reserved '" + reserved + "'"}
+				}
+				for num := lo; num <= hi; num++ {
+					msg.reservedNums[num] = true
+				}
+			}
+			continue
+		}
+		if match := protoFieldRegexp.FindStringSubmatch(line); match != nil {
+			num, err := strconv.Atoi(match[2])
+			if err != nil {
+				return nil, InternalError{"previous proto file line " + strconv.Itoa(lineNum) + ": malformed field number '" + match[2] + "'"}
+			}
+			msg.numbers[match[1]] = num
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, InternalError{"reading previous proto file: " + err.Error()}
+	}
+	return messages, nil
+}
@@ -0,0 +1,199 @@
+package apiver
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type protoServer struct {
+	ProtoBase
+	Name    string           `json:"name" api:"1.1"`
+	Cache   *protoCache      `json:"cacheGroup" api:"1.3"`
+	Port    int              `json:"port" api:"1.1,str,deprecated"`
+	Weight  *float64         `json:"weight" api:"1.2"`
+	Retries uint8            `json:"retries" api:"1.2,optional"`
+	Tags    []string         `json:"tags" api:"1.2"`
+	Labels  map[string]int32 `json:"labels"`
+	Data    []byte           `json:"data"`
+	Host    string           `json:"host-name" api:"required"`
+	Ignored string           `json:"-"`
+	Updated time.Time        `json:"updated" api:"1.3"`
+	private string
+}
+
+// ProtoBase is embedded, so it's a message field named after its type, not flattened.
+type ProtoBase struct {
+	ID int64 `json:"id"`
+}
+
+type protoCache struct {
+	Name string `json:"name" api:"1.3"`
+}
+
+func protoFile(t *testing.T, typ reflect.Type, version float64, previous string) string {
+	p := NewProto("servers.v1")
+	p.GoPackage = "example.com/servers"
+	p.Previous = []byte(previous)
+	p.AddMessage(typ)
+	bts, err := p.File(version)
+	if err != nil {
+		t.Fatalf("File %v error expected: nil, actual: %+v", version, err)
+	}
+	return string(bts)
+}
+
+func TestProtoFile(t *testing.T) {
+	expected := `// Code generated by apiver at version 1.3; DO NOT EDIT.
+
+syntax = "proto3";
+
+package servers.v1;
+
+import "google/protobuf/timestamp.proto";
+
+option go_package = "example.com/servers";
+
+message protoServer {
+  optional ProtoBase ProtoBase = 1;
+  map<string, int32> labels = 2;
+  optional bytes data = 3;
+  string host_name = 4 [json_name = "host-name"];
+  string name = 5;
+  int64 port = 6 [deprecated = true];
+  optional double weight = 7;
+  optional uint32 retries = 8;
+  repeated string tags = 9;
+  optional protoCache cacheGroup = 10;
+  google.protobuf.Timestamp updated = 11;
+}
+
+message ProtoBase {
+  optional int64 id = 1;
+}
+
+message protoCache {
+  string name = 1;
+}
+`
+	if actual := protoFile(t, reflect.TypeOf(protoServer{}), 1.3, ""); actual != expected {
+		t.Errorf("File expected: %v, actual: %v", expected, actual)
+	}
+}
+
+func TestProtoFileVersions(t *testing.T) {
+	actual := protoFile(t, reflect.TypeOf(protoServer{}), 1.2, "")
+	for _, expected := range []string{"optional ProtoBase ProtoBase = 1;", "string name = 5;", "repeated string tags = 9;", "message ProtoBase {\n  optional int64 id = 1;\n}"} {
+		if !strings.Contains(actual, expected) {
+			t.Errorf("File 1.2 expected: %v, actual: %v", expected, actual)
+		}
+	}
+	for _, unexpected := range []string{"cacheGroup", "updated", "timestamp.proto", "message protoCache"} {
+		if strings.Contains(actual, unexpected) {
+			t.Errorf("File 1.2 expected: no %v, actual: %v", unexpected, actual)
+		}
+	}
+}
+
+type protoRemoved struct {
+	A string `json:"a" api:"1.1"`
+	C string `json:"c" api:"1.3"`
+}
+
+type protoInserted struct {
+	A string `json:"a" api:"1.1"`
+	B string `json:"b" api:"1.2"`
+	D string `json:"d" api:"1.1"`
+}
+
+type protoReused struct {
+	A string `json:"a" api:"1.1"`
+	B int    `json:"b" api:"1.4"`
+}
+
+func TestProtoFilePrevious(t *testing.T) {
+	previous := `message protoRemoved {
+  string a = 1;
+  string b = 2; // removed
+  string c = 3;
+}
+`
+	expected := `message protoRemoved {
+  reserved 2;
+  reserved "b";
+  string a = 1;
+  string c = 3;
+}
+`
+	actual := protoFile(t, reflect.TypeOf(protoRemoved{}), 1.3, previous)
+	if !strings.Contains(actual, expected) {
+		t.Errorf("File removed expected: %v, actual: %v", expected, actual)
+	}
+	if again := protoFile(t, reflect.TypeOf(protoRemoved{}), 1.3, actual); again != actual {
+		t.Errorf("File with itself as previous expected: %v, actual: %v", actual, again)
+	}
+
+	tests := map[reflect.Type]struct {
+		previous string
+		err      string
+	}{
+		reflect.TypeOf(protoInserted{}): {"message protoInserted {\n  string a = 1;\n  string b = 2;\n}\n", "field 'b' would change number from 2 to 3"},
+		reflect.TypeOf(protoReused{}):   {"message protoReused {\n  string a = 1;\n  reserved \"b\";\n}\n", "field 'b' reuses a reserved name"},
+		reflect.TypeOf(protoCache{}):    {"message protoCache {\n  reserved 1 to;\n}\n", "malformed reserved '1 to'"},
+	}
+	for typ, test := range tests {
+		p := NewProto("servers.v1")
+		p.Previous = []byte(test.previous)
+		p.AddMessage(typ)
+		if _, err := p.File(1.4); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("File %v error expected: %v, actual: %+v", typ, test.err, err)
+		}
+	}
+}
+
+func TestProtoFileErrors(t *testing.T) {
+	tests := map[reflect.Type]string{
+		reflect.TypeOf(0): "must be a named struct",
+		reflect.TypeOf(struct {
+			A int `json:"a"`
+		}{}): "must be a named struct",
+		reflect.TypeOf(protoUnsupported{}): "type 'chan int' can't be represented in proto3",
+		reflect.TypeOf(protoNested{}):      "type '[]string' can't be represented in proto3",
+	}
+	for typ, expected := range tests {
+		p := NewProto("")
+		p.AddMessage(typ)
+		if _, err := p.File(1.0); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("File %v error expected: %v, actual: %+v", typ, expected, err)
+		}
+	}
+}
+
+type protoUnsupported struct {
+	C chan int `json:"c"`
+}
+
+type protoNested struct {
+	S [][]string `json:"s"`
+}
+
+// protoEmbeddedLater embeds a struct added in a later version, which is numbered like any other field of that version.
+type protoEmbeddedLater struct {
+	*ProtoBase `api:"1.2"`
+	Name       string `json:"name" api:"1.1"`
+}
+
+func TestProtoFileEmbedded(t *testing.T) {
+	older := protoFile(t, reflect.TypeOf(protoEmbeddedLater{}), 1.1, "")
+	if expected := "message protoEmbeddedLater {\n  string name = 1;\n}\n"; !strings.Contains(older, expected) || strings.Contains(older, "message ProtoBase") {
+		t.Errorf("File 1.1 expected: %v, actual: %v", expected, older)
+	}
+	newer := protoFile(t, reflect.TypeOf(protoEmbeddedLater{}), 1.2, older)
+	if expected := "message protoEmbeddedLater {\n  string name = 1;\n  optional ProtoBase ProtoBase = 2;\n}\n"; !strings.Contains(newer, expected) {
+		t.Errorf("File 1.2 expected: %v, actual: %v", expected, newer)
+	}
+	if expected := "message ProtoBase {\n  optional int64 id = 1;\n}\n"; !strings.Contains(newer, expected) {
+		t.Errorf("File 1.2 expected: %v, actual: %v", expected, newer)
+	}
+}
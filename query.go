@@ -0,0 +1,198 @@
+package apiver
+
+import (
+	"encoding"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+)
+
+// UnmarshalQuery sets the given object from URL query parameters, such as the filters of a GET request.
+// Parameters are named by the field's query tag, else its json tag, else the field name. Numbers and booleans are always parsed from strings, as if they had the 'str' tag property, and fields implementing encoding.TextUnmarshaler are parsed with it. Slice fields take every value of their parameter.
+// Like UnmarshalJSON, parameters of fields newer than version are ignored, and missing required fields return a UserError naming the parameter.
+func UnmarshalQuery(values url.Values, realObj interface{}, version float64) error {
+	return UnmarshalQueryWithOptions(values, realObj, version, Options{})
+}
+
+// UnmarshalQueryStrict is UnmarshalQuery, but returns a UserError for any parameter which isn't a field at version, including fields newer than version.
+func UnmarshalQueryStrict(values url.Values, realObj interface{}, version float64) error {
+	return UnmarshalQueryWithOptions(values, realObj, version, Options{DisallowUnknownFields: true})
+}
+
+// UnmarshalQueryWithOptions is UnmarshalQuery, with the tag names and behavior of opts. With opts.DisallowUnknownFields, it's UnmarshalQueryStrict.
+func UnmarshalQueryWithOptions(values url.Values, realObj interface{}, version float64, opts Options) error {
+	return unmarshalQuery(values, realObj, version, opts)
+}
+
+// UnmarshalForm parses the request's form, and sets the given object from the form body parameters, exactly like UnmarshalQuery.
+// Query parameters are not included, and may be unmarshalled separately with UnmarshalQuery(r.URL.Query(), ...).
+func UnmarshalForm(r *http.Request, realObj interface{}, version float64) error {
+	return UnmarshalFormWithOptions(r, realObj, version, Options{})
+}
+
+// UnmarshalFormStrict is UnmarshalForm, but returns a UserError for any parameter which isn't a field at version, like UnmarshalQueryStrict.
+func UnmarshalFormStrict(r *http.Request, realObj interface{}, version float64) error {
+	return UnmarshalFormWithOptions(r, realObj, version, Options{DisallowUnknownFields: true})
+}
+
+// UnmarshalFormWithOptions is UnmarshalForm, with the tag names and behavior of opts. With opts.DisallowUnknownFields, it's UnmarshalFormStrict.
+func UnmarshalFormWithOptions(r *http.Request, realObj interface{}, version float64, opts Options) error {
+	if err := r.ParseForm(); err != nil {
+		return UserError{"malformed form"}
+	}
+	return unmarshalQuery(r.PostForm, realObj, version, opts)
+}
+
+func unmarshalQuery(values url.Values, realObj interface{}, version float64, opts Options) error {
+	return unmarshalObj(queryCodec, realObj, version, opts, func(fakeObj interface{}) error {
+		fakeVal := reflect.Indirect(reflect.ValueOf(fakeObj))
+		if fakeVal.Kind() != reflect.Struct {
+			return InternalError{"query object must be a pointer to a struct"}
+		}
+		if opts.DisallowUnknownFields {
+			if err := checkQueryParams(values, fakeVal.Type(), reflect.Indirect(reflect.ValueOf(realObj)).Type(), version); err != nil {
+				return err
+			}
+		}
+		return decodeQuery(values, fakeVal)
+	})
+}
+
+// queryCodec names fields for query parameters. It only unmarshals, from query strings.
+var queryCodec Codec = queryCodecT{BaseCodec{Tag: "query"}}
+
+type queryCodecT struct{ BaseCodec }
+
+func (c queryCodecT) FieldTag(field reflect.StructField) reflect.StructTag {
+	return DeriveTagFromJSON(field, c.Tag)
+}
+
+func (c queryCodecT) Marshal(fakeObj interface{}, realType reflect.Type) ([]byte, error) {
+	return nil, InternalError{"marshalling query parameters is not supported"}
+}
+
+func (c queryCodecT) Unmarshal(data []byte, fakeObj interface{}) error {
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		return UserError{"malformed query"}
+	}
+	return decodeQuery(values, reflect.Indirect(reflect.ValueOf(fakeObj)))
+}
+
+// checkQueryParams returns a UserError if values has any parameter which isn't a field of the built type fakeType. Parameters of fields of realType newer than version are named in the error; other unknown parameters are not, because UserErrors never reflect user data.
+func checkQueryParams(values url.Values, fakeType reflect.Type, realType reflect.Type, version float64) error {
+	known := map[string]struct{}{}
+	for i := 0; i < fakeType.NumField(); i++ {
+		known[queryCodec.FieldName(fakeType.Field(i))] = struct{}{}
+	}
+	newer := map[string]struct{}{}
+	for i := 0; i < realType.NumField(); i++ {
+		field := realType.Field(i)
+		field.Tag = schemaFieldTag(queryCodec, realType, field)
+		newer[queryCodec.FieldName(field)] = struct{}{}
+	}
+	for key := range values {
+		if _, ok := known[key]; ok {
+			continue
+		}
+		if _, ok := newer[key]; ok {
+			return UserError{"query parameter '" + key + "' does not exist in version " + strconv.FormatFloat(version, 'f', -1, 64)}
+		}
+		return UserError{"unknown query parameter"}
+	}
+	return nil
+}
+
+// decodeQuery sets the fields of the built struct fakeVal from values.
+func decodeQuery(values url.Values, fakeVal reflect.Value) error {
+	for i := 0; i < fakeVal.NumField(); i++ {
+		field := fakeVal.Type().Field(i)
+		if isExported := field.PkgPath == ""; !isExported {
+			continue
+		}
+		name := queryCodec.FieldName(field)
+		if name == "-" {
+			continue
+		}
+		vals, ok := values[name]
+		if !ok || len(vals) == 0 {
+			continue
+		}
+		if err := setStringValues(fakeVal.Field(i), vals); err != nil {
+			return prefixError("query parameter '"+name+"': ", err)
+		}
+	}
+	return nil
+}
+
+// setStringValues sets val, allocating pointers, from the string values vals, such as query parameters or CSV cells. Slices are set from every value, and all other types from the first.
+func setStringValues(val reflect.Value, vals []string) error {
+	if val.Kind() == reflect.Ptr {
+		newVal := reflect.New(val.Type().Elem())
+		if err := setStringValues(newVal.Elem(), vals); err != nil {
+			return err
+		}
+		val.Set(newVal)
+		return nil
+	}
+	if val.Kind() == reflect.Slice && !isTextUnmarshaler(val.Type()) {
+		newVal := reflect.MakeSlice(val.Type(), len(vals), len(vals))
+		for i, s := range vals {
+			if err := setStringValues(newVal.Index(i), []string{s}); err != nil {
+				return err
+			}
+		}
+		val.Set(newVal)
+		return nil
+	}
+	return setStringValue(val, vals[0])
+}
+
+// setStringValue sets val from the single string value s, with its TextUnmarshaler if it has one, else with the str type of its kind.
+func setStringValue(val reflect.Value, s string) error {
+	if isTextUnmarshaler(val.Type()) {
+		if err := val.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+			if DefaultStrType(val.Kind()) == val.Type() {
+				return UserError{err.Error()} // str type errors never contain user data
+			}
+			return UserError{"invalid value"}
+		}
+		return nil
+	}
+	if val.Kind() == reflect.String {
+		val.SetString(s)
+		return nil
+	}
+
+	strType := DefaultStrType(val.Kind())
+	if strType == nil {
+		return InternalError{"type '" + val.Type().String() + "' can't be parsed from a string"}
+	}
+	strVal := reflect.New(strType)
+	if err := strVal.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+		return UserError{err.Error()}
+	}
+	strVal = strVal.Elem()
+
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if val.OverflowInt(strVal.Int()) {
+			return UserError{"integer out of range"}
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if val.OverflowUint(strVal.Uint()) {
+			return UserError{"integer out of range"}
+		}
+	case reflect.Float32:
+		if val.OverflowFloat(strVal.Float()) {
+			return UserError{"number out of range"}
+		}
+	}
+	val.Set(strVal.Convert(val.Type()))
+	return nil
+}
+
+func isTextUnmarshaler(typ reflect.Type) bool {
+	return reflect.PtrTo(typ).Implements(reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem())
+}
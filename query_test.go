@@ -0,0 +1,146 @@
+package apiver
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUnmarshalQuery(t *testing.T) {
+	type Obj struct {
+		Name   string  `json:"name"`
+		Limit  int     `query:"limit" json:"lim" api:"1.1"`
+		Offset *uint8  `json:"offset" api:"1.1"`
+		Ratio  float32 `json:"ratio" api:"1.1,str"`
+		Active bool    `json:"active" api:"1.2"`
+		New    *int    `json:"new" api:"1.4"`
+	}
+
+	values := url.Values{"name": {"foo"}, "limit": {"42"}, "offset": {"7"}, "ratio": {"4.5"}, "active": {"true"}, "new": {"9"}}
+	obj := Obj{}
+	if err := UnmarshalQuery(values, &obj, 1.3); err != nil {
+		t.Fatalf("UnmarshalQuery error expected nil, actual %+v", err)
+	}
+	if obj.Name != "foo" || obj.Limit != 42 || obj.Offset == nil || *obj.Offset != 7 || obj.Ratio != 4.5 || !obj.Active {
+		t.Errorf("UnmarshalQuery expected: %+v, actual: %+v", values, obj)
+	}
+	if obj.New != nil {
+		t.Errorf("UnmarshalQuery obj.New expected: nil, actual: %+v", *obj.New)
+	}
+}
+
+func TestUnmarshalQuerySlice(t *testing.T) {
+	type Obj struct {
+		IDs   []int    `json:"id" api:"1.1"`
+		Names []string `json:"name"`
+	}
+
+	values := url.Values{"id": {"1", "2", "3"}, "name": {"a", "b"}}
+	obj := Obj{}
+	if err := UnmarshalQuery(values, &obj, 1.1); err != nil {
+		t.Fatalf("UnmarshalQuery error expected nil, actual %+v", err)
+	}
+	expected := Obj{IDs: []int{1, 2, 3}, Names: []string{"a", "b"}}
+	if !reflect.DeepEqual(obj, expected) {
+		t.Errorf("UnmarshalQuery expected: %+v, actual: %+v", expected, obj)
+	}
+}
+
+func TestUnmarshalQueryTextUnmarshaler(t *testing.T) {
+	type Obj struct {
+		Since time.Time `json:"since" api:"1.1"`
+	}
+
+	values := url.Values{"since": {"2020-01-02T03:04:05Z"}}
+	obj := Obj{}
+	if err := UnmarshalQuery(values, &obj, 1.1); err != nil {
+		t.Fatalf("UnmarshalQuery error expected nil, actual %+v", err)
+	}
+	expected := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if !obj.Since.Equal(expected) {
+		t.Errorf("UnmarshalQuery obj.Since expected: %+v, actual: %+v", expected, obj.Since)
+	}
+}
+
+func TestUnmarshalQueryMissingVal(t *testing.T) {
+	type Obj struct {
+		Foo int `json:"foo" api:"1.1"`
+		A   int `json:"a" api:"1.4"`
+	}
+
+	obj := Obj{}
+	err := UnmarshalQuery(url.Values{"foo": {"42"}}, &obj, 1.4)
+	if err == nil || err.Error() != "missing required field: a" {
+		t.Errorf("UnmarshalQuery error expected 'missing required field: a', actual %+v", err)
+	}
+	if _, ok := err.(UserError); !ok {
+		t.Errorf("UnmarshalQuery error expected UserError, actual %T", err)
+	}
+}
+
+func TestUnmarshalQueryInvalid(t *testing.T) {
+	type Obj struct {
+		Foo int8 `json:"foo" api:"1.1"`
+	}
+
+	for _, val := range []string{"4.2", "asdf", "", "300"} {
+		obj := Obj{}
+		err := UnmarshalQuery(url.Values{"foo": {val}}, &obj, 1.1)
+		if _, ok := err.(UserError); !ok {
+			t.Errorf("UnmarshalQuery foo=%+v error expected UserError, actual %T %+v", val, err, err)
+		} else if !strings.HasPrefix(err.Error(), "query parameter 'foo': ") {
+			t.Errorf("UnmarshalQuery foo=%+v error expected to name parameter, actual %+v", val, err)
+		}
+	}
+}
+
+func TestUnmarshalQueryStrict(t *testing.T) {
+	type Obj struct {
+		Foo int  `json:"foo" api:"1.1"`
+		New *int `json:"new" api:"1.4"`
+	}
+
+	obj := Obj{}
+	if err := UnmarshalQueryStrict(url.Values{"foo": {"42"}}, &obj, 1.3); err != nil {
+		t.Errorf("UnmarshalQueryStrict error expected nil, actual %+v", err)
+	}
+
+	err := UnmarshalQueryStrict(url.Values{"foo": {"42"}, "new": {"9"}}, &obj, 1.3)
+	if err == nil || err.Error() != "query parameter 'new' does not exist in version 1.3" {
+		t.Errorf("UnmarshalQueryStrict newer error expected 'query parameter 'new' does not exist in version 1.3', actual %+v", err)
+	}
+
+	err = UnmarshalQueryStrict(url.Values{"foo": {"42"}, "<script>": {"9"}}, &obj, 1.3)
+	if err == nil || err.Error() != "unknown query parameter" {
+		t.Errorf("UnmarshalQueryStrict unknown error expected 'unknown query parameter', actual %+v", err)
+	}
+	if _, ok := err.(UserError); !ok {
+		t.Errorf("UnmarshalQueryStrict error expected UserError, actual %T", err)
+	}
+}
+
+func TestUnmarshalForm(t *testing.T) {
+	type Obj struct {
+		Foo int  `json:"foo" api:"1.1"`
+		Bar bool `json:"bar" api:"1.1,str"`
+		New *int `json:"new" api:"1.4"`
+	}
+
+	r, err := http.NewRequest(http.MethodPost, "/?bar=false", strings.NewReader("foo=42&bar=1&new=9"))
+	if err != nil {
+		t.Fatalf("http.NewRequest error expected nil, actual %+v", err)
+	}
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	obj := Obj{}
+	if err := UnmarshalForm(r, &obj, 1.3); err != nil {
+		t.Fatalf("UnmarshalForm error expected nil, actual %+v", err)
+	}
+	expected := Obj{Foo: 42, Bar: true}
+	if obj != expected {
+		t.Errorf("UnmarshalForm expected: %+v, actual: %+v", expected, obj)
+	}
+}
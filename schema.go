@@ -0,0 +1,134 @@
+package apiver
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FieldSchema declares the api properties of a struct field in Go code, for types which can't have api tags, such as types from third-party packages or generated code.
+// The properties are merged with any api tag on the field: a non-zero Version replaces the tag's version, and the other properties are added to the tag's.
+type FieldSchema struct {
+	// Version is the version the field was added in, as in an api tag. 0 leaves the version in the tag, if any.
+	Version float64
+	// Str is whether numbers and booleans may be strings, as the api tag 'str' property.
+	Str bool
+	// Required is whether the field must exist when unmarshalling, even if it isn't versioned. This is the api tag 'required' property.
+	Required bool
+	// Optional is whether the field may be missing when unmarshalling, even though it's versioned and not a pointer. This is the api tag 'optional' property.
+	Optional bool
+	// Deprecated is whether the field is deprecated in generated documentation, such as OpenAPI. This is the api tag 'deprecated' property.
+	Deprecated bool
+	// Rename is the name of the field in every encoding, replacing the name in its json tag, or the name of the encoding's own tag.
+	Rename string
+}
+
+var schemasMutex sync.RWMutex
+var schemas = map[reflect.Type]map[string]FieldSchema{}
+
+// schemaGeneration is incremented by every RegisterSchema, and is part of the key of cached plans, so plans built from an older schema are never used.
+var schemaGeneration uint64
+
+// RegisterSchema declares the properties of fields of the struct type typ, by Go field name. Fields which aren't declared only have the properties of their tags.
+// Registering a type again replaces its schema. Schemas should be registered before the type is used, such as in an init func. VersionMarshaler and VersionUnmarshaler methods, such as those generated by cmd/apivergen, don't use registered schemas.
+// Returns an InternalError if typ isn't a struct, or has no field with a declared name.
+func RegisterSchema(typ reflect.Type, fields map[string]FieldSchema) error {
+	if typ.Kind() != reflect.Struct {
+		return InternalError{"schema type '" + typ.String() + "' must be a struct"}
+	}
+	for name := range fields {
+		if _, ok := typ.FieldByName(name); !ok {
+			return InternalError{"schema type '" + typ.String() + "' has no field '" + name + "'"}
+		}
+	}
+
+	schema := map[string]FieldSchema{}
+	for name, field := range fields {
+		schema[name] = field
+	}
+
+	schemasMutex.Lock()
+	schemas[typ] = schema
+	schemaGeneration++
+	schemasMutex.Unlock()
+
+	// The decode and encode plans are built from the schema, so they must be rebuilt. Their keys have the new generation, so plans a concurrent call stores after this are only wasted memory, not used.
+	clearPlans(&decodePlans)
+	clearPlans(&encodePlans)
+	return nil
+}
+
+// getSchemaGeneration returns the number of calls to RegisterSchema so far.
+func getSchemaGeneration() uint64 {
+	schemasMutex.RLock()
+	defer schemasMutex.RUnlock()
+	return schemaGeneration
+}
+
+// GetSchema returns the field schemas registered for typ, by Go field name, and whether any were registered.
+func GetSchema(typ reflect.Type) (map[string]FieldSchema, bool) {
+	schemasMutex.RLock()
+	defer schemasMutex.RUnlock()
+	schema, ok := schemas[typ]
+	if !ok {
+		return nil, false
+	}
+	fields := map[string]FieldSchema{}
+	for name, field := range schema {
+		fields[name] = field
+	}
+	return fields, true
+}
+
+func getFieldSchema(typ reflect.Type, fieldName string) (FieldSchema, bool) {
+	schemasMutex.RLock()
+	defer schemasMutex.RUnlock()
+	field, ok := schemas[typ][fieldName]
+	return field, ok
+}
+
+func clearPlans(plans *sync.Map) {
+	plans.Range(func(key, val interface{}) bool {
+		plans.Delete(key)
+		return true
+	})
+}
+
+// GetFieldProperties returns the properties of field of the struct type typ: the properties of its api tag, merged with any schema registered for it with RegisterSchema.
+func GetFieldProperties(typ reflect.Type, field reflect.StructField) TagProperties {
+	return Options{}.GetFieldProperties(typ, field)
+}
+
+// schemaFieldTag returns c.FieldTag of field of the struct type typ, with the name of any registered Rename.
+// If c.FieldTag has no tag named c.TagName, such as a field without an xml tag, one with the Rename is added, so the Rename applies to every codec, not just those which derive names from json tags.
+func schemaFieldTag(c Codec, typ reflect.Type, field reflect.StructField) reflect.StructTag {
+	schema, ok := getFieldSchema(typ, field.Name)
+	if !ok || schema.Rename == "" {
+		return c.FieldTag(field)
+	}
+	field.Tag = renameTag(field.Tag, c.TagName(), schema.Rename)
+	tag := c.FieldTag(field)
+	if _, ok := tag.Lookup(c.TagName()); !ok {
+		tag = reflect.StructTag(c.TagName() + ":" + strconv.Quote(schema.Rename) + " " + string(tag))
+	}
+	return tag
+}
+
+// renameTag returns tag with the name in its json tag, and its tagKey tag if it has one, replaced by name, keeping their options.
+// The renamed tags are put first, because StructTag.Get returns the first tag with a key.
+func renameTag(tag reflect.StructTag, tagKey string, name string) reflect.StructTag {
+	keys := []string{"json"}
+	if _, ok := tag.Lookup(tagKey); ok && tagKey != "json" {
+		keys = append(keys, tagKey)
+	}
+	renamed := ""
+	for _, key := range keys {
+		opts := ""
+		if val := tag.Get(key); strings.Contains(val, ",") {
+			opts = val[strings.Index(val, ","):]
+		}
+		renamed += key + ":" + strconv.Quote(name+opts) + " "
+	}
+	return reflect.StructTag(renamed + string(tag))
+}
@@ -0,0 +1,169 @@
+package apiver
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// foreignServer is a type without api tags, as if it were from a package we don't own.
+type foreignServer struct {
+	Name    string `json:"name"`
+	Port    int    `json:"port"`
+	Cache   string `json:"cache"`
+	Weight  int    `json:"weight"`
+	Timeout int
+}
+
+func init() {
+	if err := RegisterSchema(reflect.TypeOf(foreignServer{}), map[string]FieldSchema{
+		"Name":    {Required: true},
+		"Port":    {Version: 1.1, Str: true},
+		"Cache":   {Version: 1.2, Rename: "cacheGroup"},
+		"Weight":  {Version: 1.2, Optional: true},
+		"Timeout": {Rename: "timeoutSeconds"},
+	}); err != nil {
+		panic(err)
+	}
+}
+
+func TestRegisterSchemaUnmarshal(t *testing.T) {
+	obj := foreignServer{}
+	err := UnmarshalJSON([]byte(`{"name": "a", "port": "80", "cacheGroup": "c", "timeoutSeconds": 5}`), &obj, 1.2)
+	if err != nil {
+		t.Fatalf("UnmarshalJSON error expected: nil, actual: %+v", err)
+	}
+	if expected := (foreignServer{Name: "a", Port: 80, Cache: "c", Timeout: 5}); obj != expected {
+		t.Errorf("UnmarshalJSON expected: %+v, actual: %+v", expected, obj)
+	}
+
+	tests := map[string]string{
+		`{"port": 80}`:               "missing required field: name",
+		`{"name": "a"}`:              "missing required field: port",
+		`{"name": "a", "port": 80}`:  "missing required field: cacheGroup",
+		`{"name": "a", "port": "x"}`: "not an integer",
+	}
+	for input, expected := range tests {
+		for _, unmarshal := range []func([]byte, interface{}, float64) error{UnmarshalJSON, UnmarshalJSONDirect} {
+			obj := foreignServer{}
+			if err := unmarshal([]byte(input), &obj, 1.2); err == nil || !strings.Contains(err.Error(), expected) {
+				t.Errorf("UnmarshalJSON %v error expected: %+v, actual: %+v", input, expected, err)
+			}
+		}
+	}
+
+	obj = foreignServer{}
+	if err := UnmarshalJSON([]byte(`{"name": "a", "port": 80, "cacheGroup": "c", "weight": 3}`), &obj, 1.1); err != nil {
+		t.Errorf("UnmarshalJSON older version error expected: nil, actual: %+v", err)
+	} else if obj.Cache != "" || obj.Weight != 0 {
+		t.Errorf("UnmarshalJSON older version expected newer fields unset, actual: %+v", obj)
+	}
+}
+
+func TestRegisterSchemaMarshal(t *testing.T) {
+	obj := foreignServer{Name: "a", Port: 80, Cache: "c", Weight: 3, Timeout: 5}
+	tests := map[float64]string{
+		1.0: `{"name":"a","timeoutSeconds":5}`,
+		1.1: `{"name":"a","port":80,"timeoutSeconds":5}`,
+		1.2: `{"name":"a","port":80,"cacheGroup":"c","weight":3,"timeoutSeconds":5}`,
+	}
+	for version, expected := range tests {
+		actual, err := MarshalJSON(obj, version)
+		if err != nil || string(actual) != expected {
+			t.Errorf("MarshalJSON version %v expected: %v, actual: %s %+v", version, expected, actual, err)
+		}
+		actual, err = MarshalJSONDirect(obj, version)
+		if err != nil || string(actual) != expected {
+			t.Errorf("MarshalJSONDirect version %v expected: %v, actual: %s %+v", version, expected, actual, err)
+		}
+	}
+
+	yaml, err := MarshalYAML(obj, 1.2)
+	if err != nil {
+		t.Fatalf("MarshalYAML error expected: nil, actual: %+v", err)
+	}
+	if !strings.Contains(string(yaml), "cacheGroup: c") || !strings.Contains(string(yaml), "timeoutSeconds: 5") {
+		t.Errorf("MarshalYAML expected renamed fields, actual: %s", yaml)
+	}
+}
+
+func TestRegisterSchemaXML(t *testing.T) {
+	obj := foreignServer{Name: "a", Port: 80, Cache: "c", Weight: 3, Timeout: 5}
+	xml, err := MarshalXML(obj, 1.2)
+	if err != nil {
+		t.Fatalf("MarshalXML error expected: nil, actual: %+v", err)
+	}
+	if !strings.Contains(string(xml), "<cacheGroup>c</cacheGroup>") || !strings.Contains(string(xml), "<timeoutSeconds>5</timeoutSeconds>") {
+		t.Errorf("MarshalXML expected renamed fields, actual: %s", xml)
+	}
+	if strings.Contains(string(xml), "<Timeout>") || strings.Contains(string(xml), "<Cache>") {
+		t.Errorf("MarshalXML expected no original names, actual: %s", xml)
+	}
+
+	actual := foreignServer{}
+	if err := UnmarshalXML(xml, &actual, 1.2); err != nil {
+		t.Fatalf("UnmarshalXML error expected: nil, actual: %+v", err)
+	}
+	if actual.Cache != "c" || actual.Timeout != 5 {
+		t.Errorf("UnmarshalXML expected: %+v, actual: %+v", obj, actual)
+	}
+	err = UnmarshalXML([]byte(`<foreignServer><Name>a</Name><Port>80</Port><Cache>c</Cache></foreignServer>`), &actual, 1.2)
+	if expected := "missing required field: cacheGroup"; err == nil || !strings.Contains(err.Error(), expected) {
+		t.Errorf("UnmarshalXML error expected: %+v, actual: %+v", expected, err)
+	}
+}
+
+// reregisteredServer is a type whose schema is registered again, after a plan was built from the old one.
+type reregisteredServer struct {
+	Name string `json:"name"`
+	Port int    `json:"port"`
+}
+
+func TestRegisterSchemaStalePlan(t *testing.T) {
+	typ := reflect.TypeOf(reregisteredServer{})
+	if err := RegisterSchema(typ, map[string]FieldSchema{"Port": {Version: 1.2}}); err != nil {
+		t.Fatalf("RegisterSchema error expected: nil, actual: %+v", err)
+	}
+
+	// a concurrent call which looked up the plan before RegisterSchema, and stores the plan it built from the old schema after it.
+	staleKey := newPlanKey(typ, 1.1, Options{})
+	staleEncodePlan := buildEncodePlan(buildUnmarshalType(JSONCodec, typ, 1.1, false, Options{}), typ)
+	staleDecodePlan := buildDecodePlan(buildUnmarshalType(JSONCodec, typ, 1.1, true, Options{}), typ)
+	if err := RegisterSchema(typ, map[string]FieldSchema{"Port": {Version: 1.1, Rename: "portNum"}}); err != nil {
+		t.Fatalf("RegisterSchema again error expected: nil, actual: %+v", err)
+	}
+	encodePlans.Store(staleKey, staleEncodePlan)
+	decodePlans.Store(staleKey, staleDecodePlan)
+
+	obj := reregisteredServer{Name: "a", Port: 80}
+	if actual, err := MarshalJSONDirect(obj, 1.1); err != nil || string(actual) != `{"name":"a","portNum":80}` {
+		t.Errorf("MarshalJSONDirect expected: %v, actual: %s %+v", `{"name":"a","portNum":80}`, actual, err)
+	}
+	obj = reregisteredServer{}
+	if err := UnmarshalJSONDirect([]byte(`{"name":"a"}`), &obj, 1.1); err == nil || !strings.Contains(err.Error(), "missing required field: portNum") {
+		t.Errorf("UnmarshalJSONDirect error expected: %+v, actual: %+v", "missing required field: portNum", err)
+	}
+}
+
+func TestRegisterSchemaErrors(t *testing.T) {
+	if err := RegisterSchema(reflect.TypeOf(0), map[string]FieldSchema{}); err == nil {
+		t.Errorf("RegisterSchema non-struct error expected: %+v, actual: %+v", "must be a struct", err)
+	}
+	if err := RegisterSchema(reflect.TypeOf(foreignServer{}), map[string]FieldSchema{"Missing": {}}); err == nil || !strings.Contains(err.Error(), "has no field 'Missing'") {
+		t.Errorf("RegisterSchema missing field error expected: %+v, actual: %+v", "has no field 'Missing'", err)
+	}
+	if schema, ok := GetSchema(reflect.TypeOf(foreignServer{})); !ok || schema["Cache"].Rename != "cacheGroup" {
+		t.Errorf("GetSchema after failed RegisterSchema expected: unchanged, actual: %+v %+v", schema, ok)
+	}
+}
+
+func TestGetTagPropertiesRequiredOptional(t *testing.T) {
+	props := GetTagProperties("1.2,optional")
+	if props.Version != 1.2 || !props.Optional || props.Required {
+		t.Errorf("GetTagProperties expected: %+v, actual: %+v", TagProperties{Version: 1.2, Optional: true}, props)
+	}
+	props = GetTagProperties("required,str")
+	if props.Version != 0 || !props.Required || !props.Str {
+		t.Errorf("GetTagProperties expected: %+v, actual: %+v", TagProperties{Required: true, Str: true}, props)
+	}
+}
@@ -1,8 +1,10 @@
 package apiver
 
 import (
+	"encoding/xml"
 	"errors"
 	"strconv"
+	"strings"
 )
 
 type IntS int64
@@ -52,6 +54,7 @@ func (i *FloatS) UnmarshalJSON(d []byte) error {
 
 // BoolS unmarshals a JSON bool or string as a boolean.
 // Note strings are unmarshaled like Perl: 0, "0" and "" are false; all other values are true.
+// UnmarshalText differs, because text can't distinguish strings from booleans and numbers: "false" and "0.0" are false as text, but true as JSON strings. Query parameters, CSV, and XML are unmarshaled as text.
 type BoolS bool
 
 func (i *BoolS) UnmarshalJSON(d []byte) error {
@@ -72,3 +75,240 @@ func (i *BoolS) UnmarshalJSON(d []byte) error {
 	*i = BoolS(true)
 	return nil
 }
+
+// UnmarshalStrValue sets str, which must be an *IntS, *UIntS, *FloatS, or *BoolS, from v, a value a codec such as MessagePack or CBOR decoded into an interface{}: an int64, uint64, float64, bool, or string.
+// Codecs with their own str types call it, so every codec coerces the same values. Numbers are parsed from strings, booleans are false if they're zero numbers, and strings are booleans like Perl, the same as BoolS.UnmarshalJSON.
+// Returns an error if v can't be coerced to str's type, or an InternalError if str isn't a str type.
+func UnmarshalStrValue(v interface{}, str interface{}) error {
+	switch str := str.(type) {
+	case *IntS:
+		switch v := v.(type) {
+		case int64:
+			*str = IntS(v)
+		case uint64:
+			if int64(v) < 0 {
+				return errors.New("not an integer")
+			}
+			*str = IntS(v)
+		case string:
+			di, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return errors.New("not an integer")
+			}
+			*str = IntS(di)
+		default:
+			return errors.New("not an integer")
+		}
+	case *UIntS:
+		switch v := v.(type) {
+		case int64:
+			if v < 0 {
+				return errors.New("not an integer")
+			}
+			*str = UIntS(v)
+		case uint64:
+			*str = UIntS(v)
+		case string:
+			di, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				return errors.New("not an integer")
+			}
+			*str = UIntS(di)
+		default:
+			return errors.New("not an integer")
+		}
+	case *FloatS:
+		switch v := v.(type) {
+		case int64:
+			*str = FloatS(v)
+		case uint64:
+			*str = FloatS(v)
+		case float64:
+			*str = FloatS(v)
+		case string:
+			di, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return errors.New("not a number")
+			}
+			*str = FloatS(di)
+		default:
+			return errors.New("not a number")
+		}
+	case *BoolS:
+		switch v := v.(type) {
+		case bool:
+			*str = BoolS(v)
+		case int64:
+			*str = BoolS(v != 0)
+		case uint64:
+			*str = BoolS(v != 0)
+		case float64:
+			*str = BoolS(v != 0.0)
+		case string:
+			*str = BoolS(v != "" && v != "0")
+		default:
+			*str = BoolS(true) // arrays, maps, etc. Same as UnmarshalJSON.
+		}
+	default:
+		return InternalError{"str value must be an *IntS, *UIntS, *FloatS, or *BoolS"}
+	}
+	return nil
+}
+
+// UnmarshalYAML unmarshals a YAML integer or string as an integer.
+func (i *IntS) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	s := ""
+	if err := unmarshal(&s); err != nil {
+		return errors.New("not an integer")
+	}
+	di, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return errors.New("not an integer")
+	}
+	*i = IntS(di)
+	return nil
+}
+
+// UnmarshalYAML unmarshals a YAML unsigned integer or string as an unsigned integer.
+func (i *UIntS) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	s := ""
+	if err := unmarshal(&s); err != nil {
+		return errors.New("not an integer")
+	}
+	di, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return errors.New("not an integer")
+	}
+	*i = UIntS(di)
+	return nil
+}
+
+// UnmarshalYAML unmarshals a YAML number or string as a number.
+func (i *FloatS) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	s := ""
+	if err := unmarshal(&s); err != nil {
+		return errors.New("not a number")
+	}
+	di, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return errors.New("not a number")
+	}
+	*i = FloatS(di)
+	return nil
+}
+
+// UnmarshalYAML unmarshals a YAML bool, number, or string as a boolean.
+// Bools are unmarshaled as-is, numbers are false if they're zero, and strings are unmarshaled like Perl, the same as UnmarshalJSON.
+func (i *BoolS) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	b := false
+	if err := unmarshal(&b); err == nil {
+		*i = BoolS(b)
+		return nil
+	}
+	f := 0.0
+	if err := unmarshal(&f); err == nil {
+		*i = BoolS(f != 0.0)
+		return nil
+	}
+	s := ""
+	if err := unmarshal(&s); err != nil {
+		*i = BoolS(true) // not a scalar, e.g. a sequence or mapping. Same as UnmarshalJSON.
+		return nil
+	}
+	*i = BoolS(s != "" && s != "0")
+	return nil
+}
+
+// UnmarshalXML unmarshals XML element text as an integer.
+func (i *IntS) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	s := ""
+	if err := d.DecodeElement(&s, &start); err != nil {
+		return err
+	}
+	return i.UnmarshalText([]byte(s))
+}
+
+// UnmarshalXMLAttr unmarshals an XML attribute as an integer.
+func (i *IntS) UnmarshalXMLAttr(attr xml.Attr) error { return i.UnmarshalText([]byte(attr.Value)) }
+
+// UnmarshalText unmarshals text, which may have surrounding whitespace, as an integer.
+func (i *IntS) UnmarshalText(text []byte) error {
+	di, err := strconv.ParseInt(strings.TrimSpace(string(text)), 10, 64)
+	if err != nil {
+		return errors.New("not an integer")
+	}
+	*i = IntS(di)
+	return nil
+}
+
+// UnmarshalXML unmarshals XML element text as an unsigned integer.
+func (i *UIntS) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	s := ""
+	if err := d.DecodeElement(&s, &start); err != nil {
+		return err
+	}
+	return i.UnmarshalText([]byte(s))
+}
+
+// UnmarshalXMLAttr unmarshals an XML attribute as an unsigned integer.
+func (i *UIntS) UnmarshalXMLAttr(attr xml.Attr) error { return i.UnmarshalText([]byte(attr.Value)) }
+
+// UnmarshalText unmarshals text, which may have surrounding whitespace, as an unsigned integer.
+func (i *UIntS) UnmarshalText(text []byte) error {
+	di, err := strconv.ParseUint(strings.TrimSpace(string(text)), 10, 64)
+	if err != nil {
+		return errors.New("not an integer")
+	}
+	*i = UIntS(di)
+	return nil
+}
+
+// UnmarshalXML unmarshals XML element text as a number.
+func (i *FloatS) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	s := ""
+	if err := d.DecodeElement(&s, &start); err != nil {
+		return err
+	}
+	return i.UnmarshalText([]byte(s))
+}
+
+// UnmarshalXMLAttr unmarshals an XML attribute as a number.
+func (i *FloatS) UnmarshalXMLAttr(attr xml.Attr) error { return i.UnmarshalText([]byte(attr.Value)) }
+
+// UnmarshalText unmarshals text, which may have surrounding whitespace, as a number.
+func (i *FloatS) UnmarshalText(text []byte) error {
+	di, err := strconv.ParseFloat(strings.TrimSpace(string(text)), 64)
+	if err != nil {
+		return errors.New("not a number")
+	}
+	*i = FloatS(di)
+	return nil
+}
+
+// UnmarshalXML unmarshals XML element text as a boolean.
+func (i *BoolS) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	s := ""
+	if err := d.DecodeElement(&s, &start); err != nil {
+		return err
+	}
+	return i.UnmarshalText([]byte(s))
+}
+
+// UnmarshalXMLAttr unmarshals an XML attribute as a boolean.
+func (i *BoolS) UnmarshalXMLAttr(attr xml.Attr) error { return i.UnmarshalText([]byte(attr.Value)) }
+
+// UnmarshalText unmarshals text as a boolean.
+// Text has no distinction between strings and numbers, so "true" and "false" are booleans, numbers are false if they're zero, and all other text is unmarshaled like Perl: "" is false, and everything else is true.
+func (i *BoolS) UnmarshalText(text []byte) error {
+	s := strings.TrimSpace(string(text))
+	if b, err := strconv.ParseBool(s); err == nil {
+		*i = BoolS(b)
+		return nil
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		*i = BoolS(f != 0.0)
+		return nil
+	}
+	*i = BoolS(s != "")
+	return nil
+}
@@ -0,0 +1,121 @@
+package apiver
+
+import (
+	"bytes"
+	"reflect"
+)
+
+// Unmarshal parses the JSON into a new T, with the same semantics as UnmarshalJSON.
+// Unlike UnmarshalJSON, misuse such as passing a non-pointer is a compile error, rather than an InternalError. If T is a pointer, the value pointed to is decoded, and JSON null returns a nil pointer.
+// This is NewTypedCodec[T](version).Unmarshal. To decode many objects, create a TypedCodec once.
+func Unmarshal[T any](bts []byte, version float64) (T, error) {
+	return NewTypedCodec[T](version).Unmarshal(bts)
+}
+
+// Marshal serializes obj to JSON, byte-for-byte identical to MarshalJSON.
+// This is NewTypedCodec[T](version).Marshal. To encode many objects, create a TypedCodec once.
+func Marshal[T any](obj T, version float64) ([]byte, error) {
+	return NewTypedCodec[T](version).Marshal(obj)
+}
+
+// TypedCodec decodes and encodes JSON for T at a single version, with plans built once when it's created, so no types are inspected per call.
+// It's named TypedCodec because Codec is the interface for serialization formats.
+// TypedCodec decodes with UnmarshalJSONDirect and encodes with MarshalJSONDirect, and calls VersionUnmarshaler and VersionMarshaler methods, like UnmarshalJSON and MarshalJSON. It is safe for concurrent use.
+type TypedCodec[T any] struct {
+	version float64
+	opts    Options
+	// ptr is whether T is a pointer, in which case the plans are for the value pointed to.
+	ptr    bool
+	decode *decodePlan
+	encode *encodePlan
+	// err is the InternalError of the Strict and RequireVersions checks of T, if any, which every call returns.
+	err error
+}
+
+// NewTypedCodec returns a TypedCodec for T at version.
+func NewTypedCodec[T any](version float64) *TypedCodec[T] {
+	return NewTypedCodecWithOptions[T](version, Options{})
+}
+
+// NewTypedCodecWithOptions returns a TypedCodec for T at version, with the tag names and behavior of opts, like UnmarshalJSONDirectWithOptions and MarshalJSONDirectWithOptions.
+func NewTypedCodecWithOptions[T any](version float64, opts Options) *TypedCodec[T] {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	c := &TypedCodec[T]{version: version, opts: opts}
+	if typ.Kind() == reflect.Ptr {
+		c.ptr = true
+		typ = typ.Elem()
+	}
+	c.err = opts.checkType(JSONCodec, typ)
+	c.decode = getDecodePlan(typ, version, opts)
+	c.encode = getEncodePlan(typ, version, opts)
+	return c
+}
+
+// Version returns the version the codec decodes and encodes.
+func (c *TypedCodec[T]) Version() float64 { return c.version }
+
+// Unmarshal parses the JSON into a new T. On error, the zero T is returned.
+func (c *TypedCodec[T]) Unmarshal(bts []byte) (T, error) {
+	obj := *new(T)
+	if err := c.UnmarshalInto(bts, &obj); err != nil {
+		return *new(T), err
+	}
+	return obj, nil
+}
+
+// UnmarshalInto parses the JSON into obj, preserving existing values in it, like UnmarshalJSON. Like UnmarshalJSONDirect, obj may be partially written if an error is returned.
+func (c *TypedCodec[T]) UnmarshalInto(bts []byte, obj *T) error {
+	if obj == nil {
+		return InternalError{"object must not be nil"}
+	}
+	if c.err != nil {
+		return c.err
+	}
+	if u, ok := versionUnmarshaler(obj); ok && c.opts.unmarshalHooks() {
+		return u.UnmarshalJSONVersion(bts, c.version)
+	}
+
+	val := reflect.ValueOf(obj).Elem()
+	if c.ptr {
+		if bytes.Equal(bytes.TrimSpace(bts), []byte("null")) {
+			val.Set(reflect.Zero(val.Type()))
+			return nil
+		}
+		if val.IsNil() {
+			val.Set(reflect.New(val.Type().Elem()))
+		}
+		if u, ok := versionUnmarshaler(val.Interface()); ok && c.opts.unmarshalHooks() {
+			return u.UnmarshalJSONVersion(bts, c.version)
+		}
+		val = val.Elem()
+	}
+	return unmarshalJSONDirect(bts, val, c.decode, c.opts.DisallowUnknownFields)
+}
+
+// Marshal serializes obj to JSON, byte-for-byte identical to MarshalJSON.
+func (c *TypedCodec[T]) Marshal(obj T) ([]byte, error) {
+	return marshalPooled(func(dst []byte) ([]byte, error) { return c.Append(dst, obj) })
+}
+
+// Append appends the JSON of obj to dst, and returns the extended buffer.
+func (c *TypedCodec[T]) Append(dst []byte, obj T) ([]byte, error) {
+	if c.err != nil {
+		return dst, c.err
+	}
+	if m, ok := versionMarshaler(obj); ok && c.opts.marshalHooks() {
+		bts, err := m.MarshalJSONVersion(c.version)
+		if err != nil {
+			return dst, err
+		}
+		return append(dst, bts...), nil
+	}
+
+	val := reflect.ValueOf(&obj).Elem() // addressable, like the copy MarshalJSON encodes
+	if c.ptr {
+		if val.IsNil() {
+			return append(dst, "null"...), nil
+		}
+		val = val.Elem()
+	}
+	return appendEncodePlan(dst, c.encode, val, false)
+}
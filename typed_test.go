@@ -0,0 +1,129 @@
+package apiver
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUnmarshalTyped(t *testing.T) {
+	obj, err := Unmarshal[directInner]([]byte(`{"name": "a", "count": "2"}`), 1.2)
+	if err != nil {
+		t.Fatalf("Unmarshal error expected: nil, actual: %+v", err)
+	}
+	if expected := (directInner{Name: "a", Count: 2}); !reflect.DeepEqual(expected, obj) {
+		t.Errorf("Unmarshal expected: %+v, actual: %+v", expected, obj)
+	}
+
+	obj, err = Unmarshal[directInner]([]byte(`{"name": "a"}`), 1.2)
+	if err == nil || !strings.Contains(err.Error(), "missing required field: count") {
+		t.Errorf("Unmarshal error expected: %+v, actual: %+v", "missing required field: count", err)
+	}
+	if !reflect.DeepEqual(directInner{}, obj) {
+		t.Errorf("Unmarshal error obj expected: %+v, actual: %+v", directInner{}, obj)
+	}
+}
+
+func TestUnmarshalTypedPtr(t *testing.T) {
+	obj, err := Unmarshal[*directInner]([]byte(`{"name": "a", "count": 2}`), 1.2)
+	if err != nil {
+		t.Fatalf("Unmarshal error expected: nil, actual: %+v", err)
+	}
+	if expected := (&directInner{Name: "a", Count: 2}); !reflect.DeepEqual(expected, obj) {
+		t.Errorf("Unmarshal expected: %+v, actual: %+v", expected, obj)
+	}
+
+	if _, err := Unmarshal[*directInner]([]byte(`{"name": "a"}`), 1.2); err == nil {
+		t.Errorf("Unmarshal pointer error expected: %+v, actual: %+v", "missing required field: count", err)
+	}
+
+	obj, err = Unmarshal[*directInner]([]byte(`null`), 1.2)
+	if err != nil || obj != nil {
+		t.Errorf("Unmarshal null expected: %+v %+v, actual: %+v %+v", nil, nil, obj, err)
+	}
+}
+
+func TestMarshalTyped(t *testing.T) {
+	objs := []directInner{{Name: "a", Count: 2}, {Name: "b"}}
+	for _, version := range []float64{1.0, 1.2, 1.3} {
+		expected, err := MarshalJSON(objs, version)
+		if err != nil {
+			t.Fatalf("MarshalJSON error expected: nil, actual: %+v", err)
+		}
+		actual, err := Marshal(objs, version)
+		if err != nil {
+			t.Fatalf("Marshal error expected: nil, actual: %+v", err)
+		}
+		if string(expected) != string(actual) {
+			t.Errorf("Marshal version %v expected: %s, actual: %s", version, expected, actual)
+		}
+
+		actual, err = Marshal(&objs[0], version)
+		if expected, _ := MarshalJSON(&objs[0], version); err != nil || string(expected) != string(actual) {
+			t.Errorf("Marshal pointer version %v expected: %s, actual: %s %+v", version, expected, actual, err)
+		}
+	}
+
+	actual, err := Marshal[*directInner](nil, 1.0)
+	if err != nil || string(actual) != "null" {
+		t.Errorf("Marshal nil expected: %+v, actual: %s %+v", "null", actual, err)
+	}
+}
+
+func TestTypedCodec(t *testing.T) {
+	codec := NewTypedCodec[directInner](1.3)
+	if codec.Version() != 1.3 {
+		t.Errorf("TypedCodec.Version expected: %+v, actual: %+v", 1.3, codec.Version())
+	}
+
+	note := "n"
+	expected := directInner{Name: "a", Count: 2, Note: &note}
+	bts, err := codec.Marshal(expected)
+	if err != nil {
+		t.Fatalf("TypedCodec.Marshal error expected: nil, actual: %+v", err)
+	}
+	actual, err := codec.Unmarshal(bts)
+	if err != nil {
+		t.Fatalf("TypedCodec.Unmarshal error expected: nil, actual: %+v", err)
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("TypedCodec round trip expected: %+v, actual: %+v", expected, actual)
+	}
+
+	into := directInner{Name: "existing"}
+	if err := codec.UnmarshalInto([]byte(`{"count": 3}`), &into); err != nil {
+		t.Fatalf("TypedCodec.UnmarshalInto error expected: nil, actual: %+v", err)
+	}
+	if into.Count != 3 {
+		t.Errorf("TypedCodec.UnmarshalInto count expected: %+v, actual: %+v", 3, into.Count)
+	}
+	if err := codec.UnmarshalInto([]byte(`{"count": 3}`), nil); err == nil {
+		t.Errorf("TypedCodec.UnmarshalInto nil error expected: %+v, actual: %+v", "object must not be nil", err)
+	}
+}
+
+func TestTypedCodecVersionHooks(t *testing.T) {
+	obj, err := Unmarshal[handVersioned]([]byte(`{"name": "a"}`), 1.0)
+	expected := handVersioned{}
+	expectedErr := UnmarshalJSON([]byte(`{"name": "a"}`), &expected, 1.0)
+	if !reflect.DeepEqual(expected, obj) || (err == nil) != (expectedErr == nil) {
+		t.Errorf("Unmarshal VersionUnmarshaler expected: %+v %+v, actual: %+v %+v", expected, expectedErr, obj, err)
+	}
+
+	bts, err := Marshal(obj, 1.0)
+	expectedBts, expectedErr := MarshalJSON(obj, 1.0)
+	if string(expectedBts) != string(bts) || (err == nil) != (expectedErr == nil) {
+		t.Errorf("Marshal VersionMarshaler expected: %s %+v, actual: %s %+v", expectedBts, expectedErr, bts, err)
+	}
+}
+
+func BenchmarkTypedCodecUnmarshal(b *testing.B) {
+	bts := []byte(benchmarkJSON)
+	codec := NewTypedCodec[directObj](1.3)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := codec.Unmarshal(bts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
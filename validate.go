@@ -0,0 +1,149 @@
+package apiver
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ValidateType returns an InternalError describing every problem with the api tags of the struct type typ, and of the types of its fields, which would otherwise be silently ignored. Problems are:
+// 1. malformed tags, per ParseTagProperties, such as the typo `api:"1,4"`
+// 2. the str property on a field which isn't a number or boolean, or a pointer to one
+// 3. non-pointer fields newer than the type's first version, which must have the optional property. The type's first version is its oldest field version. Such fields can never be set when unmarshalling older versions, so they can't be distinguished from their zero value.
+//
+// ValidateType is typically called in a test, or an init func. Calls with Options.Strict call it for every object.
+func ValidateType(typ reflect.Type) error {
+	return Options{}.ValidateType(typ)
+}
+
+// ValidateType is ValidateType, with the tag names of the options.
+func (o Options) ValidateType(typ reflect.Type) error {
+	return validateType(JSONCodec, typ, o)
+}
+
+// validateType is ValidateType, using the codec c for str types.
+func validateType(c Codec, typ reflect.Type, opts Options) error {
+	problems := []string{}
+	validateTypeProblems(c, typ, opts, map[reflect.Type]struct{}{}, &problems)
+	if len(problems) > 0 {
+		return InternalError{"invalid type '" + typ.String() + "': " + strings.Join(problems, "; ")}
+	}
+	return nil
+}
+
+// validateTypeProblems appends the problems of typ and the types of its fields to problems. The seen types are skipped, so recursive types terminate.
+func validateTypeProblems(c Codec, typ reflect.Type, opts Options, seen map[reflect.Type]struct{}, problems *[]string) {
+	if _, ok := seen[typ]; ok {
+		return
+	}
+	seen[typ] = struct{}{}
+
+	switch typ.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		validateTypeProblems(c, typ.Elem(), opts, seen, problems)
+		return
+	case reflect.Map:
+		validateTypeProblems(c, typ.Key(), opts, seen, problems)
+		validateTypeProblems(c, typ.Elem(), opts, seen, problems)
+		return
+	case reflect.Struct:
+	default:
+		return
+	}
+
+	fieldProps := make([]TagProperties, typ.NumField())
+	firstVersion := 0.0
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		props, err := opts.parseFieldProperties(typ, field)
+		if err != nil {
+			*problems = append(*problems, "type '"+typ.String()+"' field '"+field.Name+"': "+err.Error())
+		}
+		fieldProps[i] = props
+		if props.Version != 0 && (firstVersion == 0 || props.Version < firstVersion) {
+			firstVersion = props.Version
+		}
+	}
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		props := fieldProps[i]
+
+		if props.Str {
+			kind := field.Type.Kind()
+			if kind == reflect.Ptr {
+				kind = field.Type.Elem().Kind()
+			}
+			if c.StrType(kind) == nil {
+				*problems = append(*problems, "type '"+typ.String()+"' field '"+field.Name+"': str property on unsupported type '"+field.Type.String()+"'")
+			}
+		}
+
+		if props.Version > firstVersion && field.Type.Kind() != reflect.Ptr && !props.Optional {
+			*problems = append(*problems, "type '"+typ.String()+"' field '"+field.Name+"': version "+strconv.FormatFloat(props.Version, 'f', -1, 64)+" is newer than the type's first version "+strconv.FormatFloat(firstVersion, 'f', -1, 64)+", so it must be a pointer or optional")
+		}
+
+		validateTypeProblems(c, field.Type, opts, seen, problems)
+	}
+}
+
+// CheckVersionTags returns an InternalError listing every exported field of the struct type typ, and of the types of its fields, which has no api version, so nothing is unversioned by accident. Fields are listed by their path from typ, such as 'Servers[].Port'.
+// Fields ignored by encoding/json with `json:"-"`, and the fields of types with their own MarshalJSON or MarshalText methods, such as time.Time, aren't checked. The fields of foreign types may be given versions with RegisterSchema. Embedded fields aren't checked themselves, but their fields are.
+//
+// CheckVersionTags is typically called in a test, or an init func. Calls with Options.RequireVersions call it for every object.
+func CheckVersionTags(typ reflect.Type) error {
+	return Options{}.CheckVersionTags(typ)
+}
+
+// CheckVersionTags is CheckVersionTags, with the tag names of the options.
+func (o Options) CheckVersionTags(typ reflect.Type) error {
+	unversioned := []string{}
+	checkVersionTags(typ, "", o, map[reflect.Type]struct{}{}, &unversioned)
+	if len(unversioned) > 0 {
+		return InternalError{"type '" + typ.String() + "' has fields without a version in their " + o.withDefaults().TagName + " tag: '" + strings.Join(unversioned, "', '") + "'"}
+	}
+	return nil
+}
+
+// checkVersionTags appends the path of every unversioned field of typ and the types of its fields to unversioned, prefixing paths with path. The seen types are skipped, so recursive types terminate.
+func checkVersionTags(typ reflect.Type, path string, opts Options, seen map[reflect.Type]struct{}, unversioned *[]string) {
+	switch typ.Kind() {
+	case reflect.Ptr:
+		checkVersionTags(typ.Elem(), path, opts, seen, unversioned)
+		return
+	case reflect.Slice, reflect.Array:
+		checkVersionTags(typ.Elem(), path+"[]", opts, seen, unversioned)
+		return
+	case reflect.Map:
+		checkVersionTags(typ.Elem(), path+"[]", opts, seen, unversioned)
+		return
+	case reflect.Struct:
+	default:
+		return
+	}
+
+	if _, ok := seen[typ]; ok {
+		return
+	}
+	seen[typ] = struct{}{}
+	if hasMarshalMethod(typ) {
+		return
+	}
+
+	if path != "" {
+		path += "."
+	}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if isExported := field.PkgPath == ""; !isExported && !field.Anonymous {
+			continue
+		}
+		if field.Tag.Get("json") == "-" {
+			continue
+		}
+		if !field.Anonymous && opts.GetFieldProperties(typ, field).Version == 0 {
+			*unversioned = append(*unversioned, path+field.Name)
+		}
+		checkVersionTags(field.Type, path+field.Name, opts, seen, unversioned)
+	}
+}
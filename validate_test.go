@@ -0,0 +1,251 @@
+package apiver
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type validObj struct {
+	Name  string   `json:"name"`
+	Port  int      `json:"port" api:"1.1,str"`
+	Cache *string  `json:"cache" api:"1.2"`
+	Limit int      `json:"limit" api:"1.3,str,optional"`
+	Inner validSub `json:"inner"`
+}
+
+type validSub struct {
+	Ratio *float64 `json:"ratio" api:"1.2,str"`
+}
+
+type invalidObj struct {
+	Typo     int      `json:"typo" api:"1,4"`
+	Bogus    int      `json:"bogus" api:"1.1,strr"`
+	Both     int      `json:"both" api:"required,optional"`
+	StrSlice []int    `json:"strSlice" api:"1.1,str"`
+	Newer    int      `json:"newer" api:"1.3"`
+	Inner    []badSub `json:"inner"`
+}
+
+type badSub struct {
+	Name string `json:"name" api:"1.1,str"`
+}
+
+func TestValidateType(t *testing.T) {
+	if err := ValidateType(reflect.TypeOf(validObj{})); err != nil {
+		t.Errorf("ValidateType valid expected: nil, actual: %+v", err)
+	}
+
+	err := ValidateType(reflect.TypeOf(invalidObj{}))
+	if _, ok := err.(InternalError); !ok {
+		t.Fatalf("ValidateType invalid expected: InternalError, actual: %T %+v", err, err)
+	}
+	expecteds := []string{
+		"field 'Typo': malformed api tag '1,4': multiple versions '1' and '4'",
+		"field 'Bogus': malformed api tag '1.1,strr': unknown property 'strr'",
+		"field 'Both': malformed api tag 'required,optional': both required and optional",
+		"field 'StrSlice': str property on unsupported type '[]int'",
+		"field 'Newer': version 1.3 is newer than the type's first version 1.1",
+		"type 'apiver.badSub' field 'Name': str property on unsupported type 'string'",
+	}
+	for _, expected := range expecteds {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("ValidateType error expected: %+v, actual: %+v", expected, err)
+		}
+	}
+
+	// with the 'since' tag, the api tags are ignored
+	if err := (Options{TagName: "since"}).ValidateType(reflect.TypeOf(invalidObj{})); err != nil {
+		t.Errorf("Options.ValidateType other tag expected: nil, actual: %+v", err)
+	}
+}
+
+func TestParseTagProperties(t *testing.T) {
+	tests := map[string]string{
+		"":          "",
+		"1.1,str":   "",
+		"1,4":       "multiple versions",
+		"1.1,":      "empty property",
+		"NaN":       "invalid version",
+		"-1":        "invalid version",
+		"1.1,srt":   "unknown property 'srt'",
+		"required,": "empty property",
+	}
+	for tag, expected := range tests {
+		_, err := ParseTagProperties(tag)
+		if expected == "" && err != nil {
+			t.Errorf("ParseTagProperties '%v' expected: nil, actual: %+v", tag, err)
+		} else if expected != "" && (err == nil || !strings.Contains(err.Error(), expected)) {
+			t.Errorf("ParseTagProperties '%v' expected: %+v, actual: %+v", tag, expected, err)
+		}
+	}
+
+	if props := GetTagProperties("1,4"); props.Version != 4 {
+		t.Errorf("GetTagProperties malformed expected: %+v, actual: %+v", 4, props.Version)
+	}
+}
+
+func TestStrictOptions(t *testing.T) {
+	strict := Options{Strict: true}
+	obj := invalidObj{}
+	if err := UnmarshalJSONWithOptions([]byte(`{}`), &obj, 1.0, strict); err == nil || !strings.Contains(err.Error(), "multiple versions") {
+		t.Errorf("UnmarshalJSONWithOptions strict error expected: %+v, actual: %+v", "multiple versions", err)
+	}
+	if _, err := MarshalJSONWithOptions(obj, 1.0, strict); err == nil || !strings.Contains(err.Error(), "multiple versions") {
+		t.Errorf("MarshalJSONWithOptions strict error expected: %+v, actual: %+v", "multiple versions", err)
+	}
+	if _, err := MarshalJSONWithOptions(handVersioned{Foo: 42}, 1.1, strict); err != nil {
+		t.Errorf("MarshalJSONWithOptions strict valid error expected: nil, actual: %+v", err)
+	}
+
+	valid := validObj{}
+	if err := UnmarshalJSONWithOptions([]byte(`{"port": "80", "inner": {"ratio": "0.5"}}`), &valid, 1.2, strict); err != nil {
+		t.Errorf("UnmarshalJSONWithOptions strict valid error expected: nil, actual: %+v", err)
+	} else if valid.Port != 80 || valid.Inner.Ratio == nil || *valid.Inner.Ratio != 0.5 {
+		t.Errorf("UnmarshalJSONWithOptions strict valid expected: port 80 ratio 0.5, actual: %+v", valid)
+	}
+	if err := UnmarshalJSONWithOptions([]byte(`{"inner": {}}`), &valid, 1.2, strict); err == nil || !strings.Contains(err.Error(), "missing required field: port") {
+		t.Errorf("UnmarshalJSONWithOptions strict valid error expected: %+v, actual: %+v", "missing required field: port", err)
+	}
+}
+
+// strUnversioned has str fields which aren't pointerized, because they're unversioned or optional.
+type strUnversioned struct {
+	A int     `json:"a" api:"str"`
+	B float64 `json:"b" api:"1.1,str,optional"`
+}
+
+func TestStrUnversioned(t *testing.T) {
+	for _, input := range []string{`{"a": "1", "b": "2.5"}`, `{"a": 1, "b": 2.5}`} {
+		for _, unmarshal := range []func([]byte, interface{}, float64) error{UnmarshalJSON, UnmarshalJSONDirect} {
+			obj := strUnversioned{}
+			if err := unmarshal([]byte(input), &obj, 1.1); err != nil {
+				t.Errorf("UnmarshalJSON %v error expected: nil, actual: %+v", input, err)
+			} else if expected := (strUnversioned{A: 1, B: 2.5}); obj != expected {
+				t.Errorf("UnmarshalJSON %v expected: %+v, actual: %+v", input, expected, obj)
+			}
+		}
+	}
+
+	for _, unmarshal := range []func([]byte, interface{}, float64) error{UnmarshalJSON, UnmarshalJSONDirect} {
+		obj := strUnversioned{A: 3, B: 4}
+		if err := unmarshal([]byte(`{}`), &obj, 1.1); err != nil || obj != (strUnversioned{}) {
+			t.Errorf("UnmarshalJSON missing expected: %+v, actual: %+v %+v", strUnversioned{}, obj, err)
+		}
+	}
+
+	for _, marshal := range []func(interface{}, float64) ([]byte, error){MarshalJSON, MarshalJSONDirect} {
+		bts, err := marshal(strUnversioned{A: 1, B: 2.5}, 1.1)
+		if expected := `{"a":1,"b":2.5}`; err != nil || string(bts) != expected {
+			t.Errorf("MarshalJSON expected: %v, actual: %s %+v", expected, bts, err)
+		}
+	}
+}
+
+type versionedBase struct {
+	ID int `json:"id" api:"1.0"`
+}
+
+type versionedObj struct {
+	versionedBase
+	Name     string                      `json:"name" api:"1.0"`
+	Servers  []versionedServer           `json:"servers" api:"1.1"`
+	ByName   map[string]*versionedServer `json:"byName" api:"1.1"`
+	Updated  time.Time                   `json:"updated" api:"1.0"`
+	Internal string                      `json:"-"`
+	private  string
+}
+
+type versionedServer struct {
+	Host string           `json:"host" api:"1.1"`
+	Port int              `json:"port"`
+	Next *versionedServer `json:"next"`
+}
+
+func TestCheckVersionTags(t *testing.T) {
+	err := CheckVersionTags(reflect.TypeOf(versionedObj{}))
+	if _, ok := err.(InternalError); !ok {
+		t.Fatalf("CheckVersionTags expected: InternalError, actual: %T %+v", err, err)
+	}
+	if expected := "type 'apiver.versionedObj' has fields without a version in their api tag: 'Servers[].Port', 'Servers[].Next'"; err.Error() != expected {
+		t.Errorf("CheckVersionTags expected: %+v, actual: %+v", expected, err)
+	}
+
+	if err := CheckVersionTags(reflect.TypeOf(versionedBase{})); err != nil {
+		t.Errorf("CheckVersionTags versioned expected: nil, actual: %+v", err)
+	}
+	if err := CheckVersionTags(reflect.TypeOf([]foreignServer{})); err == nil || !strings.Contains(err.Error(), "'[].Name', '[].Timeout'") {
+		t.Errorf("CheckVersionTags schema expected: %+v, actual: %+v", "'[].Name', '[].Timeout'", err)
+	}
+
+	opts := Options{RequireVersions: true}
+	obj := versionedObj{}
+	if err := UnmarshalJSONWithOptions([]byte(`{}`), &obj, 1.1, opts); err == nil || !strings.Contains(err.Error(), "'Servers[].Port'") {
+		t.Errorf("UnmarshalJSONWithOptions RequireVersions error expected: %+v, actual: %+v", "'Servers[].Port'", err)
+	}
+	if _, err := MarshalJSONWithOptions(obj, 1.1, opts); err == nil || !strings.Contains(err.Error(), "'Servers[].Port'") {
+		t.Errorf("MarshalJSONWithOptions RequireVersions error expected: %+v, actual: %+v", "'Servers[].Port'", err)
+	}
+	if _, err := MarshalJSONWithOptions(versionedBase{ID: 1}, 1.1, opts); err != nil {
+		t.Errorf("MarshalJSONWithOptions RequireVersions versioned error expected: nil, actual: %+v", err)
+	}
+}
+
+func TestCheckTypeEveryFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     Options
+		obj      func() interface{}
+		objs     func() interface{}
+		expected string
+	}{
+		{"Strict", Options{Strict: true}, func() interface{} { return &invalidObj{} }, func() interface{} { return &[]invalidObj{} }, "multiple versions"},
+		{"RequireVersions", Options{RequireVersions: true}, func() interface{} { return &versionedObj{} }, func() interface{} { return &[]versionedObj{} }, "'Servers[].Port'"},
+	}
+	for _, test := range tests {
+		calls := map[string]func() error{
+			"UnmarshalYAMLWithOptions": func() error { return UnmarshalYAMLWithOptions([]byte(`{}`), test.obj(), 1.1, test.opts) },
+			"MarshalYAMLWithOptions": func() error {
+				_, err := MarshalYAMLWithOptions(test.obj(), 1.1, test.opts)
+				return err
+			},
+			"UnmarshalCodecWithOptions": func() error {
+				return UnmarshalCodecWithOptions(XMLCodec, []byte(`<a></a>`), test.obj(), 1.1, test.opts)
+			},
+			"MarshalCodecWithOptions": func() error {
+				_, err := MarshalCodecWithOptions(XMLCodec, test.obj(), 1.1, test.opts)
+				return err
+			},
+			"UnmarshalQueryWithOptions": func() error { return UnmarshalQueryWithOptions(url.Values{}, test.obj(), 1.1, test.opts) },
+			"UnmarshalCSVWithOptions":   func() error { return UnmarshalCSVWithOptions([]byte("a\n1\n"), test.objs(), 1.1, test.opts) },
+			"MarshalCSVWithOptions": func() error {
+				_, err := MarshalCSVWithOptions(test.objs(), 1.1, test.opts)
+				return err
+			},
+			"UnmarshalJSONDirectWithOptions": func() error { return UnmarshalJSONDirectWithOptions([]byte(`{}`), test.obj(), 1.1, test.opts) },
+			"MarshalJSONDirectWithOptions": func() error {
+				_, err := MarshalJSONDirectWithOptions(test.obj(), 1.1, test.opts)
+				return err
+			},
+		}
+		for name, call := range calls {
+			if err := call(); err == nil || !strings.Contains(err.Error(), test.expected) {
+				t.Errorf("%v %v error expected: %+v, actual: %+v", name, test.name, test.expected, err)
+			}
+		}
+	}
+
+	strictCodec := NewTypedCodecWithOptions[invalidObj](1.1, Options{Strict: true})
+	if _, err := strictCodec.Unmarshal([]byte(`{}`)); err == nil || !strings.Contains(err.Error(), "multiple versions") {
+		t.Errorf("TypedCodec.Unmarshal Strict error expected: %+v, actual: %+v", "multiple versions", err)
+	}
+	versionsCodec := NewTypedCodecWithOptions[*versionedObj](1.1, Options{RequireVersions: true})
+	if _, err := versionsCodec.Marshal(&versionedObj{}); err == nil || !strings.Contains(err.Error(), "'Servers[].Port'") {
+		t.Errorf("TypedCodec.Marshal RequireVersions error expected: %+v, actual: %+v", "'Servers[].Port'", err)
+	}
+	if _, err := NewTypedCodecWithOptions[versionedBase](1.1, Options{Strict: true, RequireVersions: true}).Marshal(versionedBase{ID: 1}); err != nil {
+		t.Errorf("TypedCodec.Marshal valid error expected: nil, actual: %+v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package apiver
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+)
+
+// DefaultVersionKey is the conventional document key holding the object version, as in Kubernetes-style `"apiVersion": "1.3"`.
+const DefaultVersionKey = `apiVersion`
+
+// UnmarshalJSONAutoVersion parses JSON for the given object, at the version stored in the document itself.
+// The versionKey field is read from the top-level JSON object first, and the object is then decoded exactly as UnmarshalJSON would decode it at that version. The version may be a JSON string, like `"1.3"`, or a number, like `1.3`.
+// Returns the version the document was decoded at.
+// This lets stored documents, such as queue messages or files written by older code, be read correctly without any out-of-band version information.
+func UnmarshalJSONAutoVersion(bts []byte, realObj interface{}, versionKey string) (float64, error) {
+	version, err := GetJSONVersion(bts, versionKey)
+	if err != nil {
+		return 0, err
+	}
+	if err := UnmarshalJSON(bts, realObj, version); err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+// GetJSONVersion returns the version in the versionKey field of the top-level JSON object in bts.
+// Returns a UserError if the key is missing, or is not a number or a string containing a number.
+func GetJSONVersion(bts []byte, versionKey string) (float64, error) {
+	doc := map[string]json.RawMessage{}
+	if err := json.Unmarshal(bts, &doc); err != nil {
+		return 0, err
+	}
+
+	rawVersion, ok := doc[versionKey]
+	if !ok || string(rawVersion) == `null` {
+		return 0, UserError{"missing required field: " + versionKey}
+	}
+
+	versionStr := ""
+	if err := json.Unmarshal(rawVersion, &versionStr); err != nil {
+		versionStr = string(rawVersion) // not a string, try to parse it as a number
+	}
+
+	version, err := strconv.ParseFloat(versionStr, 64)
+	if err != nil {
+		return 0, UserError{"field '" + versionKey + "' must be a version number"}
+	}
+	return version, nil
+}
+
+// MarshalJSONWithVersion serializes the given object as MarshalJSON does, and stamps the version into the versionKey field, as a string like `"1.3"`.
+// The key is written first, so readers may find it without parsing the whole document.
+// The object must serialize to a JSON object. It is an InternalError for the object to be a slice or primitive, or to already have a field named versionKey.
+func MarshalJSONWithVersion(realObj interface{}, version float64, versionKey string) ([]byte, error) {
+	bts, err := MarshalJSON(realObj, version)
+	if err != nil {
+		return nil, err
+	}
+	return stampJSONVersion(bts, version, versionKey)
+}
+
+// MarshalJSONIndentWithVersion is like MarshalJSONWithVersion but applies Indent to format the output.
+func MarshalJSONIndentWithVersion(realObj interface{}, prefix, indent string, version float64, versionKey string) ([]byte, error) {
+	bts, err := MarshalJSONWithVersion(realObj, version, versionKey)
+	if err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	if err := json.Indent(buf, bts, prefix, indent); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// stampJSONVersion inserts the versionKey field with the given version into the start of the serialized JSON object bts.
+func stampJSONVersion(bts []byte, version float64, versionKey string) ([]byte, error) {
+	bts = bytes.TrimSpace(bts)
+	if len(bts) < 2 || bts[0] != '{' {
+		return nil, InternalError{"object must serialize to a JSON object to stamp version key '" + versionKey + "'"}
+	}
+
+	existing := map[string]json.RawMessage{}
+	if err := json.Unmarshal(bts, &existing); err != nil {
+		return nil, err // should never happen, we just serialized it
+	}
+	if _, ok := existing[versionKey]; ok {
+		return nil, InternalError{"object already has a field named version key '" + versionKey + "'"}
+	}
+
+	key, err := json.Marshal(versionKey)
+	if err != nil {
+		return nil, err
+	}
+	val, err := json.Marshal(strconv.FormatFloat(version, 'f', -1, 64))
+	if err != nil {
+		return nil, err
+	}
+
+	stamped := make([]byte, 0, len(bts)+len(key)+len(val)+2)
+	stamped = append(stamped, '{')
+	stamped = append(stamped, key...)
+	stamped = append(stamped, ':')
+	stamped = append(stamped, val...)
+	if rest := bytes.TrimSpace(bts[1:]); len(rest) > 0 && rest[0] != '}' {
+		stamped = append(stamped, ',')
+	}
+	stamped = append(stamped, bts[1:]...)
+	return stamped, nil
+}
@@ -0,0 +1,194 @@
+package apiver
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnmarshalJSONAutoVersionStr(t *testing.T) {
+	type Obj struct {
+		Foo int  `json:"foo" api:"1.1,str"`
+		A   *int `json:"a" api:"1.4,str"`
+	}
+
+	obj := Obj{}
+	objJ := `{"apiVersion": "1.3", "foo": 42, "a": 24}`
+	version, err := UnmarshalJSONAutoVersion([]byte(objJ), &obj, DefaultVersionKey)
+	if err != nil {
+		t.Fatalf("UnmarshalJSONAutoVersion %+v error expected nil, actual %+v", objJ, err)
+	}
+	if version != 1.3 {
+		t.Errorf("UnmarshalJSONAutoVersion %+v version expected: %+v, actual: %+v", objJ, 1.3, version)
+	}
+	if obj.Foo != 42 {
+		t.Errorf("UnmarshalJSONAutoVersion %+v obj.Foo expected: %+v, actual: %+v", objJ, 42, obj.Foo)
+	}
+	if obj.A != nil {
+		t.Errorf("UnmarshalJSONAutoVersion %+v obj.A expected: nil, actual: %+v", objJ, *obj.A)
+	}
+}
+
+func TestUnmarshalJSONAutoVersionNum(t *testing.T) {
+	type Obj struct {
+		Foo int  `json:"foo" api:"1.1,str"`
+		A   *int `json:"a" api:"1.4,str"`
+	}
+
+	obj := Obj{}
+	objJ := `{"a": 24, "foo": 42, "v": 1.4}`
+	version, err := UnmarshalJSONAutoVersion([]byte(objJ), &obj, "v")
+	if err != nil {
+		t.Fatalf("UnmarshalJSONAutoVersion %+v error expected nil, actual %+v", objJ, err)
+	}
+	if version != 1.4 {
+		t.Errorf("UnmarshalJSONAutoVersion %+v version expected: %+v, actual: %+v", objJ, 1.4, version)
+	}
+	if obj.A == nil || *obj.A != 24 {
+		t.Errorf("UnmarshalJSONAutoVersion %+v obj.A expected: %+v, actual: %+v", objJ, 24, obj.A)
+	}
+}
+
+func TestUnmarshalJSONAutoVersionMissingKey(t *testing.T) {
+	type Obj struct {
+		Foo int `json:"foo" api:"1.1,str"`
+	}
+
+	obj := Obj{}
+	objJ := `{"foo": 42}`
+	_, err := UnmarshalJSONAutoVersion([]byte(objJ), &obj, DefaultVersionKey)
+	if err == nil {
+		t.Fatalf("UnmarshalJSONAutoVersion %+v error expected UserError, actual nil", objJ)
+	}
+	if _, ok := err.(UserError); !ok {
+		t.Errorf("UnmarshalJSONAutoVersion %+v error expected UserError, actual %T %+v", objJ, err, err)
+	}
+	if !strings.Contains(err.Error(), "missing required field: apiVersion") {
+		t.Errorf("UnmarshalJSONAutoVersion %+v error expected 'missing required field: apiVersion', actual %+v", objJ, err)
+	}
+}
+
+func TestUnmarshalJSONAutoVersionMalformedVersion(t *testing.T) {
+	type Obj struct {
+		Foo int `json:"foo" api:"1.1,str"`
+	}
+
+	obj := Obj{}
+	objJ := `{"apiVersion": "v1beta1", "foo": 42}`
+	_, err := UnmarshalJSONAutoVersion([]byte(objJ), &obj, DefaultVersionKey)
+	if _, ok := err.(UserError); !ok {
+		t.Errorf("UnmarshalJSONAutoVersion %+v error expected UserError, actual %T %+v", objJ, err, err)
+	}
+}
+
+func TestUnmarshalJSONAutoVersionMissingRequiredField(t *testing.T) {
+	type Obj struct {
+		Foo int `json:"foo" api:"1.1,str"`
+		A   int `json:"a" api:"1.4,str"`
+	}
+
+	obj := Obj{}
+	objJ := `{"apiVersion": "1.4", "foo": 42}`
+	_, err := UnmarshalJSONAutoVersion([]byte(objJ), &obj, DefaultVersionKey)
+	if err == nil || !strings.Contains(err.Error(), "missing required field: a") {
+		t.Errorf("UnmarshalJSONAutoVersion %+v error expected 'missing required field: a', actual %+v", objJ, err)
+	}
+}
+
+func TestMarshalJSONWithVersion(t *testing.T) {
+	type Obj struct {
+		Foo int  `json:"foo" api:"1.1"`
+		A   *int `json:"a" api:"1.4"`
+	}
+	a := 24
+	obj := Obj{Foo: 42, A: &a}
+
+	actual, err := MarshalJSONWithVersion(obj, 1.3, DefaultVersionKey)
+	if err != nil {
+		t.Fatalf("MarshalJSONWithVersion error expected: nil, actual: %+v", err)
+	}
+
+	expected := `{"apiVersion":"1.3","foo":42}`
+	if string(actual) != expected {
+		t.Errorf("MarshalJSONWithVersion expected ''%+v'', actual ''%+v''", expected, string(actual))
+	}
+}
+
+func TestMarshalJSONWithVersionEmptyObj(t *testing.T) {
+	type Obj struct {
+		A *int `json:"a,omitempty" api:"1.4"`
+	}
+
+	actual, err := MarshalJSONWithVersion(Obj{}, 1.4, DefaultVersionKey)
+	if err != nil {
+		t.Fatalf("MarshalJSONWithVersion error expected: nil, actual: %+v", err)
+	}
+
+	expected := `{"apiVersion":"1.4"}`
+	if string(actual) != expected {
+		t.Errorf("MarshalJSONWithVersion expected ''%+v'', actual ''%+v''", expected, string(actual))
+	}
+}
+
+func TestMarshalJSONWithVersionRoundTrip(t *testing.T) {
+	type Obj struct {
+		Foo int  `json:"foo" api:"1.1"`
+		A   *int `json:"a" api:"1.4"`
+	}
+	a := 24
+	obj := Obj{Foo: 42, A: &a}
+
+	bts, err := MarshalJSONWithVersion(obj, 1.4, DefaultVersionKey)
+	if err != nil {
+		t.Fatalf("MarshalJSONWithVersion error expected: nil, actual: %+v", err)
+	}
+
+	newObj := Obj{}
+	version, err := UnmarshalJSONAutoVersion(bts, &newObj, DefaultVersionKey)
+	if err != nil {
+		t.Fatalf("UnmarshalJSONAutoVersion error expected: nil, actual: %+v", err)
+	}
+	if version != 1.4 {
+		t.Errorf("UnmarshalJSONAutoVersion version expected: %+v, actual: %+v", 1.4, version)
+	}
+	if newObj.Foo != 42 || newObj.A == nil || *newObj.A != 24 {
+		t.Errorf("UnmarshalJSONAutoVersion expected: %+v, actual: %+v", obj, newObj)
+	}
+}
+
+func TestMarshalJSONIndentWithVersion(t *testing.T) {
+	type Obj struct {
+		Foo int `json:"foo" api:"1.1"`
+	}
+
+	actual, err := MarshalJSONIndentWithVersion(Obj{Foo: 42}, "", "\t", 1.1, DefaultVersionKey)
+	if err != nil {
+		t.Fatalf("MarshalJSONIndentWithVersion error expected: nil, actual: %+v", err)
+	}
+
+	expected := "{\n\t\"apiVersion\": \"1.1\",\n\t\"foo\": 42\n}"
+	if string(actual) != expected {
+		t.Errorf("MarshalJSONIndentWithVersion expected ''%+v'', actual ''%+v''", expected, string(actual))
+	}
+}
+
+func TestMarshalJSONWithVersionSlice(t *testing.T) {
+	type Obj struct {
+		Foo int `json:"foo" api:"1.1"`
+	}
+
+	_, err := MarshalJSONWithVersion([]Obj{{Foo: 42}}, 1.1, DefaultVersionKey)
+	if _, ok := err.(InternalError); !ok {
+		t.Errorf("MarshalJSONWithVersion slice error expected InternalError, actual %T %+v", err, err)
+	}
+}
+
+func TestMarshalJSONWithVersionDuplicateKey(t *testing.T) {
+	type Obj struct {
+		Version string `json:"apiVersion"`
+	}
+
+	_, err := MarshalJSONWithVersion(Obj{Version: "2.0"}, 1.1, DefaultVersionKey)
+	if _, ok := err.(InternalError); !ok {
+		t.Errorf("MarshalJSONWithVersion duplicate key error expected InternalError, actual %T %+v", err, err)
+	}
+}
@@ -0,0 +1,111 @@
+package apiver
+
+import (
+	"bytes"
+	"encoding/xml"
+	"reflect"
+)
+
+// UnmarshalXML parses XML for the given object.
+// This behaves like UnmarshalJSON: elements and attributes of fields newer than version are not deserialized into, 'str' fields are parsed from element text or attribute values, and missing required fields return a UserError naming the field by its xml tag.
+func UnmarshalXML(bts []byte, realObj interface{}, version float64) error {
+	return UnmarshalCodec(XMLCodec, bts, realObj, version)
+}
+
+// UnmarshalXMLWithOptions is UnmarshalXML, with the tag names and behavior of opts.
+// encoding/xml can't reject unknown elements, so opts.DisallowUnknownFields returns an InternalError.
+func UnmarshalXMLWithOptions(bts []byte, realObj interface{}, version float64, opts Options) error {
+	return UnmarshalCodecWithOptions(XMLCodec, bts, realObj, version, opts)
+}
+
+// MarshalXML serializes the given object as XML, omitting elements and attributes of fields newer than version.
+// The root element is named by the object's XMLName field if it has one, else by the name of its type, just like encoding/xml.
+func MarshalXML(realObj interface{}, version float64) ([]byte, error) {
+	return MarshalCodec(XMLCodec, realObj, version)
+}
+
+// MarshalXMLWithOptions is MarshalXML, with the tag names and behavior of opts.
+func MarshalXMLWithOptions(realObj interface{}, version float64, opts Options) ([]byte, error) {
+	return MarshalCodecWithOptions(XMLCodec, realObj, version, opts)
+}
+
+// MarshalXMLIndent is like MarshalXML but applies Indent to format the output.
+func MarshalXMLIndent(realObj interface{}, prefix, indent string, version float64) ([]byte, error) {
+	return marshalXML(realObj, version, prefix, indent, Options{})
+}
+
+func marshalXML(realObj interface{}, version float64, prefix, indent string, opts Options) ([]byte, error) {
+	obj, err := BuildMarshalCodecObjWithOptions(XMLCodec, realObj, version, opts)
+	if err != nil {
+		return nil, err
+	}
+	return marshalXMLObj(obj, reflect.TypeOf(realObj), prefix, indent)
+}
+
+// marshalXMLObj serializes the built object fakeObj, naming the root element after realType.
+func marshalXMLObj(fakeObj interface{}, realType reflect.Type, prefix, indent string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	encoder := xml.NewEncoder(buf)
+	encoder.Indent(prefix, indent)
+	if err := encodeXMLObj(encoder, fakeObj, realType, nil); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeXML encodes realObj at version with encoder.
+// If start is nil, the root element is named as encoding/xml would name realObj, rather than the built object, whose type is unnamed.
+func encodeXML(encoder *xml.Encoder, realObj interface{}, version float64, start *xml.StartElement, opts Options) error {
+	obj, err := BuildMarshalCodecObjWithOptions(XMLCodec, realObj, version, opts)
+	if err != nil {
+		return err
+	}
+	return encodeXMLObj(encoder, obj, reflect.TypeOf(realObj), start)
+}
+
+// encodeXMLObj encodes the built object fakeObj with encoder.
+// If start is nil, the root element is named as encoding/xml would name an object of realType.
+func encodeXMLObj(encoder *xml.Encoder, fakeObj interface{}, realType reflect.Type, start *xml.StartElement) error {
+	if start == nil {
+		start = xmlStartElement(realType)
+	}
+	if start == nil {
+		return encoder.Encode(fakeObj)
+	}
+	return encoder.EncodeElement(fakeObj, *start)
+}
+
+// XMLCodec is the Codec for encoding/xml.
+// Marshal names the root element after the real type, as encoding/xml would.
+var XMLCodec Codec = xmlCodec{BaseCodec{Tag: "xml"}}
+
+type xmlCodec struct{ BaseCodec }
+
+func (c xmlCodec) Marshal(fakeObj interface{}, realType reflect.Type) ([]byte, error) {
+	return marshalXMLObj(fakeObj, realType, "", "")
+}
+
+func (c xmlCodec) Unmarshal(data []byte, fakeObj interface{}) error {
+	return xml.Unmarshal(data, fakeObj)
+}
+
+// xmlStartElement returns the root element encoding/xml would use for the struct type typ, or the element type of typ if it's a pointer, slice, or array.
+// Returns nil if typ isn't a struct, or has an XMLName field with a name, which encoding/xml will use from the built object.
+func xmlStartElement(typ reflect.Type) *xml.StartElement {
+	if typ == nil {
+		return nil
+	}
+	for typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct || typ.Name() == "" {
+		return nil
+	}
+	if field, ok := typ.FieldByName("XMLName"); ok && tagFieldName(field, "xml") != field.Name {
+		return nil
+	}
+	return &xml.StartElement{Name: xml.Name{Local: typ.Name()}}
+}
@@ -0,0 +1,292 @@
+package apiver
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+type xmlServer struct {
+	ID       int     `xml:"id,attr" api:"1.1"`
+	Zone     *string `xml:"zone,attr" api:"1.3"`
+	Name     string  `xml:"name" api:"1.1"`
+	Port     *int    `xml:"port" api:"1.2,str"`
+	Weight   float64 `xml:"weight" api:"1.1,str"`
+	Online   bool    `xml:"online" api:"1.1,str"`
+	HostName *string `xml:"host>name" api:"1.3"`
+}
+
+func TestUnmarshalXMLBasic(t *testing.T) {
+	objX := `<xmlServer id="4" zone="east">
+  <name>foo</name>
+  <port> 8080 </port>
+  <weight>1.5</weight>
+  <online>0</online>
+  <host><name>foo.example.net</name></host>
+</xmlServer>`
+
+	obj := xmlServer{}
+	if err := UnmarshalXML([]byte(objX), &obj, 1.2); err != nil {
+		t.Fatalf("UnmarshalXML %+v error expected nil, actual %+v", objX, err)
+	}
+	if obj.ID != 4 {
+		t.Errorf("UnmarshalXML obj.ID expected: %+v, actual: %+v", 4, obj.ID)
+	}
+	if obj.Zone != nil {
+		t.Errorf("UnmarshalXML obj.Zone expected: nil, actual: %+v", *obj.Zone)
+	}
+	if obj.Name != "foo" {
+		t.Errorf("UnmarshalXML obj.Name expected: %+v, actual: %+v", "foo", obj.Name)
+	}
+	if obj.Port == nil || *obj.Port != 8080 {
+		t.Errorf("UnmarshalXML obj.Port expected: %+v, actual: %+v", 8080, obj.Port)
+	}
+	if obj.Weight != 1.5 {
+		t.Errorf("UnmarshalXML obj.Weight expected: %+v, actual: %+v", 1.5, obj.Weight)
+	}
+	if obj.Online {
+		t.Errorf("UnmarshalXML obj.Online expected: %+v, actual: %+v", false, obj.Online)
+	}
+	if obj.HostName != nil {
+		t.Errorf("UnmarshalXML obj.HostName expected: nil, actual: %+v", *obj.HostName)
+	}
+}
+
+func TestUnmarshalXMLNewerAttr(t *testing.T) {
+	objX := `<xmlServer id="4" zone="east"><name>foo</name><weight>1</weight><online>true</online><host><name>foo.example.net</name></host></xmlServer>`
+
+	obj := xmlServer{}
+	if err := UnmarshalXML([]byte(objX), &obj, 1.3); err != nil {
+		t.Fatalf("UnmarshalXML %+v error expected nil, actual %+v", objX, err)
+	}
+	if obj.Zone == nil || *obj.Zone != "east" {
+		t.Errorf("UnmarshalXML obj.Zone expected: %+v, actual: %+v", "east", obj.Zone)
+	}
+	if obj.HostName == nil || *obj.HostName != "foo.example.net" {
+		t.Errorf("UnmarshalXML obj.HostName expected: %+v, actual: %+v", "foo.example.net", obj.HostName)
+	}
+	if !obj.Online {
+		t.Errorf("UnmarshalXML obj.Online expected: %+v, actual: %+v", true, obj.Online)
+	}
+}
+
+func TestUnmarshalXMLStrAttr(t *testing.T) {
+	type Obj struct {
+		I int  `xml:"i,attr" api:"1.1,str"`
+		B bool `xml:"b,attr" api:"1.1,str"`
+	}
+
+	obj := Obj{}
+	objX := `<Obj i=" -42 " b="asdf"></Obj>`
+	if err := UnmarshalXML([]byte(objX), &obj, 1.1); err != nil {
+		t.Fatalf("UnmarshalXML %+v error expected nil, actual %+v", objX, err)
+	}
+	if obj.I != -42 {
+		t.Errorf("UnmarshalXML obj.I expected: %+v, actual: %+v", -42, obj.I)
+	}
+	if !obj.B {
+		t.Errorf("UnmarshalXML obj.B expected: %+v, actual: %+v", true, obj.B)
+	}
+}
+
+func TestUnmarshalXMLStrInvalid(t *testing.T) {
+	type Obj struct {
+		I int `xml:"i" api:"1.1,str"`
+	}
+
+	obj := Obj{}
+	objX := `<Obj><i>4.2</i></Obj>`
+	if err := UnmarshalXML([]byte(objX), &obj, 1.1); err == nil {
+		t.Errorf("UnmarshalXML %+v error expected non-nil, actual nil", objX)
+	}
+}
+
+func TestUnmarshalXMLMissingVal(t *testing.T) {
+	type Obj struct {
+		Foo int `json:"foo" xml:"xml_foo" api:"1.1"`
+		Bar int `json:"bar" xml:"bar_attr,attr" api:"1.1"`
+	}
+
+	obj := Obj{}
+	objX := `<Obj bar_attr="4"></Obj>`
+	err := UnmarshalXML([]byte(objX), &obj, 1.1)
+	if err == nil {
+		t.Fatalf("UnmarshalXML %+v error expected 'missing required field: xml_foo', actual nil", objX)
+	}
+	if _, ok := err.(UserError); !ok {
+		t.Errorf("UnmarshalXML %+v error expected UserError, actual %T", objX, err)
+	}
+	if err.Error() != "missing required field: xml_foo" {
+		t.Errorf("UnmarshalXML %+v error expected 'missing required field: xml_foo', actual %+v", objX, err)
+	}
+
+	objX = `<Obj><xml_foo>4</xml_foo></Obj>`
+	err = UnmarshalXML([]byte(objX), &obj, 1.1)
+	if err == nil || err.Error() != "missing required field: bar_attr" {
+		t.Errorf("UnmarshalXML %+v error expected 'missing required field: bar_attr', actual %+v", objX, err)
+	}
+}
+
+func TestUnmarshalXMLMissingValNoTag(t *testing.T) {
+	type Obj struct {
+		Foo int `api:"1.1"`
+	}
+
+	obj := Obj{}
+	err := UnmarshalXML([]byte(`<Obj></Obj>`), &obj, 1.1)
+	if err == nil || err.Error() != "missing required field: Foo" {
+		t.Errorf("UnmarshalXML error expected 'missing required field: Foo', actual %+v", err)
+	}
+}
+
+func TestMarshalXML(t *testing.T) {
+	zone := "east"
+	port := 8080
+	hostName := "foo.example.net"
+	obj := xmlServer{ID: 4, Zone: &zone, Name: "foo", Port: &port, Weight: 1.5, Online: true, HostName: &hostName}
+
+	actual, err := MarshalXML(obj, 1.2)
+	if err != nil {
+		t.Fatalf("MarshalXML error expected: nil, actual: %+v", err)
+	}
+
+	expected := `<xmlServer id="4"><name>foo</name><port>8080</port><weight>1.5</weight><online>true</online></xmlServer>`
+	if string(actual) != expected {
+		t.Errorf("MarshalXML expected ''%+v'', actual ''%+v''", expected, string(actual))
+	}
+
+	actual, err = MarshalXML(&obj, 1.3)
+	if err != nil {
+		t.Fatalf("MarshalXML error expected: nil, actual: %+v", err)
+	}
+
+	expected = `<xmlServer id="4" zone="east"><name>foo</name><port>8080</port><weight>1.5</weight><online>true</online><host><name>foo.example.net</name></host></xmlServer>`
+	if string(actual) != expected {
+		t.Errorf("MarshalXML expected ''%+v'', actual ''%+v''", expected, string(actual))
+	}
+}
+
+func TestMarshalXMLName(t *testing.T) {
+	type Obj struct {
+		XMLName xml.Name `xml:"server"`
+		Foo     int      `xml:"foo" api:"1.1"`
+		Bar     *int     `xml:"bar" api:"1.2"`
+	}
+	bar := 24
+
+	actual, err := MarshalXML(Obj{Foo: 42, Bar: &bar}, 1.1)
+	if err != nil {
+		t.Fatalf("MarshalXML error expected: nil, actual: %+v", err)
+	}
+
+	expected := `<server><foo>42</foo></server>`
+	if string(actual) != expected {
+		t.Errorf("MarshalXML expected ''%+v'', actual ''%+v''", expected, string(actual))
+	}
+}
+
+func TestMarshalXMLSlice(t *testing.T) {
+	type Obj struct {
+		Foo int  `xml:"foo" api:"1.1"`
+		Bar *int `xml:"bar" api:"1.2"`
+	}
+	bar := 24
+
+	actual, err := MarshalXML([]Obj{{Foo: 42, Bar: &bar}, {Foo: 43}}, 1.1)
+	if err != nil {
+		t.Fatalf("MarshalXML error expected: nil, actual: %+v", err)
+	}
+
+	expected := `<Obj><foo>42</foo></Obj><Obj><foo>43</foo></Obj>`
+	if string(actual) != expected {
+		t.Errorf("MarshalXML expected ''%+v'', actual ''%+v''", expected, string(actual))
+	}
+}
+
+func TestMarshalXMLIndent(t *testing.T) {
+	type Obj struct {
+		Foo int  `xml:"foo" api:"1.1"`
+		Bar *int `xml:"bar" api:"1.2"`
+	}
+
+	actual, err := NewXML(1.1).MarshalIndent(Obj{Foo: 42}, "", "\t")
+	if err != nil {
+		t.Fatalf("xml.MarshalIndent error expected: nil, actual: %+v", err)
+	}
+
+	expected := "<Obj>\n\t<foo>42</foo>\n</Obj>"
+	if string(actual) != expected {
+		t.Errorf("xml.MarshalIndent expected ''%+v'', actual ''%+v''", expected, string(actual))
+	}
+}
+
+func TestNewXMLRoundTrip(t *testing.T) {
+	zone := "east"
+	port := 8080
+	obj := xmlServer{ID: 4, Zone: &zone, Name: "foo", Port: &port, Weight: 1.5, Online: true}
+
+	xml := NewXML(1.3)
+	bts, err := xml.Marshal(obj)
+	if err != nil {
+		t.Fatalf("xml.Marshal error expected: nil, actual: %+v", err)
+	}
+	newObj := xmlServer{}
+	if err := xml.Unmarshal(bts, &newObj); err != nil {
+		t.Fatalf("xml.Unmarshal error expected: nil, actual: %+v", err)
+	}
+	if newObj.ID != obj.ID || newObj.Zone == nil || *newObj.Zone != zone || newObj.Name != obj.Name || newObj.Port == nil || *newObj.Port != port || newObj.Weight != obj.Weight || newObj.Online != obj.Online || newObj.HostName != nil {
+		t.Errorf("xml round trip expected %+v, actual %+v", obj, newObj)
+	}
+}
+
+func TestNewXMLDecoder(t *testing.T) {
+	type Obj struct {
+		Foo int  `xml:"foo" api:"1.1,str"`
+		A   *int `xml:"a" api:"1.4,str"`
+	}
+
+	objX := `<list><Obj><foo>42</foo><a>1</a></Obj><Obj><foo>24</foo></Obj></list>`
+	decoder := NewXML(1.3).NewDecoder(strings.NewReader(objX))
+
+	if _, err := decoder.Token(); err != nil {
+		t.Fatalf("xml.Decoder.Token error expected: nil, actual: %+v", err)
+	}
+	for _, expected := range []int{42, 24} {
+		obj := Obj{}
+		if err := decoder.Decode(&obj); err != nil {
+			t.Fatalf("xml.Decoder error expected: nil, actual: %+v", err)
+		}
+		if obj.Foo != expected {
+			t.Errorf("xml.Decoder expected obj.Foo %+v, actual %+v", expected, obj.Foo)
+		}
+		if obj.A != nil {
+			t.Errorf("xml.Decoder expected obj.A nil, actual %+v", *obj.A)
+		}
+	}
+}
+
+func TestNewXMLEncoder(t *testing.T) {
+	type Obj struct {
+		Foo int  `xml:"foo" api:"1.1"`
+		A   *int `xml:"a" api:"1.4"`
+	}
+	a := 24
+
+	buf := &bytes.Buffer{}
+	encoder := NewXML(1.3).NewEncoder(buf)
+	if err := encoder.Encode(Obj{Foo: 42, A: &a}); err != nil {
+		t.Fatalf("xml.Encoder error expected: nil, actual: %+v", err)
+	}
+	if err := encoder.EncodeElement(Obj{Foo: 43, A: &a}, xml.StartElement{Name: xml.Name{Local: "other"}}); err != nil {
+		t.Fatalf("xml.Encoder.EncodeElement error expected: nil, actual: %+v", err)
+	}
+	if err := encoder.Flush(); err != nil {
+		t.Fatalf("xml.Encoder.Flush error expected: nil, actual: %+v", err)
+	}
+
+	expected := `<Obj><foo>42</foo></Obj><other><foo>43</foo></other>`
+	if buf.String() != expected {
+		t.Errorf("xml.Encoder expected ''%+v'', actual ''%+v''", expected, buf.String())
+	}
+}
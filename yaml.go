@@ -0,0 +1,65 @@
+package apiver
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+// UnmarshalYAML parses YAML for the given object.
+// This behaves exactly like UnmarshalJSON: fields newer than version are not deserialized into, 'str' fields accept strings, and missing required fields return the same UserError.
+// Fields without a yaml tag use the name in their json tag, so the same struct definitions may be used for both encodings.
+func UnmarshalYAML(bts []byte, realObj interface{}, version float64) error {
+	return UnmarshalCodec(YAMLCodec, bts, realObj, version)
+}
+
+// UnmarshalYAMLWithOptions is UnmarshalYAML, with the tag names and behavior of opts.
+func UnmarshalYAMLWithOptions(bts []byte, realObj interface{}, version float64, opts Options) error {
+	return UnmarshalCodecWithOptions(YAMLCodec, bts, realObj, version, opts)
+}
+
+// MarshalYAML serializes the given object as YAML, omitting fields newer than version.
+// Fields without a yaml tag use the name in their json tag, so the same struct definitions may be used for both encodings.
+func MarshalYAML(realObj interface{}, version float64) ([]byte, error) {
+	return MarshalCodec(YAMLCodec, realObj, version)
+}
+
+// MarshalYAMLWithOptions is MarshalYAML, with the tag names and behavior of opts.
+func MarshalYAMLWithOptions(realObj interface{}, version float64, opts Options) ([]byte, error) {
+	return MarshalCodecWithOptions(YAMLCodec, realObj, version, opts)
+}
+
+// BuildMarshalYAMLObj is BuildMarshalObj, but the built object has yaml tags derived from json tags, for fields without yaml tags.
+func BuildMarshalYAMLObj(realObj interface{}, version float64) (interface{}, error) {
+	return buildMarshalObj(YAMLCodec, realObj, version, Options{})
+}
+
+// YAMLCodec is the Codec for gopkg.in/yaml.v3.
+// Built types have yaml tags derived from json tags, for fields without yaml tags.
+var YAMLCodec Codec = yamlCodec{BaseCodec{Tag: "yaml"}}
+
+type yamlCodec struct{ BaseCodec }
+
+func (c yamlCodec) Marshal(fakeObj interface{}, realType reflect.Type) ([]byte, error) {
+	return yaml.Marshal(fakeObj)
+}
+
+func (c yamlCodec) Unmarshal(data []byte, fakeObj interface{}) error {
+	return yaml.Unmarshal(data, fakeObj)
+}
+
+func (c yamlCodec) UnmarshalDisallowUnknownFields(data []byte, fakeObj interface{}) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(fakeObj); err != nil && err != io.EOF {
+		return err // io.EOF is an empty document, which yaml.Unmarshal accepts
+	}
+	return nil
+}
+
+// FieldTag returns the field's tag, with a yaml tag derived from the json tag, if the field has a json tag but no yaml tag.
+func (c yamlCodec) FieldTag(field reflect.StructField) reflect.StructTag {
+	return DeriveTagFromJSON(field, c.Tag)
+}
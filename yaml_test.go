@@ -0,0 +1,272 @@
+package apiver
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestUnmarshalYAMLBasic(t *testing.T) {
+	type Obj struct {
+		Foo int  `json:"foo" api:"1.1"`
+		A   *int `json:"a" api:"1.4"`
+	}
+
+	obj := Obj{}
+	objY := "foo: 42\na: 24\n"
+	if err := UnmarshalYAML([]byte(objY), &obj, 1.3); err != nil {
+		t.Fatalf("UnmarshalYAML %+v error expected nil, actual %+v", objY, err)
+	}
+	if obj.Foo != 42 {
+		t.Errorf("UnmarshalYAML %+v obj.Foo expected: %+v, actual: %+v", objY, 42, obj.Foo)
+	}
+	if obj.A != nil {
+		t.Errorf("UnmarshalYAML %+v obj.A expected: nil, actual: %+v", objY, *obj.A)
+	}
+}
+
+func TestUnmarshalYAMLStr(t *testing.T) {
+	type Obj struct {
+		I int     `json:"i" api:"1.1,str"`
+		U uint    `json:"u" api:"1.1,str"`
+		F float64 `json:"f" api:"1.1,str"`
+		B bool    `json:"b" api:"1.1,str"`
+	}
+
+	obj := Obj{}
+	objY := `i: "-42"
+u: "42"
+f: "4.2"
+b: "asdf"
+`
+	if err := UnmarshalYAML([]byte(objY), &obj, 1.1); err != nil {
+		t.Fatalf("UnmarshalYAML %+v error expected nil, actual %+v", objY, err)
+	}
+	expected := Obj{I: -42, U: 42, F: 4.2, B: true}
+	if obj != expected {
+		t.Errorf("UnmarshalYAML %+v expected: %+v, actual: %+v", objY, expected, obj)
+	}
+}
+
+func TestUnmarshalYAMLStrNative(t *testing.T) {
+	type Obj struct {
+		I int     `json:"i" api:"1.1,str"`
+		U uint    `json:"u" api:"1.1,str"`
+		F float64 `json:"f" api:"1.1,str"`
+		B bool    `json:"b" api:"1.1,str"`
+	}
+
+	obj := Obj{}
+	objY := "i: -42\nu: 42\nf: 4.2\nb: true\n"
+	if err := UnmarshalYAML([]byte(objY), &obj, 1.1); err != nil {
+		t.Fatalf("UnmarshalYAML %+v error expected nil, actual %+v", objY, err)
+	}
+	expected := Obj{I: -42, U: 42, F: 4.2, B: true}
+	if obj != expected {
+		t.Errorf("UnmarshalYAML %+v expected: %+v, actual: %+v", objY, expected, obj)
+	}
+}
+
+func TestUnmarshalYAMLBoolStrFalse(t *testing.T) {
+	type Obj struct {
+		A bool `json:"a" api:"1.1,str"`
+		B bool `json:"b" api:"1.1,str"`
+		C bool `json:"c" api:"1.1,str"`
+		D bool `json:"d" api:"1.1,str"`
+		E bool `json:"e" api:"1.1,str"`
+	}
+
+	obj := Obj{A: true, B: true, C: true, D: true}
+	objY := `a: false
+b: 0
+c: "0"
+d: ""
+e: "0.0"
+`
+	if err := UnmarshalYAML([]byte(objY), &obj, 1.1); err != nil {
+		t.Fatalf("UnmarshalYAML %+v error expected nil, actual %+v", objY, err)
+	}
+	expected := Obj{E: true}
+	if obj != expected {
+		t.Errorf("UnmarshalYAML %+v expected: %+v, actual: %+v", objY, expected, obj)
+	}
+}
+
+func TestUnmarshalYAMLIntStrInvalid(t *testing.T) {
+	type Obj struct {
+		I int `json:"i" api:"1.1,str"`
+	}
+
+	obj := Obj{}
+	objY := `i: "4.2"`
+	if err := UnmarshalYAML([]byte(objY), &obj, 1.1); err == nil {
+		t.Errorf("UnmarshalYAML %+v error expected non-nil, actual nil", objY)
+	}
+}
+
+func TestUnmarshalYAMLMissingVal(t *testing.T) {
+	type Obj struct {
+		Foo int `json:"foo" api:"1.1"`
+		A   int `json:"a" api:"1.4"`
+	}
+
+	obj := Obj{}
+	objY := "foo: 42\n"
+	err := UnmarshalYAML([]byte(objY), &obj, 1.4)
+	if err == nil {
+		t.Fatalf("UnmarshalYAML %+v error expected 'missing required field: a', actual nil", objY)
+	}
+	jsonErr := UnmarshalJSON([]byte(`{"foo": 42}`), &obj, 1.4)
+	if err.Error() != jsonErr.Error() {
+		t.Errorf("UnmarshalYAML %+v error expected to match JSON error '%+v', actual '%+v'", objY, jsonErr, err)
+	}
+	if _, ok := err.(UserError); !ok {
+		t.Errorf("UnmarshalYAML %+v error expected UserError, actual %T", objY, err)
+	}
+}
+
+func TestUnmarshalYAMLTags(t *testing.T) {
+	type Obj struct {
+		FooBar int  `json:"foo_bar" api:"1.1"`
+		Baz    int  `json:"baz" yaml:"yaml_baz" api:"1.1"`
+		Quux   *int `json:"-" api:"1.1"`
+	}
+
+	obj := Obj{}
+	objY := "foo_bar: 42\nyaml_baz: 24\nquux: 7\nQuux: 7\n"
+	if err := UnmarshalYAML([]byte(objY), &obj, 1.1); err != nil {
+		t.Fatalf("UnmarshalYAML %+v error expected nil, actual %+v", objY, err)
+	}
+	if obj.FooBar != 42 {
+		t.Errorf("UnmarshalYAML %+v obj.FooBar expected: %+v, actual: %+v", objY, 42, obj.FooBar)
+	}
+	if obj.Baz != 24 {
+		t.Errorf("UnmarshalYAML %+v obj.Baz expected: %+v, actual: %+v", objY, 24, obj.Baz)
+	}
+	if obj.Quux != nil {
+		t.Errorf("UnmarshalYAML %+v obj.Quux expected: nil, actual: %+v", objY, *obj.Quux)
+	}
+}
+
+func TestUnmarshalYAMLNested(t *testing.T) {
+	type B struct {
+		Val    int  `json:"val" api:"1.1,str"`
+		NewVal *int `json:"new_val" api:"1.5,str"`
+	}
+	type Obj struct {
+		B B `json:"b" api:"1.2"`
+	}
+
+	obj := Obj{}
+	objY := "b:\n  val: \"42\"\n  new_val: 99\n"
+	if err := UnmarshalYAML([]byte(objY), &obj, 1.4); err != nil {
+		t.Fatalf("UnmarshalYAML %+v error expected nil, actual %+v", objY, err)
+	}
+	if obj.B.Val != 42 {
+		t.Errorf("UnmarshalYAML %+v obj.B.Val expected: %+v, actual: %+v", objY, 42, obj.B.Val)
+	}
+	if obj.B.NewVal != nil {
+		t.Errorf("UnmarshalYAML %+v obj.B.NewVal expected: nil, actual: %+v", objY, *obj.B.NewVal)
+	}
+}
+
+func TestMarshalYAML(t *testing.T) {
+	type Obj struct {
+		FooBar int    `json:"foo_bar" api:"1.1,str"`
+		Empty  string `json:"empty,omitempty"`
+		A      *int   `json:"a" api:"1.4"`
+	}
+	a := 24
+	obj := Obj{FooBar: 42, A: &a}
+
+	actual, err := MarshalYAML(obj, 1.3)
+	if err != nil {
+		t.Fatalf("MarshalYAML error expected: nil, actual: %+v", err)
+	}
+
+	expected := "foo_bar: 42\n"
+	if string(actual) != expected {
+		t.Errorf("MarshalYAML expected ''%+v'', actual ''%+v''", expected, string(actual))
+	}
+}
+
+func TestNewYAMLDecoder(t *testing.T) {
+	type Obj struct {
+		Foo int  `json:"foo" api:"1.1,str"`
+		A   *int `json:"a" api:"1.4,str"`
+	}
+
+	objY := "foo: \"42\"\n---\nfoo: 24\n"
+	decoder := NewYAML(1.3).NewDecoder(strings.NewReader(objY))
+
+	for _, expected := range []int{42, 24} {
+		obj := Obj{}
+		if err := decoder.Decode(&obj); err != nil {
+			t.Fatalf("yaml.Decoder error expected: nil, actual: %+v", err)
+		}
+		if obj.Foo != expected {
+			t.Errorf("yaml.Decoder expected obj.Foo %+v, actual %+v", expected, obj.Foo)
+		}
+	}
+}
+
+func TestNewYAMLDecoderKnownFields(t *testing.T) {
+	type Obj struct {
+		Foo int  `json:"foo" api:"1.1"`
+		A   *int `json:"a" api:"1.4"`
+	}
+
+	objY := "foo: 42\na: 24\n"
+	decoder := NewYAML(1.3).NewDecoder(strings.NewReader(objY))
+	decoder.KnownFields(true)
+
+	obj := Obj{}
+	if err := decoder.Decode(&obj); err == nil {
+		t.Errorf("yaml.Decoder KnownFields newer field error expected: non-nil, actual: nil")
+	}
+}
+
+func TestNewYAMLEncoder(t *testing.T) {
+	type Obj struct {
+		Foo int  `json:"foo" api:"1.1"`
+		A   *int `json:"a" api:"1.4"`
+	}
+	a := 24
+
+	buf := &bytes.Buffer{}
+	encoder := NewYAML(1.3).NewEncoder(buf)
+	if err := encoder.Encode(Obj{Foo: 42, A: &a}); err != nil {
+		t.Fatalf("yaml.Encoder error expected: nil, actual: %+v", err)
+	}
+	if err := encoder.Close(); err != nil {
+		t.Fatalf("yaml.Encoder.Close error expected: nil, actual: %+v", err)
+	}
+
+	expected := "foo: 42\n"
+	if buf.String() != expected {
+		t.Errorf("yaml.Encoder expected ''%+v'', actual ''%+v''", expected, buf.String())
+	}
+}
+
+func TestNewYAMLRoundTrip(t *testing.T) {
+	type Obj struct {
+		Foo int     `json:"foo" api:"1.1"`
+		Bar float32 `json:"bar" api:"1.2,str"`
+		A   *string `json:"a" api:"1.4"`
+	}
+	a := "abc"
+	obj := Obj{Foo: 42, Bar: 4.5, A: &a}
+
+	yaml := NewYAML(1.4)
+	bts, err := yaml.Marshal(obj)
+	if err != nil {
+		t.Fatalf("yaml.Marshal error expected: nil, actual: %+v", err)
+	}
+	newObj := Obj{}
+	if err := yaml.Unmarshal(bts, &newObj); err != nil {
+		t.Fatalf("yaml.Unmarshal error expected: nil, actual: %+v", err)
+	}
+	if newObj.Foo != obj.Foo || newObj.Bar != obj.Bar || newObj.A == nil || *newObj.A != *obj.A {
+		t.Errorf("yaml round trip expected %+v, actual %+v", obj, newObj)
+	}
+}